@@ -0,0 +1,170 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+)
+
+// Associate two items, e.g. an asset with each of its owners, in a link table between their types:
+//
+// err := orm.Associate(stub, &asset, &owner)
+// var owners []Owner
+// err = orm.GetAssociated(stub, &asset, &owners)
+//
+// The link table orm_link_<type>_<type> is created by the first association. It has a row for each direction, so
+// the items associated with either item are read by key. Associating items again does nothing. The caller should be
+// allowed to update both items.
+func Associate(stub shim.ChaincodeStubInterface, a, b BlockchainItemizer, opts ...Option) error {
+	o := newOptions(stub, opts)
+	table, err := linkOf(stub, a, b, true, o)
+	if err != nil {
+		return err
+	}
+	if err := authorizeLink(stub, a, b, o); err != nil {
+		return err
+	}
+	if _, err := stub.GetTable(table); err == shim.ErrTableNotFound {
+		logger.Infof("Create Link %s", table)
+		err := stub.CreateTable(table, []*shim.ColumnDefinition{
+			{Name: "Type", Type: shim.ColumnDefinition_STRING, Key: true},
+			{Name: "Id", Type: shim.ColumnDefinition_INT64, Key: true},
+			{Name: "Associated", Type: shim.ColumnDefinition_INT64, Key: true},
+		})
+		if err != nil {
+			return errors.Wrap(err, "Could not create link "+table)
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "Could not get link "+table)
+	}
+	for _, row := range []shim.Row{linkRow(a, b), linkRow(b, a)} {
+		if _, err := stub.InsertRow(table, row); err != nil {
+			return errors.Wrap(err, "Could not associate in "+table)
+		}
+	}
+	return nil
+}
+
+// Remove the association of two items. Items that are not associated are left as they are. The caller should be
+// allowed to update both items.
+func Dissociate(stub shim.ChaincodeStubInterface, a, b BlockchainItemizer, opts ...Option) error {
+	o := newOptions(stub, opts)
+	table, err := linkOf(stub, a, b, true, o)
+	if err != nil {
+		return err
+	}
+	if err := authorizeLink(stub, a, b, o); err != nil {
+		return err
+	}
+	if _, err := stub.GetTable(table); err == shim.ErrTableNotFound {
+		return nil
+	}
+	for _, row := range []shim.Row{linkRow(a, b), linkRow(b, a)} {
+		if err := stub.DeleteRow(table, indexKey(row)); err != nil {
+			return errors.Wrap(err, "Could not dissociate in "+table)
+		}
+	}
+	return nil
+}
+
+// Get the items associated with an item by passing a pointer to a slice of their type. Links to items that no longer
+// exist or can't be read are skipped.
+func GetAssociated(stub shim.ChaincodeStubInterface, item BlockchainItemizer, associated interface{}, opts ...Option) error {
+	if err := checkSlice(associated); err != nil {
+		return errors.Wrap(err, "Object passed to GetAssociated should be a pointer to a slice")
+	}
+	v := reflect.ValueOf(associated).Elem()
+	t := v.Type().Elem()
+	o := newOptions(stub, opts)
+	table, err := linkOf(stub, item, reflect.New(t).Interface().(BlockchainItemizer), false, o)
+	if err != nil {
+		return err
+	}
+	if _, err := stub.GetTable(table); err == shim.ErrTableNotFound {
+		return nil
+	}
+
+	key := indexKey(linkRow(item, nil))
+	var ids []int64
+	err = scanKey(stub, table, key, func(tbl *shim.Table, row shim.Row) error {
+		ids = append(ids, row.Columns[2].GetInt64())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	authorizer, err := authorizerOf(stub, t, "read")
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		other := reflect.New(t).Interface().(BlockchainItemizer)
+		if err := get(stub, other, id, o); err != nil {
+			return err
+		}
+		if other.GetId() == 0 || authorizer.check(other) != nil {
+			continue // Stale link or not readable
+		}
+		v.Set(reflect.Append(v, reflect.ValueOf(other).Elem()))
+	}
+	return nil
+}
+
+// Get the link table between the types of two items. Both need an id to write a link, only the first to read them.
+func linkOf(stub shim.ChaincodeStubInterface, a, b BlockchainItemizer, write bool, o *options) (string, error) {
+	if err := checkStub(stub, write); err != nil {
+		return "", err
+	}
+	if err := checkNamespace(o); err != nil {
+		return "", err
+	}
+	var names []string
+	for _, item := range []BlockchainItemizer{a, b} {
+		if err := checkItem(item); err != nil {
+			return "", err
+		}
+		t := reflect.TypeOf(item).Elem()
+		if !keyedById(t) {
+			return "", errors.New(t.Name() + " is not keyed by id alone and can't be associated")
+		}
+		names = append(names, t.Name())
+	}
+	if a.GetId() == 0 || write && b.GetId() == 0 {
+		return "", errors.New("Items without an id can't be associated")
+	}
+	sort.Strings(names)
+	return o.conf().Namespace + "orm_link_" + names[0] + "_" + names[1], nil
+}
+
+// Check that the caller may update both items of a link, as they are stored; items that are not stored as they are
+// given
+func authorizeLink(stub shim.ChaincodeStubInterface, a, b BlockchainItemizer, o *options) error {
+	for _, item := range []BlockchainItemizer{a, b} {
+		if policyOf(reflect.TypeOf(item).Elem()) == nil {
+			continue
+		}
+		stored, err := getStoredItem(stub, item, o)
+		if err != nil {
+			return err
+		} else if stored == nil {
+			stored = item
+		}
+		if err := authorize(stub, "update", stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create the row that links item to other. Other can be nil for the key of the links of item.
+func linkRow(item, other BlockchainItemizer) shim.Row {
+	row := shim.Row{Columns: []*shim.Column{
+		{Value: &shim.Column_String_{String_: reflect.TypeOf(item).Elem().Name()}},
+		{Value: &shim.Column_Int64{Int64: item.GetId()}},
+	}}
+	if other != nil {
+		row.Columns = append(row.Columns, &shim.Column{Value: &shim.Column_Int64{Int64: other.GetId()}})
+	}
+	return row
+}
@@ -0,0 +1,58 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestAssociate(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE")
+	checkCreateOrders(t, stub, &TestOrder{}, 1, 1, 2)
+
+	var countries []TestIndexed
+	if err := GetAssociated(stub, &TestOrder{Saveable: Saveable{Id: 1}}, &countries); err != nil || len(countries) != 0 {
+		fail(t, "Items without a link table should have no associations")
+	}
+
+	order1, order2 := &TestOrder{Saveable: Saveable{Id: 1}}, &TestOrder{Saveable: Saveable{Id: 2}}
+	nl, be := &TestIndexed{Saveable: Saveable{Id: 1}}, &TestIndexed{Saveable: Saveable{Id: 2}}
+	for _, pair := range [][2]BlockchainItemizer{{order1, nl}, {order1, be}, {be, order2}, {order1, nl}} {
+		if err := Associate(stub, pair[0], pair[1]); err != nil {
+			fail(t, err)
+		}
+	}
+	if _, err := stub.GetTable("orm_link_TestIndexed_TestOrder"); err != nil {
+		fail(t, "The link table should be created")
+	}
+
+	if err := GetAssociated(stub, order1, &countries); err != nil || len(countries) != 2 || countries[1].Country != "BE" {
+		fail(t, "The associated items should be read")
+	}
+	var orders []TestOrder
+	if err := GetAssociated(stub, be, &orders); err != nil || len(orders) != 2 {
+		fail(t, "Associations should be read in both directions")
+	}
+
+	if err := Dissociate(stub, be, order1); err != nil {
+		fail(t, err)
+	}
+	countries = nil
+	if err := GetAssociated(stub, order1, &countries); err != nil || len(countries) != 1 || countries[0].Id != 1 {
+		fail(t, "Dissociated items should not be read")
+	}
+
+	// Links to deleted items are skipped
+	if err := Delete(stub, nl); err != nil {
+		fail(t, err)
+	}
+	countries = nil
+	if err := GetAssociated(stub, order1, &countries); err != nil || len(countries) != 0 {
+		fail(t, "Deleted items should be skipped")
+	}
+
+	if err := Associate(stub, order1, &TestIndexed{}); err == nil {
+		fail(t, "Items without an id can't be associated")
+	}
+}
@@ -0,0 +1,93 @@
+package orm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"time"
+)
+
+// The state of an item as stored at a transaction, for parties outside the network. Anyone with State can check
+// Digest. An attestation is not signed: it is only as trustworthy as the endorsed transaction TxId that returned it.
+// WrittenTxId tells which transaction wrote the attested row, to look it up in the blocks, when the versions of the
+// type are kept.
+type Attestation struct {
+	Table string
+	Id    int64
+	// The stored columns as a JSON object with sorted keys. The same row always gives the same bytes.
+	State []byte
+	// Hex encoded SHA-256 hash of State
+	Digest string
+	// The transaction that made the attestation
+	TxId string
+	// The transaction that last wrote the row, if the versions of the type are kept with KeepVersions
+	WrittenTxId string `json:",omitempty"`
+	// The time of the Clock. Zero when it can't tell the time.
+	Timestamp time.Time
+}
+
+// Create an attestation of the stored state of an item. The stored row is read, so changes to item that have not
+// been saved are not part of it.
+func Attest(stub shim.ChaincodeStubInterface, item BlockchainItemizer, opts ...Option) (*Attestation, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(item); err != nil {
+		return nil, err
+	}
+	if item.GetId() == 0 {
+		return nil, errors.New("Item cannot have id 0")
+	}
+	t := reflect.TypeOf(item).Elem()
+	o := newOptions(stub, opts)
+	name := tableName(t, o)
+	if policyOf(t) != nil {
+		stored, err := getStoredItem(stub, item, o)
+		if err != nil {
+			return nil, err
+		} else if stored == nil {
+			return nil, ErrNotFound
+		}
+		if err := authorize(stub, "read", stored); err != nil {
+			return nil, err
+		}
+	}
+
+	tbl, err := stub.GetTable(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get table "+name)
+	}
+	key, err := keyOf(item)
+	if err != nil {
+		return nil, err
+	}
+	row, err := stub.GetRow(name, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get %s with id %d", name, item.GetId())
+	}
+	if len(row.Columns) == 0 {
+		return nil, ErrNotFound
+	}
+
+	values := map[string]interface{}{}
+	for i, c := range row.Columns {
+		values[tbl.ColumnDefinitions[i].Name] = columnValue(c)
+	}
+	state, err := json.Marshal(values) // Keys of maps are sorted
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not serialize "+name)
+	}
+	digest := sha256.Sum256(state)
+
+	a := &Attestation{Table: name, Id: item.GetId(), State: state, Digest: hex.EncodeToString(digest[:]), TxId: stub.GetTxID()}
+	if a.WrittenTxId, err = lastWriteOf(stub, t, item.GetId(), o); err != nil {
+		return nil, err
+	}
+	if a.Timestamp, err = now(stub, o); err != nil {
+		logger.Warningf("No timestamp for attestation of %s %d: %v", name, item.GetId(), err)
+	}
+	return a, nil
+}
@@ -0,0 +1,61 @@
+package orm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestAttest(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("tx1")
+	checkCreateIndexed(t, stub, "NL")
+
+	item := TestIndexed{Saveable: Saveable{Id: 1}, Name: "unsaved"}
+	a, err := Attest(stub, &item)
+	if err != nil {
+		fail(t, err)
+	}
+	if string(a.State) != `{"Country":"NL","Id":1,"Name":""}` {
+		fail(t, "State should hold the stored columns: "+string(a.State))
+	}
+	digest := sha256.Sum256(a.State)
+	if a.Digest != hex.EncodeToString(digest[:]) || a.TxId != "tx1" || a.Table != "TestIndexed" {
+		fail(t, "Attestation should hold the digest and transaction")
+	}
+
+	if a.WrittenTxId != "" {
+		fail(t, "The writing transaction is only known when versions are kept")
+	}
+
+	item.Id = 2
+	if _, err := Attest(stub, &item); err != ErrNotFound {
+		fail(t, "Attesting a missing item should return ErrNotFound")
+	}
+}
+
+func TestAttestWrittenTxId(t *testing.T) {
+	KeepVersions(new(TestHistoric))
+	defer delete(keptVersions, "TestHistoric")
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("create")
+	if err := CreateTable(stub, new(TestHistoric)); err != nil {
+		fail(t, err)
+	}
+	item := TestHistoric{Status: "draft"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	stub.MockTransactionStart("publish")
+	item.Status = "published"
+	if err := Update(stub, &item); err != nil {
+		fail(t, err)
+	}
+
+	stub.MockTransactionStart("attest")
+	a, err := Attest(stub, &item)
+	if err != nil || a.TxId != "attest" || a.WrittenTxId != "publish" {
+		fail(t, "The attestation should tell which transaction wrote the row")
+	}
+}
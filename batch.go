@@ -0,0 +1,199 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+)
+
+// A write of a row that is held back until the session is flushed. A nil row deletes it.
+type pendingWrite struct {
+	key []shim.Column
+	row *shim.Row
+	// Whether the row was in the state before the session wrote it, to insert or replace it when flushed
+	existed bool
+}
+
+// The writes of a batching session, by table and key, in the order they were first written
+type batch struct {
+	tables []string
+	keys   map[string][]string
+	writes map[string]map[string]*pendingWrite
+	// The definitions of the tables written to, for the key columns of their rows
+	definitions map[string]*shim.Table
+}
+
+// Write the pending writes to the stub: one call per row, the last write of the row. A row that is inserted and then
+// deleted in the session is not written at all. Call it before the end of the invocation, e.g. with defer in Invoke.
+// Scans of a table flush its writes first, so they see them.
+func (s *Session) Flush() error {
+	if s.batch == nil {
+		return nil
+	}
+	for len(s.batch.tables) > 0 {
+		if err := s.flushTable(s.batch.tables[0]); err != nil {
+			return err
+		}
+	}
+	s.batch = nil
+	return nil
+}
+
+// Write the pending writes of a table
+func (s *Session) flushTable(table string) error {
+	if s.batch == nil {
+		return nil
+	}
+	waitForDrains(s)
+	for _, k := range s.batch.keys[table] {
+		w := s.batch.writes[table][k]
+		var err error
+		switch {
+		case w.row == nil && w.existed:
+			if err = s.ChaincodeStubInterface.DeleteRow(table, w.key); err == nil {
+				s.stats.RowsWritten++
+			}
+		case w.row != nil && w.existed:
+			if _, err = s.ChaincodeStubInterface.ReplaceRow(table, *w.row); err == nil {
+				s.countWrite(*w.row)
+			}
+		case w.row != nil:
+			if _, err = s.ChaincodeStubInterface.InsertRow(table, *w.row); err == nil {
+				s.countWrite(*w.row)
+			}
+		}
+		if err != nil {
+			return errors.Wrap(err, "Could not flush "+table)
+		}
+	}
+	s.dropTable(table)
+	return nil
+}
+
+// Forget the pending writes of a table
+func (s *Session) dropTable(table string) {
+	if s.batch == nil {
+		return
+	}
+	for i, t := range s.batch.tables {
+		if t == table {
+			s.batch.tables = append(s.batch.tables[:i], s.batch.tables[i+1:]...)
+			break
+		}
+	}
+	delete(s.batch.keys, table)
+	delete(s.batch.writes, table)
+	delete(s.batch.definitions, table)
+}
+
+// Get the pending write of a row, with the key of the row
+func (s *Session) pending(table string, key []shim.Column) (*pendingWrite, string, error) {
+	k, err := rowKey(table, key)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.batch == nil {
+		return nil, k, nil
+	}
+	return s.batch.writes[table][k], k, nil
+}
+
+// Hold back a write of a row until the session is flushed. Like the calls of the stub, a row is only created if it
+// doesn't exist and only updated if it does; returns whether it is written.
+func (s *Session) hold(table string, key []shim.Column, row *shim.Row, kind writeKind) (bool, error) {
+	w, k, err := s.pending(table, key)
+	if err != nil {
+		return false, err
+	}
+	exists, existed := false, false
+	if w != nil {
+		exists = w.row != nil
+	} else {
+		stored, err := s.ChaincodeStubInterface.GetRow(table, key)
+		if err != nil {
+			return false, err
+		}
+		exists, existed = len(stored.Columns) > 0, len(stored.Columns) > 0
+	}
+	if kind == created && exists || kind == updated && !exists || kind == deleted && !exists && w == nil {
+		return false, nil
+	}
+
+	if w != nil {
+		w.row = row
+		return true, nil
+	}
+	s.initBatch()
+	if s.batch.writes[table] == nil {
+		s.batch.tables = append(s.batch.tables, table)
+		s.batch.writes[table] = map[string]*pendingWrite{}
+	}
+	s.batch.keys[table] = append(s.batch.keys[table], k)
+	s.batch.writes[table][k] = &pendingWrite{key: key, row: row, existed: existed}
+	return true, nil
+}
+
+func (s *Session) initBatch() {
+	if s.batch == nil {
+		s.batch = &batch{keys: map[string][]string{}, writes: map[string]map[string]*pendingWrite{}, definitions: map[string]*shim.Table{}}
+	}
+}
+
+// Get the key columns of a row of a table
+func (s *Session) keyOfRow(table string, row shim.Row) ([]shim.Column, error) {
+	s.initBatch()
+	tbl, ok := s.batch.definitions[table]
+	if !ok {
+		var err error
+		if tbl, err = s.ChaincodeStubInterface.GetTable(table); err != nil {
+			return nil, err
+		}
+		s.batch.definitions[table] = tbl
+	}
+	var key []shim.Column
+	for i, cd := range tbl.ColumnDefinitions {
+		if cd.Key && i < len(row.Columns) {
+			key = append(key, *row.Columns[i])
+		}
+	}
+	return key, nil
+}
+
+// Write a row when the session is flushed
+func (s *Session) holdRow(table string, row shim.Row, kind writeKind) (bool, error) {
+	key, err := s.keyOfRow(table, row)
+	if err != nil {
+		return false, err
+	}
+	return s.hold(table, key, &row, kind)
+}
+
+// Get a row, which may be pending in the session
+func (s *Session) batchGet(table string, key []shim.Column) (shim.Row, bool, error) {
+	w, _, err := s.pending(table, key)
+	if err != nil || w == nil {
+		return shim.Row{}, false, err
+	}
+	if w.row == nil {
+		return shim.Row{}, true, nil
+	}
+	return *w.row, true, nil
+}
+
+// Get the key of a row as a string, to look it up in a map
+func rowKey(table string, key []shim.Column) (string, error) {
+	data, err := json.Marshal(toWireRow(keyPointers(key)))
+	if err != nil {
+		return "", errors.Wrap(err, "Could not read the key of "+table)
+	}
+	return string(data), nil
+}
+
+// Get pointers to key columns, to serialize them like the columns of a row
+func keyPointers(key []shim.Column) []*shim.Column {
+	var columns []*shim.Column
+	for i := range key {
+		columns = append(columns, &key[i])
+	}
+	return columns
+}
@@ -0,0 +1,95 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+// Counts the writes that reach the stub
+type writeCountingStub struct {
+	*shim.MockStub
+	writes int
+}
+
+func (s *writeCountingStub) InsertRow(tableName string, row shim.Row) (bool, error) {
+	s.writes++
+	return s.MockStub.InsertRow(tableName, row)
+}
+
+func (s *writeCountingStub) ReplaceRow(tableName string, row shim.Row) (bool, error) {
+	s.writes++
+	return s.MockStub.ReplaceRow(tableName, row)
+}
+
+func (s *writeCountingStub) DeleteRow(tableName string, key []shim.Column) error {
+	s.writes++
+	return s.MockStub.DeleteRow(tableName, key)
+}
+
+func TestSessionBatch(t *testing.T) {
+	stub := &writeCountingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode))}
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL")
+	stub.writes = 0
+
+	session := NewSession(stub)
+	session.Batch = true
+	var item TestIndexed
+	if err := Get(session, &item, 1); err != nil {
+		fail(t, err)
+	}
+	for _, country := range []string{"BE", "DE", "FR"} {
+		item.Country = country
+		if err := Update(session, &item); err != nil {
+			fail(t, err)
+		}
+	}
+	if stub.writes != 0 {
+		fail(t, "Writes should be held back until Flush")
+	}
+	var read TestIndexed
+	if err := Get(session, &read, 1); err != nil || read.Country != "FR" {
+		fail(t, "The session should read its own writes")
+	}
+	if err := Get(stub, &read, 1); err != nil || read.Country != "NL" {
+		fail(t, "The stub should not have the writes before Flush")
+	}
+
+	if err := session.Flush(); err != nil {
+		fail(t, err)
+	}
+	// The row, the old index row and the last new index row
+	if stub.writes != 3 {
+		fail(t, "Only the last write of every row should be flushed")
+	}
+	if err := Get(stub, &read, 1); err != nil || read.Country != "FR" {
+		fail(t, "The stub should have the writes after Flush")
+	}
+	if ids := checkIndexIds(t, stub, "FR"); len(ids) != 1 {
+		fail(t, "The index should be flushed")
+	}
+	if ids := checkIndexIds(t, stub, "BE"); len(ids) != 0 {
+		fail(t, "Superseded index rows should not be written")
+	}
+
+	// A row that is created and deleted in the session is never written
+	stub.writes = 0
+	if err := Create(session, &TestIndexed{Country: "BE"}); err != nil {
+		fail(t, err)
+	}
+	if err := Delete(session, &TestIndexed{Saveable: Saveable{Id: 2}}); err != nil {
+		fail(t, err)
+	}
+	if err := session.Flush(); err != nil || stub.writes != 0 {
+		fail(t, "Deleted rows should not be written")
+	}
+
+	// Scans see the writes of the session
+	if err := Create(session, &TestIndexed{Country: "BE"}); err != nil {
+		fail(t, err)
+	}
+	var items []TestIndexed
+	if err := GetAll(session, &items); err != nil || len(items) != 2 {
+		fail(t, "Scans should flush the writes of their table")
+	}
+}
@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+var saveableType = reflect.TypeOf(Saveable{})
+
+// The cause of an UnsupportedFieldError
+var ErrUnsupportedField = errors.New("Field type cannot be stored.")
+
+// Returned for entities with fields of a type that cannot be stored. Tag a field with `orm:"-"` to leave it out.
+type UnsupportedFieldError struct {
+	Type   string
+	Fields []string
+}
+
+func (e *UnsupportedFieldError) Error() string {
+	return fmt.Sprintf("Fields of %s cannot be stored: %s; tag them with `orm:\"-\"` to leave them out",
+		e.Type, strings.Join(e.Fields, ", "))
+}
+
+// So errors.Cause(err) == ErrUnsupportedField
+func (e *UnsupportedFieldError) Cause() error {
+	return ErrUnsupportedField
+}
+
+// Check that a stub can be used. Writes to a MockStub fail without a transaction, so check that one was started.
+// Writes wait for the scans of the transaction that were stopped early to be drained, so they never overlap reads of
+// the shim.
+func checkStub(stub shim.ChaincodeStubInterface, write bool) error {
+	if session, ok := stub.(*Session); ok && session != nil {
+		stub = session.ChaincodeStubInterface
+	}
+	if stub == nil {
+		return errors.New("Stub is nil")
+	}
+	if v := reflect.ValueOf(stub); v.Kind() == reflect.Ptr && v.IsNil() {
+		return errors.Errorf("Stub is a nil %v", v.Type())
+	}
+	if mock, ok := stub.(*shim.MockStub); ok && write && mock.TxID == "" {
+		return errors.New("No transaction in progress: call stub.MockTransactionStart(txid) before writing to a MockStub")
+	}
+	if write {
+		waitForDrains(stub)
+	}
+	return nil
+}
+
+// Check that an item can be used with reflection, and explain how to fix common mistakes instead of panicking
+func checkItem(item BlockchainItemizer) error {
+	if item == nil {
+		return errors.New("Item is nil")
+	}
+	t := reflect.TypeOf(item)
+	if t.Kind() != reflect.Ptr {
+		return errors.Errorf("Pass a pointer to your entity (&item) instead of a %v", t)
+	}
+	if reflect.ValueOf(item).IsNil() {
+		return errors.Errorf("Item is a nil %v", t)
+	}
+	if t.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("Entities should be structs, not %v", t.Elem())
+	}
+	if err := checkEmbedded(t.Elem()); err != nil {
+		return err
+	}
+	if err := checkFields(t.Elem()); err != nil {
+		return err
+	}
+
+	// SetId on a value receiver changes a copy
+	probe := reflect.New(t.Elem()).Interface().(BlockchainItemizer)
+	probe.SetId(1)
+	if probe.GetId() != 1 {
+		return errors.Errorf("SetId of %v should have a pointer receiver", t.Elem().Name())
+	}
+	return nil
+}
+
+// Check that all stored fields of an entity type can be stored in a column
+func checkFields(t reflect.Type) error {
+	var unsupported []string
+	columns := map[string]string{}
+	for _, f := range fieldsOf(t) {
+		if other, ok := columns[f.column]; ok {
+			return errors.Errorf("Fields %s and %s of %s are both stored in column %s", other, f.Name, t.Name(), f.column)
+		}
+		columns[f.column] = f.Name
+		if f.column == "" {
+			return errors.Errorf("Column name of field %s of %s is empty", f.Name, t.Name())
+		}
+		if _, ok := columnTypeOf(f.Type); !ok {
+			unsupported = append(unsupported, f.Name+" "+f.Type.String())
+		}
+	}
+	if len(unsupported) > 0 {
+		return &UnsupportedFieldError{Type: t.Name(), Fields: unsupported}
+	}
+	return nil
+}
+
+// Check the anonymous fields of an entity type
+func checkEmbedded(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		if f.Type.Kind() == reflect.Ptr && f.Type.Elem() == saveableType {
+			return errors.Errorf("Saveable must be embedded by value in %v, not as *Saveable", t.Name())
+		}
+		if f.Type.Kind() == reflect.Struct {
+			if err := checkEmbedded(f.Type); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Check that items is a pointer to a slice of entities
+func checkSlice(items interface{}) error {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.Errorf("Pass a pointer to a slice (&items) instead of a %v", reflect.TypeOf(items))
+	}
+	if v.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("Pass a pointer to a slice instead of a %v", v.Type())
+	}
+	t := v.Type().Elem().Elem()
+	if t.Kind() == reflect.Ptr {
+		return errors.Errorf("Pass a slice of entities ([]%v) instead of a slice of pointers", t.Elem().Name())
+	}
+	if _, ok := reflect.New(t).Interface().(BlockchainItemizer); !ok {
+		return errors.Errorf("%v doesn't implement BlockchainItemizer; embed an orm.Saveable", t)
+	}
+	return checkItem(reflect.New(t).Interface().(BlockchainItemizer))
+}
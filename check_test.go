@@ -0,0 +1,71 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"strings"
+	"testing"
+)
+
+type TestValueReceiver struct {
+	Id int64
+}
+
+func (s TestValueReceiver) GetId() int64   { return s.Id }
+func (s TestValueReceiver) SetId(id int64) { s.Id = id }
+
+type TestPointerSaveable struct {
+	Name string
+	*Saveable
+}
+
+func checkErrorContains(t *testing.T, err error, msg string) {
+	if err == nil {
+		fail(t, "Expected error containing: "+msg)
+	} else if !strings.Contains(err.Error(), msg) {
+		fail(t, "Expected error containing '"+msg+"', got: "+err.Error())
+	}
+}
+
+func TestCheckItem(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	checkErrorContains(t, Create(stub, TestValueReceiver{}), "Pass a pointer")
+	checkErrorContains(t, Create(stub, &TestValueReceiver{}), "pointer receiver")
+	checkErrorContains(t, CreateTable(stub, &TestPointerSaveable{}), "embedded by value")
+	checkErrorContains(t, Get(stub, (*TestStruct)(nil), 1), "nil")
+	checkErrorContains(t, Update(stub, nil), "nil")
+}
+
+func TestCheckSlice(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	var items []TestStruct
+	checkErrorContains(t, GetAll(stub, items), "Pass a pointer to a slice")
+	var s TestStruct
+	checkErrorContains(t, GetAll(stub, &s), "Pass a pointer to a slice")
+	var pointers []*TestStruct
+	checkErrorContains(t, Find(stub, &pointers, nil), "slice of pointers")
+	var strs []string
+	checkErrorContains(t, GetAll(stub, &strs), "BlockchainItemizer")
+}
+
+func TestCheckStub(t *testing.T) {
+	var s TestStruct
+	checkErrorContains(t, Get(nil, &s, 1), "Stub is nil")
+	checkErrorContains(t, Get((*shim.MockStub)(nil), &s, 1), "nil")
+	checkErrorContains(t, GetAll(NewSession(nil), &[]TestStruct{}), "Stub is nil")
+
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	checkErrorContains(t, CreateTable(stub, &s), "MockTransactionStart")
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	stub.MockTransactionEnd("test")
+	checkErrorContains(t, Create(NewSession(stub), &s), "MockTransactionStart")
+	if err := Get(stub, &s, 1); err != ErrNotFound {
+		fail(t, "Reads should not need a transaction")
+	}
+}
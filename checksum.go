@@ -0,0 +1,107 @@
+package orm
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Entities that implement Checksummer get a hidden Checksum column: an HMAC-SHA256 of the table name and the other
+// columns, written on every write and verified on every read. A row changed in the state database of a compromised
+// peer, or moved to another id, fails to read with an *IntegrityError. Implement the interface before the table is
+// created:
+//
+// func (u *User) ChecksumKey(stub shim.ChaincodeStubInterface) ([]byte, error) { return secret, nil }
+//
+// Keep the key out of the ledger, e.g. in the configuration of the chaincode, since anyone who has it can forge rows.
+type Checksummer interface {
+	ChecksumKey(stub shim.ChaincodeStubInterface) ([]byte, error)
+}
+
+const checksumColumn = "Checksum"
+
+// Returned when the checksum of a row doesn't match its content
+type IntegrityError struct {
+	Table string
+	Id    int64
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("Checksum of %s with id %d does not match: the row was changed outside the chaincode", e.Table, e.Id)
+}
+
+// Get the checksum key of the items of type t, or nil if they have no checksums
+func checksumKeyOf(stub shim.ChaincodeStubInterface, t reflect.Type) ([]byte, error) {
+	checksummer, ok := reflect.New(t).Interface().(Checksummer)
+	if !ok {
+		return nil, nil
+	}
+	key, err := checksummer.ChecksumKey(stub)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get checksum key of "+t.Name())
+	}
+	if len(key) == 0 {
+		return nil, errors.New("Checksum key of " + t.Name() + " is empty")
+	}
+	return key, nil
+}
+
+// Compute the checksum of the columns of a row of a table
+func checksumOf(key []byte, table string, columns []*shim.Column) ([]byte, error) {
+	content, err := json.Marshal(toWireRow(columns))
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not compute checksum")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(table))
+	mac.Write([]byte{0})
+	mac.Write(content)
+	return mac.Sum(nil), nil
+}
+
+// Add the checksum to a new row of an item, if it has one
+func sealRow(stub shim.ChaincodeStubInterface, name string, item BlockchainItemizer, row shim.Row) (shim.Row, error) {
+	key, err := checksumKeyOf(stub, reflect.TypeOf(item).Elem())
+	if err != nil || key == nil {
+		return row, err
+	}
+	checksum, err := checksumOf(key, name, row.Columns)
+	if err != nil {
+		return row, err
+	}
+	row.Columns = append(row.Columns, &shim.Column{Value: &shim.Column_Bytes{Bytes: checksum}})
+	return row, nil
+}
+
+// Verify the checksum of a stored row. A table without a checksum column fails as well: its definition is in the same
+// state as its rows, so dropping the column must not turn off the verification.
+func verifyRow(key []byte, tbl *shim.Table, row shim.Row) error {
+	n := len(tbl.ColumnDefinitions)
+	if key == nil || len(row.Columns) == 0 {
+		return nil
+	}
+	err := &IntegrityError{Table: tbl.Name}
+	if values := keyValues(tbl, row); len(values) > 0 {
+		err.Id, _ = values[0].(int64)
+	}
+	if n == 0 || tbl.ColumnDefinitions[n-1].Name != checksumColumn {
+		logger.Errorf("%v: the table has no checksum column", err)
+		return err
+	}
+	if len(row.Columns) != n {
+		return err
+	}
+	checksum, cerr := checksumOf(key, tbl.Name, row.Columns[:n-1])
+	if cerr != nil {
+		return cerr
+	}
+	if !hmac.Equal(checksum, row.Columns[n-1].GetBytes()) {
+		logger.Errorf("%v", err)
+		return err
+	}
+	return nil
+}
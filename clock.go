@@ -0,0 +1,58 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// Tells the time of the current transaction. All endorsers of a transaction should get the same time, so the
+// default is the timestamp of the transaction; set Config.Clock to a FixedClock in tests.
+type Clock func(stub shim.ChaincodeStubInterface) (time.Time, error)
+
+// The default Clock: the timestamp of the transaction as set by the client
+func TxClock(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "Could not get transaction timestamp")
+	}
+	if ts == nil {
+		return time.Time{}, errors.New("Transaction has no timestamp")
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+// A Clock that always tells the same time
+func FixedClock(t time.Time) Clock {
+	return func(stub shim.ChaincodeStubInterface) (time.Time, error) {
+		return t, nil
+	}
+}
+
+// A Clock that tells the time of the peer. Endorsers get different times, so it is reported by the Determinism
+// audit; only use it for logging or in tests.
+func WallClock(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	if err := auditDeterminism(nil, "WallClock used"); err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().UTC(), nil
+}
+
+// Use a different Clock for this operation
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.config.Clock = clock
+	}
+}
+
+// Get the time of the current transaction from the configured Clock, e.g. for timestamps in your own entities
+func Now(stub shim.ChaincodeStubInterface, opts ...Option) (time.Time, error) {
+	return now(stub, newOptions(stub, opts))
+}
+
+func now(stub shim.ChaincodeStubInterface, o *options) (time.Time, error) {
+	if clock := o.conf().Clock; clock != nil {
+		return clock(stub)
+	}
+	return TxClock(stub)
+}
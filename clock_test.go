@@ -0,0 +1,49 @@
+package orm
+
+import (
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+// Presents the timestamp of the transaction
+type timestampStub struct {
+	*shim.MockStub
+	ts *timestamp.Timestamp
+}
+
+func (s *timestampStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return s.ts, nil
+}
+
+func TestClock(t *testing.T) {
+	mock := shim.NewMockStub("cc", new(MockChaincode))
+	mock.MockTransactionStart("test")
+	stub := &timestampStub{MockStub: mock, ts: &timestamp.Timestamp{Seconds: 1500000000, Nanos: 5}}
+
+	if now, err := Now(stub); err != nil || !now.Equal(time.Unix(1500000000, 5)) {
+		fail(t, "Now should default to the timestamp of the transaction")
+	}
+
+	fixed := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	Configure(Config{Clock: FixedClock(fixed)})
+	defer Configure(Config{})
+	if now, err := Now(stub); err != nil || !now.Equal(fixed) {
+		fail(t, "Now should use the configured clock")
+	}
+
+	checkCreateIndexed(t, stub, "NL")
+	if a, err := Attest(stub, &TestIndexed{Saveable: Saveable{Id: 1}}); err != nil || !a.Timestamp.Equal(fixed) {
+		fail(t, "Attestation should use the clock")
+	}
+
+	if _, err := Now(stub, WithClock(WallClock)); err != nil {
+		fail(t, err)
+	}
+	Configure(Config{Determinism: AuditFail})
+	if _, err := Now(stub, WithClock(WallClock)); errors.Cause(err) != ErrNondeterministic {
+		fail(t, "WallClock should be reported by the audit")
+	}
+}
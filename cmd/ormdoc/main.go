@@ -0,0 +1,290 @@
+// Command ormdoc documents the data model of a chaincode as Markdown or HTML, to share it with the other members of a
+// consortium, or draws it as an entity-relationship diagram for Graphviz (dot) or PlantUML. It reads the metadata
+// that orm.MetadataJSON returns, e.g. written to a file by a test of the chaincode:
+//
+// ormdoc -format html metadata.json > model.html
+// ormdoc -format dot metadata.json | dot -Tsvg > model.svg
+// ormdoc -format openapi metadata.json > components.json
+// ormdoc -format proto -package model metadata.json > model.proto
+// ormdoc -format codec -package model metadata.json > codec_gen.go
+//
+// The codec format generates the orm.RowCodecs of the entities, to read and write their rows without reflection. It
+// needs the package the entities are declared in, and entities whose fields are all stored as they are, like strings,
+// integers, bools, byte slices and enums of those.
+//
+// Without a file, the metadata is read from stdin.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/arner/orm"
+	gofmt "go/format"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	texttemplate "text/template"
+)
+
+const markdown = `# Data model
+{{range .}}
+## {{.Name}}
+Table ` + "`{{.Table}}`" + `
+
+| Column | Type | Key | Unique | Size | References |
+| --- | --- | --- | --- | --- | --- |
+{{range .Columns}}| {{.Name}} | {{.Type}} | {{if .Key}}yes{{end}} | {{.Unique}} | {{if .Size}}{{.Size}}{{end}} | {{.References}} |
+{{end}}{{if .Indexes}}
+Indexes:
+{{range .Indexes}}
+- {{.Name}} ({{join .Columns}})
+{{- end}}
+{{end}}{{if .Policy}}
+Policy:
+{{range $op, $principals := .Policy}}
+- {{$op}}: {{join $principals}}
+{{- end}}
+{{end}}{{end}}`
+
+const html = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Data model</title></head>
+<body>
+<h1>Data model</h1>
+{{range .}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+<p>Table <code>{{.Table}}</code></p>
+<table>
+<tr><th>Column</th><th>Type</th><th>Key</th><th>Unique</th><th>Size</th><th>References</th></tr>
+{{range .Columns}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{if .Key}}yes{{end}}</td><td>{{.Unique}}</td><td>{{if .Size}}{{.Size}}{{end}}</td><td>{{if .References}}<a href="#{{.References}}">{{.References}}</a>{{end}}</td></tr>
+{{end}}</table>
+{{if .Indexes}}<h3>Indexes</h3>
+<ul>{{range .Indexes}}<li>{{.Name}} ({{join .Columns}})</li>{{end}}</ul>
+{{end}}{{if .Policy}}<h3>Policy</h3>
+<ul>{{range $op, $principals := .Policy}}<li>{{$op}}: {{join $principals}}</li>{{end}}</ul>
+{{end}}{{end}}
+</body>
+</html>
+`
+
+const dot = `digraph model {
+  node [shape=record];
+{{range .}}  "{{.Name}}" [label="{ {{.Name}} |{{range $i, $c := .Columns}}{{if $i}}\l{{end}}{{if .Key}}+ {{end}}{{.Name}} : {{.Type}}{{end}}\l}"];
+{{end}}{{range $e := .}}{{range .Columns}}{{if .References}}  "{{$e.Name}}" -> "{{.References}}" [label="{{.Name}}"];
+{{end}}{{end}}{{end}}}
+`
+
+const plantuml = `@startuml
+{{range .}}entity {{.Name}} {
+{{range .Columns}}{{if .Key}}  * {{.Name}} : {{.Type}}
+  --
+{{end}}{{end}}{{range .Columns}}{{if not .Key}}  {{.Name}} : {{.Type}}
+{{end}}{{end}}}
+{{end}}{{range $e := .}}{{range .Columns}}{{if .References}}{{$e.Name}} }o--|| {{.References}} : {{.Name}}
+{{end}}{{end}}{{end}}@enduml
+`
+
+const proto = `syntax = "proto3";
+
+package {{.Package}};
+{{range .Entities}}
+message {{.Name}} {
+{{range $i, $c := .Columns}}  {{protoType .Type}} {{.Name}} = {{inc $i}};
+{{end}}}
+{{end}}`
+
+// The proto3 types of the column types
+var protoTypes = map[string]string{
+	"BOOL":   "bool",
+	"BYTES":  "bytes",
+	"INT32":  "int32",
+	"INT64":  "int64",
+	"STRING": "string",
+	"UINT32": "uint32",
+	"UINT64": "uint64",
+}
+
+const codec = `// Code generated by ormdoc -format codec; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/arner/orm"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func init() {
+{{range .Entities}}	orm.RegisterCodec(&{{.Name}}{}, orm.RowCodec{Marshal: marshal{{.Name}}, Unmarshal: unmarshal{{.Name}}})
+{{end}}}
+{{range .Entities}}
+func marshal{{.Name}}(item orm.BlockchainItemizer) ([]*shim.Column, error) {
+	v := item.(*{{.Name}})
+	return []*shim.Column{
+{{range .Columns}}		{Value: {{.Marshal}}},
+{{end}}	}, nil
+}
+
+func unmarshal{{.Name}}(columns []*shim.Column, item orm.BlockchainItemizer) error {
+	v := item.(*{{.Name}})
+{{range .Columns}}	v.{{.Field}} = {{.Unmarshal}}
+{{end}}	return nil
+}
+{{end}}`
+
+// How generated codecs copy the values of a column type: the shim.Column wrapper, its field, getter and Go type
+type codecType struct {
+	wrapper, field, getter, goType string
+}
+
+var codecTypes = map[string]codecType{
+	"BOOL":   {"Column_Bool", "Bool", "GetBool", "bool"},
+	"BYTES":  {"Column_Bytes", "Bytes", "GetBytes", "[]byte"},
+	"INT32":  {"Column_Int32", "Int32", "GetInt32", "int32"},
+	"INT64":  {"Column_Int64", "Int64", "GetInt64", "int64"},
+	"STRING": {"Column_String_", "String_", "GetString_", "string"},
+	"UINT32": {"Column_Uint32", "Uint32", "GetUint32", "uint32"},
+	"UINT64": {"Column_Uint64", "Uint64", "GetUint64", "uint64"},
+}
+
+// The statements of a generated codec for one column
+type codecColumn struct {
+	Field, Marshal, Unmarshal string
+}
+
+// Get the statements of the codec of an entity, for the columns of its fields
+func codecOf(e orm.EntityMetadata) ([]codecColumn, error) {
+	var columns []codecColumn
+	for i, c := range e.Columns {
+		if c.Field == "" {
+			continue
+		}
+		ct, ok := codecTypes[c.Type]
+		if !ok || c.GoType == "" {
+			return nil, fmt.Errorf("Field %s of %s has an encoding of its own, which codecs can't generate", c.Field, e.Name)
+		}
+		goType := strings.TrimPrefix(c.GoType, protoPackage+".")
+		if strings.Contains(goType, ".") {
+			return nil, fmt.Errorf("Field %s of %s has type %s from another package than %s", c.Field, e.Name, c.GoType, protoPackage)
+		}
+		value, column := "v."+c.Field, fmt.Sprintf("columns[%d].%s()", i, ct.getter)
+		if goType != ct.goType {
+			value, column = ct.goType+"("+value+")", goType+"("+column+")"
+		}
+		columns = append(columns, codecColumn{
+			Field:     c.Field,
+			Marshal:   fmt.Sprintf("&shim.%s{%s: %s}", ct.wrapper, ct.field, value),
+			Unmarshal: column,
+		})
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s has no Go fields in its metadata; write the metadata again with this version of orm", e.Name)
+	}
+	return columns, nil
+}
+
+func join(values []string) string {
+	return strings.Join(values, ", ")
+}
+
+// The package of generated .proto files and codecs
+var protoPackage = "model"
+
+// Write the documentation of entities in a format
+func render(w io.Writer, entities []orm.EntityMetadata, format string) error {
+	switch format {
+	case "proto":
+		for _, e := range entities {
+			for _, c := range e.Columns {
+				if _, ok := protoTypes[c.Type]; !ok {
+					return fmt.Errorf("Column %s of %s has type %s, which has no proto type", c.Name, e.Name, c.Type)
+				}
+			}
+		}
+		funcs := texttemplate.FuncMap{
+			"protoType": func(typ string) string { return protoTypes[typ] },
+			"inc":       func(i int) int { return i + 1 },
+		}
+		t := texttemplate.Must(texttemplate.New("proto").Funcs(funcs).Parse(proto))
+		return t.Execute(w, map[string]interface{}{"Package": protoPackage, "Entities": entities})
+	case "codec":
+		var view []map[string]interface{}
+		for _, e := range entities {
+			columns, err := codecOf(e)
+			if err != nil {
+				return err
+			}
+			view = append(view, map[string]interface{}{"Name": e.Name, "Columns": columns})
+		}
+		var b bytes.Buffer
+		t := texttemplate.Must(texttemplate.New("codec").Parse(codec))
+		if err := t.Execute(&b, map[string]interface{}{"Package": protoPackage, "Entities": view}); err != nil {
+			return err
+		}
+		src, err := gofmt.Source(b.Bytes())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(src)
+		return err
+	case "markdown", "md":
+		t := texttemplate.Must(texttemplate.New("markdown").Funcs(texttemplate.FuncMap{"join": join}).Parse(markdown))
+		return t.Execute(w, entities)
+	case "dot", "plantuml":
+		diagram := map[string]string{"dot": dot, "plantuml": plantuml}[format]
+		t := texttemplate.Must(texttemplate.New(format).Parse(diagram))
+		return t.Execute(w, entities)
+	case "openapi":
+		schemas := map[string]interface{}{}
+		for _, e := range entities {
+			schema := map[string]interface{}{}
+			for k, v := range e.Schema {
+				if k != "$schema" {
+					schema[k] = v
+				}
+			}
+			schemas[e.Name] = schema
+		}
+		data, err := json.MarshalIndent(map[string]interface{}{"components": map[string]interface{}{"schemas": schemas}}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case "html":
+		t := htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap{"join": join}).Parse(html))
+		return t.Execute(w, entities)
+	}
+	return fmt.Errorf("Unknown format %s", format)
+}
+
+func main() {
+	format := flag.String("format", "markdown", "Output format: markdown, html, dot, plantuml, openapi, proto or codec")
+	flag.StringVar(&protoPackage, "package", protoPackage, "Package of the generated .proto file or codecs")
+	flag.Parse()
+
+	var data []byte
+	var err error
+	if flag.NArg() > 0 {
+		data, err = ioutil.ReadFile(flag.Arg(0))
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var entities []orm.EntityMetadata
+	if err := json.Unmarshal(data, &entities); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid metadata:", err)
+		os.Exit(1)
+	}
+	if err := render(os.Stdout, entities, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
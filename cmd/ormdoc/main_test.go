@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/arner/orm"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var entities = []orm.EntityMetadata{{
+	Name:    "User",
+	Table:   "User",
+	Columns: []orm.ColumnMetadata{{Name: "Country", Type: "STRING"}, {Name: "Id", Type: "INT64", Key: true}},
+	Indexes: []orm.IndexMetadata{{Name: "country", Table: "User_idx_country", Columns: []string{"Country"}}},
+	Policy:  map[string][]string{"read": {"any"}},
+}}
+
+func TestMarkdown(t *testing.T) {
+	var b bytes.Buffer
+	if err := render(&b, entities, "markdown"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"## User", "| Id | INT64 | yes |", "- country (Country)", "- read: any"} {
+		if !strings.Contains(b.String(), s) {
+			t.Fatalf("Markdown should contain %q:\n%s", s, b.String())
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var b bytes.Buffer
+	if err := render(&b, entities, "html"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<td>Country</td><td>STRING</td>") {
+		t.Fatal(b.String())
+	}
+	if err := render(&b, entities, "pdf"); err == nil {
+		t.Fatal("Unknown formats should be rejected")
+	}
+}
+
+func TestDiagrams(t *testing.T) {
+	model := append(entities, orm.EntityMetadata{
+		Name:    "Order",
+		Table:   "Order",
+		Columns: []orm.ColumnMetadata{{Name: "UserId", Type: "INT64", References: "User"}, {Name: "Id", Type: "INT64", Key: true}},
+	})
+	var b bytes.Buffer
+	if err := render(&b, model, "dot"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"Order" -> "User" [label="UserId"];`) {
+		t.Fatal(b.String())
+	}
+	b.Reset()
+	if err := render(&b, model, "plantuml"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Order }o--|| User : UserId") || !strings.Contains(b.String(), "entity User {") {
+		t.Fatal(b.String())
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	model := []orm.EntityMetadata{{Name: "User", Schema: map[string]interface{}{"$schema": "draft-04", "type": "object"}}}
+	var b bytes.Buffer
+	if err := render(&b, model, "openapi"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"User": {`) || strings.Contains(b.String(), "draft-04") {
+		t.Fatal(b.String())
+	}
+}
+
+func TestProto(t *testing.T) {
+	var b bytes.Buffer
+	if err := render(&b, entities, "proto"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "message User {\n  string Country = 1;\n  int64 Id = 2;\n}") {
+		t.Fatal(b.String())
+	}
+	model := []orm.EntityMetadata{{Name: "Bad", Columns: []orm.ColumnMetadata{{Name: "X", Type: "FLOAT"}}}}
+	if err := render(&b, model, "proto"); err == nil {
+		t.Fatal("Unknown column types should be rejected")
+	}
+}
+
+var update = flag.Bool("update", false, "Write the generated codec of the orm tests")
+
+// Like TestGenerated of the orm tests, whose codec is generated into codec_gen_test.go
+type TestGeneratedStatus string
+
+type TestGenerated struct {
+	Name   string
+	Status TestGeneratedStatus
+	Small  int32
+	Count  uint32
+	Total  uint64
+	Open   bool
+	Data   []byte
+	orm.Saveable
+}
+
+func TestCodec(t *testing.T) {
+	orm.Register(&TestGenerated{})
+	var model []orm.EntityMetadata
+	for _, e := range orm.Metadata() {
+		if e.Name == "TestGenerated" {
+			// Declared in the orm tests instead of here
+			for i, c := range e.Columns {
+				e.Columns[i].GoType = strings.Replace(c.GoType, "main.", "orm_test.", 1)
+			}
+			model = append(model, e)
+		}
+	}
+	defer func(p string) { protoPackage = p }(protoPackage)
+	protoPackage = "orm_test"
+	var b bytes.Buffer
+	if err := render(&b, model, "codec"); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := ioutil.WriteFile(golden, b.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != string(data) {
+		t.Fatalf("%s is out of date, run go test -update:\n%s", golden, b.String())
+	}
+
+	bad := []orm.EntityMetadata{{Name: "Bad", Columns: []orm.ColumnMetadata{{Name: "X", Type: "UINT64", Field: "X"}}}}
+	if err := render(&b, bad, "codec"); err == nil {
+		t.Fatal("Fields with an encoding of their own should be rejected")
+	}
+	bad[0].Columns[0].GoType = "other.Amount"
+	if err := render(&b, bad, "codec"); err == nil {
+		t.Fatal("Types of other packages should be rejected")
+	}
+}
+
+// The generated codec of the orm tests
+const golden = "../../codec_gen_test.go"
@@ -0,0 +1,82 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Converts the items of one type to and from the columns of their rows without reflection, for hot entities where
+// the reflection shows up in the profile. The columns are those of the stored fields, in order; the schema version
+// and checksum columns are added and checked as usual. Transforms still run on top of a codec.
+type RowCodec struct {
+	Marshal   func(item BlockchainItemizer) ([]*shim.Column, error)
+	Unmarshal func(columns []*shim.Column, item BlockchainItemizer) error
+}
+
+var codecs = map[string]RowCodec{}
+
+// Register the codec of the items of the type of prototype, e.g. from an init function of generated code. Reads fall
+// back to reflection when the table was created with other columns, or when only some fields are projected.
+func RegisterCodec(prototype BlockchainItemizer, codec RowCodec) {
+	state.Lock()
+	defer state.Unlock()
+	codecs[reflect.TypeOf(prototype).Elem().Name()] = codec
+}
+
+// Get the codec of the items of type t, if one is registered
+func codecOf(t reflect.Type) (RowCodec, bool) {
+	state.RLock()
+	defer state.RUnlock()
+	codec, ok := codecs[t.Name()]
+	return codec, ok
+}
+
+// Get the columns of the fields of an item with its codec
+func marshalRow(codec RowCodec, t reflect.Type, v reflect.Value) ([]*shim.Column, error) {
+	columns, err := codec.Marshal(v.Addr().Interface().(BlockchainItemizer))
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not marshal "+t.Name())
+	}
+	if n := len(fieldsOf(t)); len(columns) != n {
+		return nil, errors.Errorf("Codec of %s returned %d columns instead of %d", t.Name(), len(columns), n)
+	}
+	return columns, nil
+}
+
+// Check whether a codec can decode the rows of a table: the table has exactly the columns of the fields of type t,
+// besides the schema version and checksum
+func decodesTable(tbl *shim.Table, t reflect.Type, extra int) bool {
+	fields := fieldsOf(t)
+	if len(tbl.ColumnDefinitions) != len(fields)+extra {
+		return false
+	}
+	for i, f := range fields {
+		if tbl.ColumnDefinitions[i].Name != f.column {
+			return false
+		}
+	}
+	return true
+}
+
+// Get the Go type of fields of type t if they are stored as they are, by their kind, so a generated codec can copy
+// them; or "" if the type has an encoding of its own. Follows createColumnValue.
+func plainGoType(t reflect.Type) string {
+	if isValuer(t) || t == timeType || isNullable(t) || isFloat(t) {
+		return ""
+	}
+	if _, builtin := columnDefinitions[t.Name()]; !builtin && (isText(t) || isBinary(t)) {
+		return ""
+	}
+	if isBytes(t) && t.Name() == "" {
+		return "[]byte"
+	}
+	if isBytes(t) {
+		return t.String()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int32, reflect.Int64, reflect.String, reflect.Uint32, reflect.Uint64:
+		return t.String()
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+// Code generated by ormdoc -format codec; DO NOT EDIT.
+
+package orm_test
+
+import (
+	"github.com/arner/orm"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func init() {
+	orm.RegisterCodec(&TestGenerated{}, orm.RowCodec{Marshal: marshalTestGenerated, Unmarshal: unmarshalTestGenerated})
+}
+
+func marshalTestGenerated(item orm.BlockchainItemizer) ([]*shim.Column, error) {
+	v := item.(*TestGenerated)
+	return []*shim.Column{
+		{Value: &shim.Column_String_{String_: v.Name}},
+		{Value: &shim.Column_String_{String_: string(v.Status)}},
+		{Value: &shim.Column_Int32{Int32: v.Small}},
+		{Value: &shim.Column_Uint32{Uint32: v.Count}},
+		{Value: &shim.Column_Uint64{Uint64: v.Total}},
+		{Value: &shim.Column_Bool{Bool: v.Open}},
+		{Value: &shim.Column_Bytes{Bytes: v.Data}},
+		{Value: &shim.Column_Int64{Int64: v.Id}},
+	}, nil
+}
+
+func unmarshalTestGenerated(columns []*shim.Column, item orm.BlockchainItemizer) error {
+	v := item.(*TestGenerated)
+	v.Name = columns[0].GetString_()
+	v.Status = TestGeneratedStatus(columns[1].GetString_())
+	v.Small = columns[2].GetInt32()
+	v.Count = columns[3].GetUint32()
+	v.Total = columns[4].GetUint64()
+	v.Open = columns[5].GetBool()
+	v.Data = columns[6].GetBytes()
+	v.Id = columns[7].GetInt64()
+	return nil
+}
@@ -0,0 +1,77 @@
+package orm_test
+
+import (
+	"github.com/arner/orm"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"reflect"
+	"testing"
+)
+
+type TestGeneratedStatus string
+
+// The codec of TestGenerated is generated by cmd/ormdoc into codec_gen_test.go
+type TestGenerated struct {
+	Name   string
+	Status TestGeneratedStatus
+	Small  int32
+	Count  uint32
+	Total  uint64
+	Open   bool
+	Data   []byte
+	orm.Saveable
+}
+
+// The same fields without a codec, written with reflection
+type TestReflected struct {
+	Name   string
+	Status TestGeneratedStatus
+	Small  int32
+	Count  uint32
+	Total  uint64
+	Open   bool
+	Data   []byte
+	orm.Saveable
+}
+
+func TestGeneratedCodec(t *testing.T) {
+	stub := shim.NewMockStub("cc", nil)
+	stub.MockTransactionStart("test")
+	generated := TestGenerated{Name: "a", Status: "open", Small: -3, Count: 4, Total: 1 << 40, Open: true, Data: []byte{1, 2}}
+	reflected := TestReflected(generated)
+	for _, item := range []orm.BlockchainItemizer{&generated, &reflected} {
+		if err := orm.CreateTable(stub, item); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.Create(stub, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := []shim.Column{{Value: &shim.Column_Int64{Int64: 1}}}
+	coded, err := stub.GetRow("TestGenerated", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := stub.GetRow("TestReflected", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(row.Columns) == 0 || !reflect.DeepEqual(coded.Columns, row.Columns) {
+		t.Fatalf("The generated codec should write the row that reflection writes: %v, not %v", row, coded)
+	}
+	if columns, err := marshalTestGenerated(&generated); err != nil || !reflect.DeepEqual(columns, row.Columns) {
+		t.Fatalf("The generated marshaler should return the columns of the row: %v, not %v", row.Columns, columns)
+	}
+	var unmarshaled TestGenerated
+	if err := unmarshalTestGenerated(row.Columns, &unmarshaled); err != nil || !reflect.DeepEqual(unmarshaled, generated) {
+		t.Fatalf("The generated unmarshaler should set the fields of the row: %+v, not %+v", generated, unmarshaled)
+	}
+
+	var read TestGenerated
+	if err := orm.Get(stub, &read, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, generated) {
+		t.Fatalf("The generated codec should read the item that was written: %+v, not %+v", generated, read)
+	}
+}
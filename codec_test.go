@@ -0,0 +1,63 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestCoded struct {
+	Name string
+	Saveable
+}
+
+// A codec like generated code would register, counting its calls
+func testCodec(marshaled, unmarshaled *int) RowCodec {
+	return RowCodec{
+		Marshal: func(item BlockchainItemizer) ([]*shim.Column, error) {
+			*marshaled++
+			c := item.(*TestCoded)
+			return []*shim.Column{
+				{Value: &shim.Column_String_{String_: c.Name}},
+				{Value: &shim.Column_Int64{Int64: c.Id}},
+			}, nil
+		},
+		Unmarshal: func(columns []*shim.Column, item BlockchainItemizer) error {
+			*unmarshaled++
+			c := item.(*TestCoded)
+			c.Name, c.Id = columns[0].GetString_(), columns[1].GetInt64()
+			return nil
+		},
+	}
+}
+
+func TestRowCodec(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	marshaled, unmarshaled := 0, 0
+	RegisterCodec(new(TestCoded), testCodec(&marshaled, &unmarshaled))
+	defer delete(codecs, "TestCoded")
+
+	if err := CreateTable(stub, new(TestCoded)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestCoded{Name: "coded"}); err != nil {
+		fail(t, err)
+	}
+	var item TestCoded
+	if err := Get(stub, &item, 1); err != nil || item.Name != "coded" || item.Id != 1 {
+		fail(t, "Items should be read with the codec")
+	}
+	if marshaled != 1 || unmarshaled == 0 {
+		fail(t, "The codec should be used instead of reflection")
+	}
+
+	// Projections fall back to reflection
+	calls := unmarshaled
+	var items []TestCoded
+	if err := GetAll(stub, &items, WithProjection("Name")); err != nil || len(items) != 1 || items[0].Name != "coded" {
+		fail(t, "Projected items should be read")
+	}
+	if unmarshaled != calls {
+		fail(t, "Projections should not use the codec")
+	}
+}
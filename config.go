@@ -0,0 +1,197 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"sync"
+	"unicode"
+)
+
+// Generates the id of a new item in a table
+type IdStrategy func(stub shim.ChaincodeStubInterface, table string) (int64, error)
+
+// Names the table of the type of item, e.g. to partition tables by channel or epoch. The item is a prototype: only its
+// type is meaningful, since items are read by the name before their values are known.
+type TableNameResolver func(item BlockchainItemizer, stub shim.ChaincodeStubInterface) string
+
+// Logs the operations of the package. A *shim.ChaincodeLogger satisfies it.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Package-wide settings, set once at initialization of the chaincode with Configure. The zero value of every field
+// is the default behavior.
+type Config struct {
+	// Generates the ids of created items. Defaults to MaxIdPlusOne; NextId keeps a counter per table.
+	IdStrategy IdStrategy
+	// What to do with stored columns that don't have a field in the struct. Defaults to SkipUnknownColumns.
+	UnknownColumns UnknownColumnPolicy
+	// Check that the columns of a table match the fields of the struct, in order and by type, before reading its
+	// rows. Returns a *SchemaMismatchError that lists the differences, instead of reading the columns that match.
+	StrictSchema bool
+	// Rows read ahead of a scan while the previous rows are decoded. 0 reads a row when it is needed.
+	Prefetch int
+	// Set a chaincode event <Table>.Created, <Table>.Updated or <Table>.Deleted with the item as JSON on every write.
+	// Fabric keeps only the last event of a transaction.
+	EventEmission bool
+	// Defaults to a shim logger named orm
+	Logger Logger
+	// Prefix of the names of all tables, e.g. to keep the tables of several applications in one chaincode apart.
+	// Only letters, digits and underscores are allowed: CreateTable and Associate return an error for other
+	// characters.
+	Namespace string
+	// Sort the results of GetAll by the field tagged `sort:"true"`, or by id. The order of the rows of a table is
+	// not guaranteed, and endorsements of the same call fail when their results are in a different order.
+	SortResults bool
+	// Check operations for risks of different results on different endorsers, like unsorted results, map fields and
+	// scans stopped by MaxDuration. Use it while developing and testing.
+	Determinism DeterminismAudit
+	// Tells the time of the transaction, e.g. for attestations. Defaults to TxClock.
+	Clock Clock
+	// What to do with values longer than the size in their tag. Defaults to RejectOversized.
+	Sizes SizePolicy
+	// Names the tables instead of the name of their type. The Namespace is put before the name it returns. Index
+	// tables are named after it. Metadata, which has no stub, uses the names of the types.
+	TableNameResolver TableNameResolver
+}
+
+var config = Config{}
+
+// Guards the configuration and the registries of the package, like the registered entities, policies and
+// transformers. They are declared at initialization of the chaincode, but a chaincode container can run transactions
+// concurrently, and CreateTable registers its entity.
+var state sync.RWMutex
+
+var defaultLogger = shim.NewLogger("orm")
+
+// Replace the package-wide configuration. Override a setting for a single operation with an Option, e.g.
+// WithNamespace.
+func Configure(c Config) {
+	state.Lock()
+	defer state.Unlock()
+	config = c
+}
+
+// Get the package-wide configuration
+func currentConfig() Config {
+	state.RLock()
+	defer state.RUnlock()
+	return config
+}
+
+// Logs to the Logger of the configuration, so Configure can replace it while other transactions log
+type configuredLogger struct{}
+
+func (configuredLogger) current() Logger {
+	if l := currentConfig().Logger; l != nil {
+		return l
+	}
+	return defaultLogger
+}
+
+func (l configuredLogger) Debugf(format string, args ...interface{}) {
+	l.current().Debugf(format, args...)
+}
+
+func (l configuredLogger) Infof(format string, args ...interface{}) {
+	l.current().Infof(format, args...)
+}
+
+func (l configuredLogger) Warningf(format string, args ...interface{}) {
+	l.current().Warningf(format, args...)
+}
+
+func (l configuredLogger) Errorf(format string, args ...interface{}) {
+	l.current().Errorf(format, args...)
+}
+
+// The default IdStrategy: one higher than the highest id in the table. All rows are read, so it gets slower as the
+// table grows.
+func MaxIdPlusOne(stub shim.ChaincodeStubInterface, table string) (int64, error) {
+	return generateId(stub, table)
+}
+
+// Use a different IdStrategy for this operation
+func WithIdStrategy(strategy IdStrategy) Option {
+	return func(o *options) {
+		o.config.IdStrategy = strategy
+	}
+}
+
+// Turn StrictSchema on or off for this operation
+func WithStrictSchema(strict bool) Option {
+	return func(o *options) {
+		o.config.StrictSchema = strict
+	}
+}
+
+// Turn EventEmission on or off for this operation
+func WithEventEmission(emit bool) Option {
+	return func(o *options) {
+		o.config.EventEmission = emit
+	}
+}
+
+// Turn SortResults on or off for this operation
+func WithSortedResults(sorted bool) Option {
+	return func(o *options) {
+		o.config.SortResults = sorted
+	}
+}
+
+// Use a different Namespace for this operation
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.config.Namespace = namespace
+	}
+}
+
+// Use a different TableNameResolver for this operation
+func WithTableNameResolver(resolver TableNameResolver) Option {
+	return func(o *options) {
+		o.config.TableNameResolver = resolver
+	}
+}
+
+// Get the name of the table of type t
+func tableName(t reflect.Type, o *options) string {
+	if resolver := o.conf().TableNameResolver; resolver != nil && o.stub != nil {
+		return o.conf().Namespace + resolver(reflect.New(t).Interface().(BlockchainItemizer), o.stub)
+	}
+	return o.conf().Namespace + t.Name()
+}
+
+// Check that the Namespace has only letters, digits and underscores, which every table name can hold
+func checkNamespace(o *options) error {
+	for _, r := range o.conf().Namespace {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return errors.Errorf("Invalid namespace %q: only letters, digits and underscores are allowed", o.conf().Namespace)
+		}
+	}
+	return nil
+}
+
+// Generate an id for a new item with the configured IdStrategy
+func nextId(stub shim.ChaincodeStubInterface, table string, o *options) (int64, error) {
+	if strategy := o.conf().IdStrategy; strategy != nil {
+		return strategy(stub, table)
+	}
+	return MaxIdPlusOne(stub, table)
+}
+
+// Set the event of a write when EventEmission is on
+func emitEvent(stub shim.ChaincodeStubInterface, table, operation string, item BlockchainItemizer, o *options) error {
+	if !o.conf().EventEmission {
+		return nil
+	}
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrap(err, "Could not marshal event payload")
+	}
+	return stub.SetEvent(table+"."+operation, payload)
+}
@@ -0,0 +1,261 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type TestUnsupported struct {
+	Tags []string
+	Saveable
+}
+
+// Records the events that are set
+type eventStub struct {
+	*shim.MockStub
+	events map[string][]byte
+}
+
+func (s *eventStub) SetEvent(name string, payload []byte) error {
+	s.events[name] = payload
+	return nil
+}
+
+type countingLogger struct {
+	*shim.ChaincodeLogger
+	infos int
+}
+
+func (l *countingLogger) Infof(format string, args ...interface{}) {
+	l.infos++
+}
+
+func TestNamespace(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	defer Configure(Config{})
+
+	Configure(Config{Namespace: "app1_"})
+	checkCreateIndexed(t, stub, "NL")
+	if _, err := stub.GetTable("app1_TestIndexed_idx_country"); err != nil {
+		fail(t, "Index table should be namespaced")
+	}
+
+	// The same type in another namespace has its own table
+	if err := CreateTable(stub, new(TestIndexed), WithNamespace("app2_")); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestIndexed{Country: "BE"}, WithNamespace("app2_")); err != nil {
+		fail(t, err)
+	}
+	var items []TestIndexed
+	if err := GetAll(stub, &items, WithNamespace("app2_")); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 1 || items[0].Country != "BE" || items[0].Id != 1 {
+		fail(t, "Namespaces should be separate")
+	}
+	if ok, _ := HasColumn(stub, new(TestIndexed), "Country"); !ok {
+		fail(t, "HasColumn should use the namespace")
+	}
+}
+
+func TestInvalidNamespace(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	defer Configure(Config{})
+
+	checkErrorContains(t, CreateTable(stub, new(TestIndexed), WithNamespace("app-1.")), "Invalid namespace")
+	Configure(Config{Namespace: "app 1"})
+	checkErrorContains(t, CreateTable(stub, new(TestIndexed)), "Invalid namespace")
+	if _, err := stub.GetTable("app 1TestIndexed"); err == nil {
+		fail(t, "No table should be created in an invalid namespace")
+	}
+	Configure(Config{Namespace: "app_1"})
+	if err := CreateTable(stub, new(TestIndexed)); err != nil {
+		fail(t, err)
+	}
+}
+
+func TestTableNameResolver(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("epoch1")
+	defer Configure(Config{})
+
+	byEpoch := func(item BlockchainItemizer, stub shim.ChaincodeStubInterface) string {
+		return stub.GetTxID() + "_" + reflect.TypeOf(item).Elem().Name()
+	}
+	Configure(Config{Namespace: "app_", TableNameResolver: byEpoch})
+	checkCreateIndexed(t, stub, "NL")
+	if _, err := stub.GetTable("app_epoch1_TestIndexed_idx_country"); err != nil {
+		fail(t, "Index table should be named after the resolved name")
+	}
+	var item TestIndexed
+	if err := Get(stub, &item, 1); err != nil || item.Country != "NL" {
+		fail(t, "The item should be read from the resolved table")
+	}
+
+	stub.MockTransactionStart("epoch2")
+	if err := Get(stub, &item, 1, WithTableNameResolver(nil)); err == nil {
+		fail(t, "The table of the type should not exist")
+	}
+	checkCreateIndexed(t, stub, "BE")
+	var items []TestIndexed
+	if err := GetAll(stub, &items); err != nil || len(items) != 1 || items[0].Country != "BE" {
+		fail(t, "The epochs should have their own tables")
+	}
+}
+
+func TestIdStrategy(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	fixed := func(stub shim.ChaincodeStubInterface, table string) (int64, error) {
+		return 42, nil
+	}
+	item := TestStruct{Str: "a"}
+	if err := Create(stub, &item, WithIdStrategy(fixed)); err != nil {
+		fail(t, err)
+	}
+	if item.Id != 42 {
+		fail(t, "Id should be generated by the strategy")
+	}
+	item = TestStruct{Str: "b"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if item.Id != 43 {
+		fail(t, "Id should be generated by MaxIdPlusOne by default")
+	}
+}
+
+func TestUnsupportedFields(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+
+	err := CreateTable(stub, new(TestUnsupported))
+	if errors.Cause(err) != ErrUnsupportedField {
+		fail(t, "Fields that cannot be stored should fail")
+	}
+	if e, ok := err.(*UnsupportedFieldError); !ok || len(e.Fields) != 1 || e.Fields[0] != "Tags []string" {
+		fail(t, "The fields that cannot be stored should be listed")
+	}
+	checkErrorContains(t, Create(stub, &TestUnsupported{}), "Tags")
+
+	type TestSkipped struct {
+		Tags []string `orm:"-"`
+		Saveable
+	}
+	if err := CreateTable(stub, new(TestSkipped)); err != nil {
+		fail(t, err)
+	}
+	item := &TestSkipped{Tags: []string{"a"}}
+	if err := Create(stub, item); err != nil {
+		fail(t, err)
+	}
+	stored := &TestSkipped{}
+	if err := Get(stub, stored, item.Id); err != nil || stored.Tags != nil {
+		fail(t, "Skipped fields should not be stored")
+	}
+}
+
+func TestEventEmission(t *testing.T) {
+	mock := shim.NewMockStub("cc", new(MockChaincode))
+	mock.MockTransactionStart("test")
+	stub := &eventStub{MockStub: mock, events: map[string][]byte{}}
+	checkCreateTable(t, stub)
+
+	item := TestStruct{Str: "a"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if len(stub.events) != 0 {
+		fail(t, "Events should be off by default")
+	}
+
+	Configure(Config{EventEmission: true})
+	defer Configure(Config{})
+	item = TestStruct{Str: "b"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	var created TestStruct
+	if err := json.Unmarshal(stub.events["TestStruct.Created"], &created); err != nil {
+		fail(t, err)
+	}
+	if created.Id != 2 || created.Str != "b" {
+		fail(t, "Event should hold the item")
+	}
+	if err := Delete(stub, &item, WithEventEmission(false)); err != nil {
+		fail(t, err)
+	}
+	if _, ok := stub.events["TestStruct.Deleted"]; ok {
+		fail(t, "Event should not be emitted when overridden")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	l := &countingLogger{ChaincodeLogger: shim.NewLogger("test")}
+	Configure(Config{Logger: l})
+	defer Configure(Config{})
+
+	checkCreateTable(t, stub)
+	if l.infos == 0 {
+		fail(t, "Configured logger should be used")
+	}
+}
+
+// Run with go test -race: transactions run concurrently with each other and with registrations
+func TestConcurrentUse(t *testing.T) {
+	defer Configure(Config{})
+	defer delete(policies, "TestIndexed")
+	defer delete(transformers, "concurrent")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mock := shim.NewMockStub("cc", new(MockChaincode))
+			mock.MockTransactionStart(fmt.Sprint("test", i))
+			stub := NewSession(mock)
+			if err := CreateTable(stub, new(TestIndexed)); err != nil {
+				errs <- err
+				return
+			}
+			item := &TestIndexed{Name: "Name", Country: "NL"}
+			if err := Create(stub, item); err != nil {
+				errs <- err
+				return
+			}
+			var items []TestIndexed
+			if err := Find(stub, &items, Where("Country", "=", "NL")); err != nil || len(items) != 1 {
+				errs <- fmt.Errorf("Concurrent find: %v", err)
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		Configure(Config{EventEmission: true})
+		Register(new(TestStruct))
+		RegisterTransformer("concurrent", Transformer{Write: stringTransform(strings.ToUpper)})
+		Policy(new(TestIndexed)).AllowRead(AnyCaller).AllowCreate(AnyCaller)
+		Metadata()
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		fail(t, err)
+	}
+}
@@ -0,0 +1,63 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"reflect"
+)
+
+// Count the items whose field has a value. If the field has its own index and the table has no policy, only the
+// index is read; the items are not read or decoded.
+func CountBy(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, field string, value interface{}, opts ...Option) (int, error) {
+	return countBy(stub, prototype, field, value, 0, opts)
+}
+
+// Check whether there is an item whose field has a value, from the index of the field if it has one, like CountBy
+func ExistsBy(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, field string, value interface{}, opts ...Option) (bool, error) {
+	n, err := countBy(stub, prototype, field, value, 1, opts)
+	return n > 0, err
+}
+
+// Count the items whose field has a value, up to max if it is not 0
+func countBy(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, field string, value interface{}, max int, opts []Option) (int, error) {
+	if err := checkStub(stub, false); err != nil {
+		return 0, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return 0, err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	q := Where(field, "=", value)
+	if err := q.validate(t); err != nil {
+		return 0, err
+	}
+
+	o := newOptions(stub, opts)
+	idx, err := indexOn(t, field, o)
+	if err != nil {
+		return 0, err
+	} else if idx != nil && policyOf(t) == nil && storedExactly(idx.fields[0].Type, value) && idx.covers(value) {
+		ids, err := idx.lookup(stub, value)
+		if max > 0 && len(ids) > max {
+			return max, err
+		}
+		return len(ids), err
+	}
+
+	n := 0
+	WithProjection(field)(o)
+	err = scan(stub, t, o, func(item interface{}) error {
+		if ok, err := q.matches(item); err != nil {
+			return err
+		} else if ok {
+			n++
+		}
+		if max > 0 && n >= max {
+			return errStopScan
+		}
+		return nil
+	})
+	if err == errStopScan {
+		err = nil
+	}
+	return n, err
+}
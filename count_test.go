@@ -0,0 +1,31 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestCountBy(t *testing.T) {
+	stub := &countingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode)), table: "TestIndexed"}
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "NL")
+	stub.rows = 0
+
+	if n, err := CountBy(stub, &TestIndexed{}, "Country", "NL"); err != nil || n != 2 {
+		fail(t, "The items with the value should be counted")
+	}
+	if ok, err := ExistsBy(stub, &TestIndexed{}, "Country", "DE"); err != nil || ok {
+		fail(t, "There should be no item with a missing value")
+	}
+	if stub.rows != 0 {
+		fail(t, "Counting by an indexed field should only read the index")
+	}
+
+	// Without an index, the table is scanned
+	if ok, err := ExistsBy(stub, &TestIndexed{}, "Name", ""); err != nil || !ok || stub.rows != 1 {
+		fail(t, "Unindexed fields should be counted from the table")
+	}
+	if _, err := CountBy(stub, &TestIndexed{}, "Nope", 1); err == nil {
+		fail(t, "Unknown fields should fail")
+	}
+}
@@ -0,0 +1,64 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Create all items of a slice, e.g. for a bulk load. The ids are allocated once before the items are inserted: with
+// the default IdStrategy they are a block after the highest id, so the table is read once instead of for every item.
+// Other strategies are called for every item. The ids are set in the slice. When an item fails, the error tells its
+// position and the items before it are written; return the error from the invocation, so nothing is committed.
+func CreateAll(stub shim.ChaincodeStubInterface, items interface{}, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkSlice(items); err != nil {
+		return errors.Wrap(err, "Object passed to CreateAll should be a pointer to a slice")
+	}
+	v := reflect.ValueOf(items).Elem()
+	if v.Len() == 0 {
+		return nil
+	}
+	o := newOptions(stub, opts)
+	ids, err := reserveIds(stub, tableName(v.Type().Elem(), o), v.Len(), o)
+	if err != nil {
+		return errors.Wrap(err, "Generate id failed.")
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		id := ids[i]
+		reserved := func(stub shim.ChaincodeStubInterface, table string) (int64, error) {
+			return id, nil
+		}
+		itemOpts := append(append([]Option{}, opts...), WithIdStrategy(reserved))
+		if err := Create(stub, v.Index(i).Addr().Interface().(BlockchainItemizer), itemOpts...); err != nil {
+			return errors.Wrapf(err, "Could not create item %d of %d", i+1, v.Len())
+		}
+	}
+	return nil
+}
+
+// Get n ids for new items of a table
+func reserveIds(stub shim.ChaincodeStubInterface, table string, n int, o *options) ([]int64, error) {
+	ids := make([]int64, n)
+	if o.conf().IdStrategy == nil {
+		first, err := MaxIdPlusOne(stub, table)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ids {
+			ids[i] = first + int64(i)
+		}
+		return ids, nil
+	}
+	for i := range ids {
+		id, err := nextId(stub, table, o)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
@@ -0,0 +1,51 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+// Counts the scans of a table
+type scanCountingStub struct {
+	*shim.MockStub
+	scans int
+}
+
+func (s *scanCountingStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	if tableName == "TestStruct" && len(key) == 0 {
+		s.scans++
+	}
+	return s.MockStub.GetRows(tableName, key)
+}
+
+func TestCreateAll(t *testing.T) {
+	stub := &scanCountingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode))}
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a")
+	stub.scans = 0
+
+	items := []TestStruct{getTestStruct(), getTestStruct(), getTestStruct()}
+	if err := CreateAll(stub, &items); err != nil {
+		fail(t, err)
+	}
+	if items[0].Id != 2 || items[2].Id != 4 {
+		fail(t, "The items should get a block of ids")
+	}
+	if stub.scans != 1 {
+		fail(t, "The table should be read once for the ids")
+	}
+	var all []TestStruct
+	if err := GetAll(stub, &all); err != nil || len(all) != 4 {
+		fail(t, "All items should be created")
+	}
+
+	// With another strategy, ids are generated for every item
+	Configure(Config{IdStrategy: NextId})
+	defer Configure(Config{})
+	items = []TestStruct{getTestStruct(), getTestStruct()}
+	if err := CreateAll(stub, &items); err != nil || items[0].Id != 5 || items[1].Id != 6 {
+		fail(t, "The strategy should give the ids")
+	}
+	checkErrorContains(t, CreateAll(stub, items), "Pass a pointer to a slice")
+}
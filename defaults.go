@@ -0,0 +1,45 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Get an item by id, or a copy of defaultItem when there is no such item, e.g. for a table of settings where a
+// missing row means the default. The copy gets the id, so it can be saved with Save.
+func GetOrDefault(stub shim.ChaincodeStubInterface, item BlockchainItemizer, id int64, defaultItem BlockchainItemizer, opts ...Option) error {
+	if err := checkItem(defaultItem); err != nil {
+		return err
+	}
+	if reflect.TypeOf(defaultItem) != reflect.TypeOf(item) {
+		return errors.Errorf("Default should be a %v, not a %v", reflect.TypeOf(item), reflect.TypeOf(defaultItem))
+	}
+	value := reflect.New(reflect.TypeOf(item).Elem())
+	err := Get(stub, value.Interface().(BlockchainItemizer), id, opts...)
+	if errors.Cause(err) == ErrNotFound {
+		value.Elem().Set(reflect.ValueOf(defaultItem).Elem())
+		value.Interface().(BlockchainItemizer).SetId(id)
+	} else if err != nil {
+		return err
+	}
+	reflect.ValueOf(item).Elem().Set(value.Elem())
+	return nil
+}
+
+// Get the items with the given ids into a slice, in the order of the ids, with a copy of defaultItem for every id
+// without an item
+func GetAllOrDefault(stub shim.ChaincodeStubInterface, items interface{}, ids []int64, defaultItem BlockchainItemizer, opts ...Option) error {
+	if err := checkSlice(items); err != nil {
+		return errors.Wrap(err, "Object passed to GetAllOrDefault should be a pointer to a slice")
+	}
+	v := reflect.ValueOf(items).Elem()
+	for _, id := range ids {
+		item := reflect.New(v.Type().Elem())
+		if err := GetOrDefault(stub, item.Interface().(BlockchainItemizer), id, defaultItem, opts...); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, item.Elem()))
+	}
+	return nil
+}
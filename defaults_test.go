@@ -0,0 +1,34 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestGetOrDefault(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "stored")
+
+	defaultItem := &TestStruct{Str: "default"}
+	var item TestStruct
+	if err := GetOrDefault(stub, &item, 1, defaultItem); err != nil || item.Str != "stored" {
+		fail(t, "A stored item should be read")
+	}
+	if err := GetOrDefault(stub, &item, 5, defaultItem); err != nil || item.Str != "default" || item.Id != 5 {
+		fail(t, "A missing item should get the default with its id")
+	}
+	if defaultItem.Id != 0 {
+		fail(t, "The default should not change")
+	}
+
+	var items []TestStruct
+	if err := GetAllOrDefault(stub, &items, []int64{3, 1}, defaultItem); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 2 || items[0].Str != "default" || items[0].Id != 3 || items[1].Str != "stored" {
+		fail(t, "The items should be read in the order of the ids, with defaults")
+	}
+	checkErrorContains(t, GetOrDefault(stub, &item, 1, &TestIndexed{}), "Default should be a *orm.TestStruct")
+}
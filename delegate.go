@@ -0,0 +1,314 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Prefix of the functions a data chaincode handles with HandleDelegated
+const DelegatePrefix = "orm."
+
+// Route the tables of a stub to the chaincode that owns them, so several business chaincodes can share one schema:
+//
+// err := orm.Create(orm.DelegateWrites(stub, "data"), &user)
+//
+// Writes are sent with InvokeChaincode and reads with QueryChaincode. The data chaincode passes these calls to
+// HandleDelegated. Use the result like a stub, e.g. wrap it in a Session.
+func DelegateWrites(stub shim.ChaincodeStubInterface, chaincodeName string) shim.ChaincodeStubInterface {
+	return &delegatingStub{ChaincodeStubInterface: stub, chaincode: chaincodeName}
+}
+
+// Check whether the arguments of an invocation are a call of DelegateWrites
+func IsDelegated(args [][]byte) bool {
+	return len(args) > 0 && strings.HasPrefix(string(args[0]), DelegatePrefix)
+}
+
+// Handle a call of DelegateWrites in the chaincode that owns the tables, from Invoke and Query:
+//
+// if orm.IsDelegated(stub.GetArgs()) {
+//   return orm.HandleDelegated(stub, stub.GetArgs())
+// }
+//
+// Check the caller before, e.g. with a Policy; every chaincode that can invoke the data chaincode can write its tables.
+func HandleDelegated(stub shim.ChaincodeStubInterface, args [][]byte) ([]byte, error) {
+	if !IsDelegated(args) || len(args) < 2 {
+		return nil, errors.New("Not a delegated call")
+	}
+	function, table := strings.TrimPrefix(string(args[0]), DelegatePrefix), string(args[1])
+	var arg []byte
+	if len(args) > 2 {
+		arg = args[2]
+	}
+	logger.Debugf("Delegated %s on %s", function, table)
+
+	switch function {
+	case "CreateTable":
+		var defs []wireDefinition
+		if err := json.Unmarshal(arg, &defs); err != nil {
+			return nil, errors.Wrap(err, "Invalid column definitions")
+		}
+		return nil, stub.CreateTable(table, fromWireDefinitions(defs))
+	case "GetTable":
+		tbl, err := stub.GetTable(table)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(toWireDefinitions(tbl.ColumnDefinitions))
+	case "DeleteTable":
+		return nil, stub.DeleteTable(table)
+	case "InsertRow", "ReplaceRow":
+		var row []wireColumn
+		if err := json.Unmarshal(arg, &row); err != nil {
+			return nil, errors.Wrap(err, "Invalid row")
+		}
+		var ok bool
+		var err error
+		if function == "InsertRow" {
+			ok, err = stub.InsertRow(table, shim.Row{Columns: fromWireRow(row)})
+		} else {
+			ok, err = stub.ReplaceRow(table, shim.Row{Columns: fromWireRow(row)})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(ok)
+	case "GetRow", "GetRows", "DeleteRow":
+		var key []wireColumn
+		if err := json.Unmarshal(arg, &key); err != nil {
+			return nil, errors.Wrap(err, "Invalid key")
+		}
+		switch function {
+		case "GetRow":
+			row, err := stub.GetRow(table, fromWireKey(key))
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(toWireRow(row.Columns))
+		case "GetRows":
+			rows, err := stub.GetRows(table, fromWireKey(key))
+			if err != nil {
+				return nil, err
+			}
+			var all [][]wireColumn
+			for row := range rows {
+				all = append(all, toWireRow(row.Columns))
+			}
+			return json.Marshal(all)
+		default:
+			return nil, stub.DeleteRow(table, fromWireKey(key))
+		}
+	}
+	return nil, errors.New("Unknown delegated function " + function)
+}
+
+// A stub whose table operations are handled by another chaincode
+type delegatingStub struct {
+	shim.ChaincodeStubInterface
+	chaincode string
+}
+
+func (s *delegatingStub) invoke(write bool, function, table string, arg interface{}) ([]byte, error) {
+	args := [][]byte{[]byte(DelegatePrefix + function), []byte(table)}
+	if arg != nil {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, data)
+	}
+	var result []byte
+	var err error
+	if write {
+		result, err = s.ChaincodeStubInterface.InvokeChaincode(s.chaincode, args)
+	} else {
+		result, err = s.ChaincodeStubInterface.QueryChaincode(s.chaincode, args)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Delegated %s on %s failed", function, table)
+	}
+	return result, nil
+}
+
+func (s *delegatingStub) CreateTable(name string, columnDefinitions []*shim.ColumnDefinition) error {
+	_, err := s.invoke(true, "CreateTable", name, toWireDefinitions(columnDefinitions))
+	return err
+}
+
+func (s *delegatingStub) GetTable(tableName string) (*shim.Table, error) {
+	result, err := s.invoke(false, "GetTable", tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+	var defs []wireDefinition
+	if err := json.Unmarshal(result, &defs); err != nil {
+		return nil, errors.Wrap(err, "Invalid table definition")
+	}
+	return &shim.Table{Name: tableName, ColumnDefinitions: fromWireDefinitions(defs)}, nil
+}
+
+func (s *delegatingStub) DeleteTable(tableName string) error {
+	_, err := s.invoke(true, "DeleteTable", tableName, nil)
+	return err
+}
+
+func (s *delegatingStub) InsertRow(tableName string, row shim.Row) (bool, error) {
+	return s.writeRow("InsertRow", tableName, row)
+}
+
+func (s *delegatingStub) ReplaceRow(tableName string, row shim.Row) (bool, error) {
+	return s.writeRow("ReplaceRow", tableName, row)
+}
+
+func (s *delegatingStub) writeRow(function, tableName string, row shim.Row) (bool, error) {
+	result, err := s.invoke(true, function, tableName, toWireRow(row.Columns))
+	if err != nil {
+		return false, err
+	}
+	var ok bool
+	return ok, json.Unmarshal(result, &ok)
+}
+
+func (s *delegatingStub) GetRow(tableName string, key []shim.Column) (shim.Row, error) {
+	result, err := s.invoke(false, "GetRow", tableName, toWireKey(key))
+	if err != nil {
+		return shim.Row{}, err
+	}
+	var row []wireColumn
+	if err := json.Unmarshal(result, &row); err != nil {
+		return shim.Row{}, errors.Wrap(err, "Invalid row")
+	}
+	return shim.Row{Columns: fromWireRow(row)}, nil
+}
+
+func (s *delegatingStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	result, err := s.invoke(false, "GetRows", tableName, toWireKey(key))
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]wireColumn
+	if err := json.Unmarshal(result, &rows); err != nil {
+		return nil, errors.Wrap(err, "Invalid rows")
+	}
+	c := make(chan shim.Row, len(rows))
+	for _, row := range rows {
+		c <- shim.Row{Columns: fromWireRow(row)}
+	}
+	close(c)
+	return c, nil
+}
+
+func (s *delegatingStub) DeleteRow(tableName string, key []shim.Column) error {
+	_, err := s.invoke(true, "DeleteRow", tableName, toWireKey(key))
+	return err
+}
+
+// A column definition as sent between chaincodes
+type wireDefinition struct {
+	Name string
+	Type int32
+	Key  bool
+}
+
+func toWireDefinitions(cds []*shim.ColumnDefinition) []wireDefinition {
+	defs := make([]wireDefinition, 0, len(cds))
+	for _, cd := range cds {
+		defs = append(defs, wireDefinition{Name: cd.Name, Type: int32(cd.Type), Key: cd.Key})
+	}
+	return defs
+}
+
+func fromWireDefinitions(defs []wireDefinition) []*shim.ColumnDefinition {
+	var cds []*shim.ColumnDefinition
+	for _, def := range defs {
+		cds = append(cds, &shim.ColumnDefinition{Name: def.Name, Type: shim.ColumnDefinition_Type(def.Type), Key: def.Key})
+	}
+	return cds
+}
+
+// A column value as sent between chaincodes. Exactly one field is set.
+type wireColumn struct {
+	Bool   *bool   `json:",omitempty"`
+	Bytes  *[]byte `json:",omitempty"`
+	Int32  *int32  `json:",omitempty"`
+	Int64  *int64  `json:",omitempty"`
+	String *string `json:",omitempty"`
+	Uint32 *uint32 `json:",omitempty"`
+	Uint64 *uint64 `json:",omitempty"`
+}
+
+func toWireColumn(c *shim.Column) wireColumn {
+	var w wireColumn
+	switch val := c.GetValue().(type) {
+	case *shim.Column_Bool:
+		w.Bool = &val.Bool
+	case *shim.Column_Bytes:
+		b := append([]byte{}, val.Bytes...)
+		w.Bytes = &b
+	case *shim.Column_Int32:
+		w.Int32 = &val.Int32
+	case *shim.Column_Int64:
+		w.Int64 = &val.Int64
+	case *shim.Column_String_:
+		w.String = &val.String_
+	case *shim.Column_Uint32:
+		w.Uint32 = &val.Uint32
+	case *shim.Column_Uint64:
+		w.Uint64 = &val.Uint64
+	}
+	return w
+}
+
+func fromWireColumn(w wireColumn) shim.Column {
+	switch {
+	case w.Bool != nil:
+		return shim.Column{Value: &shim.Column_Bool{Bool: *w.Bool}}
+	case w.Bytes != nil:
+		return shim.Column{Value: &shim.Column_Bytes{Bytes: *w.Bytes}}
+	case w.Int32 != nil:
+		return shim.Column{Value: &shim.Column_Int32{Int32: *w.Int32}}
+	case w.Int64 != nil:
+		return shim.Column{Value: &shim.Column_Int64{Int64: *w.Int64}}
+	case w.String != nil:
+		return shim.Column{Value: &shim.Column_String_{String_: *w.String}}
+	case w.Uint32 != nil:
+		return shim.Column{Value: &shim.Column_Uint32{Uint32: *w.Uint32}}
+	case w.Uint64 != nil:
+		return shim.Column{Value: &shim.Column_Uint64{Uint64: *w.Uint64}}
+	}
+	return shim.Column{}
+}
+
+func toWireRow(columns []*shim.Column) []wireColumn {
+	row := make([]wireColumn, 0, len(columns))
+	for _, c := range columns {
+		row = append(row, toWireColumn(c))
+	}
+	return row
+}
+
+func fromWireRow(row []wireColumn) []*shim.Column {
+	var columns []*shim.Column
+	for _, w := range row {
+		c := fromWireColumn(w)
+		columns = append(columns, &c)
+	}
+	return columns
+}
+
+func toWireKey(key []shim.Column) []wireColumn {
+	row := make([]wireColumn, 0, len(key))
+	for i := range key {
+		row = append(row, toWireColumn(&key[i]))
+	}
+	return row
+}
+
+func fromWireKey(row []wireColumn) []shim.Column {
+	var key []shim.Column
+	for _, w := range row {
+		key = append(key, fromWireColumn(w))
+	}
+	return key
+}
@@ -0,0 +1,68 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+// Owns the tables of other chaincodes
+type dataChaincode struct{}
+
+func (cc *dataChaincode) Init(stub shim.ChaincodeStubInterface) ([]byte, error) { return nil, nil }
+func (cc *dataChaincode) Invoke(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	return HandleDelegated(stub, stub.GetArgs())
+}
+func (cc *dataChaincode) Query(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	return HandleDelegated(stub, stub.GetArgs())
+}
+
+func TestDelegateWrites(t *testing.T) {
+	data := shim.NewMockStub("data", new(dataChaincode))
+	business := shim.NewMockStub("cc", new(MockChaincode))
+	business.MockPeerChaincode("data", data)
+	business.MockTransactionStart("test")
+	stub := DelegateWrites(business, "data")
+
+	checkCreateIndexed(t, stub, "NL", "BE")
+	item := TestIndexed{Saveable: Saveable{Id: 2}, Country: "DE", Name: "b"}
+	if err := Update(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if err := Delete(stub, &TestIndexed{Saveable: Saveable{Id: 1}}); err != nil {
+		fail(t, err)
+	}
+
+	// The tables are in the data chaincode
+	if _, err := business.GetTable("TestIndexed"); err == nil {
+		fail(t, "The business chaincode should have no tables")
+	}
+	var items []TestIndexed
+	if err := GetAll(data, &items); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 1 || items[0] != item {
+		fail(t, "The data chaincode should hold the written items")
+	}
+	if ids := checkIndexIds(t, data, "DE"); len(ids) != 1 || ids[0] != 2 {
+		fail(t, "The data chaincode should hold the indexes")
+	}
+
+	var read TestIndexed
+	if err := Get(stub, &read, 2); err != nil {
+		fail(t, err)
+	}
+	if read != item {
+		fail(t, "Reads should be delegated")
+	}
+	if _, err := HandleDelegated(data, [][]byte{[]byte("orm.Drop"), []byte("TestIndexed")}); err == nil {
+		fail(t, "Unknown functions should be rejected")
+	}
+}
+
+func TestDelegateBytes(t *testing.T) {
+	data := shim.NewMockStub("data", new(dataChaincode))
+	business := shim.NewMockStub("cc", new(MockChaincode))
+	business.MockPeerChaincode("data", data)
+	business.MockTransactionStart("test")
+	checkBytes(t, DelegateWrites(business, "data"))
+}
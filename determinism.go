@@ -0,0 +1,53 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// What to do when an operation may give different results on different endorsers
+type DeterminismAudit int
+
+const (
+	// Don't check
+	AuditOff DeterminismAudit = iota
+	// Log a warning
+	AuditLog
+	// Return ErrNondeterministic
+	AuditFail
+)
+
+// Returned when Determinism is AuditFail and an operation may give different results on different endorsers
+var ErrNondeterministic = errors.New("Operation may not be deterministic across endorsers.")
+
+// Report a risk of nondeterminism according to the configured audit mode
+func auditDeterminism(o *options, format string, args ...interface{}) error {
+	switch o.conf().Determinism {
+	case AuditLog:
+		logger.Warningf("Nondeterminism: "+format, args...)
+	case AuditFail:
+		return errors.Wrap(ErrNondeterministic, fmt.Sprintf(format, args...))
+	}
+	return nil
+}
+
+// Check the fields of an entity for types whose values are not stored or compared in a fixed order
+func auditFields(t reflect.Type, o *options) error {
+	if o.conf().Determinism == AuditOff {
+		return nil
+	}
+	for _, f := range fieldsOf(t) {
+		switch f.Type.Kind() {
+		case reflect.Map:
+			if err := auditDeterminism(o, "field %s of %s is a map, which has no fixed order", f.Name, t.Name()); err != nil {
+				return err
+			}
+		case reflect.Float32, reflect.Float64:
+			if err := auditDeterminism(o, "field %s of %s is a float, which may be rounded differently", f.Name, t.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
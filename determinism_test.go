@@ -0,0 +1,48 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+// A map stored as text
+type labels map[string]string
+
+func (l labels) MarshalText() ([]byte, error) {
+	return json.Marshal(map[string]string(l))
+}
+
+func (l *labels) UnmarshalText(text []byte) error {
+	return json.Unmarshal(text, (*map[string]string)(l))
+}
+
+type TestMapped struct {
+	Labels labels
+	Saveable
+}
+
+func TestDeterminismAudit(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	defer Configure(Config{})
+
+	Configure(Config{Determinism: AuditFail})
+	var items []TestStruct
+	if err := GetAll(stub, &items); errors.Cause(err) != ErrNondeterministic {
+		fail(t, "Unsorted GetAll should be reported")
+	}
+	if err := GetAll(stub, &items, WithSortedResults(true)); err != nil {
+		fail(t, err)
+	}
+	if err := CreateTable(stub, new(TestMapped)); errors.Cause(err) != ErrNondeterministic {
+		fail(t, "Map fields should be reported")
+	}
+
+	Configure(Config{Determinism: AuditLog})
+	if err := GetAll(stub, &items); err != nil {
+		fail(t, err)
+	}
+}
@@ -0,0 +1,174 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Kinds of problems found by DiagnoseKeys
+const (
+	// The columns of the row don't match the definition of the table
+	MalformedRow = "malformed row"
+	// More than one row has the same key values
+	DuplicateKey = "duplicate key"
+	// Reading the row by its key values gives no row or a different row, e.g. after a manual write of the state
+	MisplacedRow = "misplaced row"
+)
+
+// A problem with a row of a table, and how to repair it
+type KeyProblem struct {
+	Problem string
+	// The values of the key columns of the row, e.g. [5] for the item with id 5
+	Key []interface{}
+	// What RepairKeys does, or what has to be done by hand
+	Repair string
+	// RepairKeys can repair it
+	Repairable bool
+	row        shim.Row
+}
+
+func (p KeyProblem) String() string {
+	return fmt.Sprintf("%s %v: %s", p.Problem, p.Key, p.Repair)
+}
+
+// The result of DiagnoseKeys: the problems of a table, in the order of its rows
+type KeyReport struct {
+	Table    string
+	Rows     int
+	Problems []KeyProblem
+	// The problems RepairKeys repaired
+	Repaired int
+}
+
+// Scan the table of prototype for rows with the same key values and rows that can't be read by their key, which past
+// bugs or manual writes can leave behind. The report is a repair plan; nothing is written. Reading every row is
+// expensive; run it from a maintenance function.
+func DiagnoseKeys(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, opts ...Option) (*KeyReport, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	name := tableName(reflect.TypeOf(prototype).Elem(), newOptions(stub, opts))
+
+	report := &KeyReport{Table: name}
+	seen := map[string]bool{}
+	err := scanRows(stub, name, func(tbl *shim.Table, row shim.Row) error {
+		report.Rows++
+		if problem := checkColumns(tbl, row); problem != "" {
+			report.Problems = append(report.Problems, KeyProblem{Problem: MalformedRow, Key: keyValues(tbl, row),
+				Repair: problem + "; fix or delete the row by hand"})
+			return nil
+		}
+		key := keyValues(tbl, row)
+		if k := fmt.Sprint(key); seen[k] {
+			report.Problems = append(report.Problems, KeyProblem{Problem: DuplicateKey, Key: key,
+				Repair: "choose the row to keep and delete the others by hand"})
+			return nil
+		} else {
+			seen[k] = true
+		}
+
+		stored, err := stub.GetRow(name, keyColumns(tbl, row))
+		if err != nil {
+			return errors.Wrapf(err, "Could not get %s with key %v", name, key)
+		}
+		switch {
+		case len(stored.Columns) == 0:
+			report.Problems = append(report.Problems, KeyProblem{Problem: MisplacedRow, Key: key,
+				Repair: "insert the row under its key", Repairable: true, row: row})
+		case !reflect.DeepEqual(toWireRow(stored.Columns), toWireRow(row.Columns)):
+			report.Problems = append(report.Problems, KeyProblem{Problem: MisplacedRow, Key: key,
+				Repair: "another row is stored under its key; choose the row to keep by hand"})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range report.Problems {
+		logger.Warningf("%s: %v", name, p)
+	}
+	return report, nil
+}
+
+// Diagnose the keys of the table of prototype and repair what can be repaired without choosing between rows: misplaced
+// rows whose key is free are inserted under it. The copy under the wrong key stays, since the table API can't address
+// it. The other problems are left in the report.
+func RepairKeys(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, opts ...Option) (*KeyReport, error) {
+	report, err := DiagnoseKeys(stub, prototype, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range report.Problems {
+		if !p.Repairable {
+			continue
+		}
+		if ok, err := stub.InsertRow(report.Table, p.row); err != nil {
+			return report, errors.Wrapf(err, "Could not repair %s with key %v", report.Table, p.Key)
+		} else if ok {
+			report.Repaired++
+		}
+	}
+	return report, nil
+}
+
+// Check the number and types of the columns of a row. Returns a description of the first problem.
+func checkColumns(tbl *shim.Table, row shim.Row) string {
+	if len(row.Columns) != len(tbl.ColumnDefinitions) {
+		return fmt.Sprintf("%d columns instead of %d", len(row.Columns), len(tbl.ColumnDefinitions))
+	}
+	for i, cd := range tbl.ColumnDefinitions {
+		if t, ok := storedTypeOf(row.Columns[i]); !ok || t != cd.Type {
+			return fmt.Sprintf("column %s is not of type %s", cd.Name, cd.Type)
+		}
+	}
+	return ""
+}
+
+// Get the type of the value of a column
+func storedTypeOf(c *shim.Column) (shim.ColumnDefinition_Type, bool) {
+	if c == nil {
+		return 0, false
+	}
+	switch c.GetValue().(type) {
+	case *shim.Column_Bool:
+		return shim.ColumnDefinition_BOOL, true
+	case *shim.Column_Bytes:
+		return shim.ColumnDefinition_BYTES, true
+	case *shim.Column_Int32:
+		return shim.ColumnDefinition_INT32, true
+	case *shim.Column_Int64:
+		return shim.ColumnDefinition_INT64, true
+	case *shim.Column_String_:
+		return shim.ColumnDefinition_STRING, true
+	case *shim.Column_Uint32:
+		return shim.ColumnDefinition_UINT32, true
+	case *shim.Column_Uint64:
+		return shim.ColumnDefinition_UINT64, true
+	}
+	return 0, false
+}
+
+// Get the key columns of a row
+func keyColumns(tbl *shim.Table, row shim.Row) []shim.Column {
+	var key []shim.Column
+	for i, cd := range tbl.ColumnDefinitions {
+		if cd.Key && i < len(row.Columns) && row.Columns[i] != nil {
+			key = append(key, *row.Columns[i])
+		}
+	}
+	return key
+}
+
+// Get the values of the key columns of a row
+func keyValues(tbl *shim.Table, row shim.Row) []interface{} {
+	var values []interface{}
+	for _, c := range keyColumns(tbl, row) {
+		values = append(values, columnValue(&c))
+	}
+	return values
+}
@@ -0,0 +1,90 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"reflect"
+	"testing"
+)
+
+// Returns extra rows from GetRows, like rows stored under a key that doesn't match their values
+type strayRowsStub struct {
+	*shim.MockStub
+	rows []shim.Row
+}
+
+func (s *strayRowsStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	rows, err := s.MockStub.GetRows(tableName, key)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan shim.Row)
+	go func() {
+		for row := range rows {
+			c <- row
+		}
+		for _, row := range s.rows {
+			c <- row
+		}
+		close(c)
+	}()
+	return c, nil
+}
+
+func strayRow(t *testing.T, item TestStruct) shim.Row {
+	row, err := createRow(reflect.TypeOf(item), reflect.ValueOf(&item).Elem())
+	if err != nil {
+		fail(t, err)
+	}
+	return row
+}
+
+func TestDiagnoseKeys(t *testing.T) {
+	mock := shim.NewMockStub("cc", new(MockChaincode))
+	mock.MockTransactionStart("test")
+	checkCreateTable(t, mock)
+	checkCreateItems(t, mock, "a", "b")
+
+	report, err := DiagnoseKeys(mock, new(TestStruct))
+	if err != nil {
+		fail(t, err)
+	}
+	if report.Rows != 2 || len(report.Problems) != 0 {
+		fail(t, "A healthy table should have no problems")
+	}
+
+	stored := checkGet(t, mock)
+	changed, missing := stored, stored
+	changed.Str = "changed"
+	missing.Id = 7
+	stub := &strayRowsStub{MockStub: mock, rows: []shim.Row{
+		strayRow(t, stored),
+		strayRow(t, changed),
+		strayRow(t, missing),
+		{Columns: []*shim.Column{{Value: &shim.Column_Int64{Int64: 8}}}},
+	}}
+	report, err = DiagnoseKeys(stub, new(TestStruct))
+	if err != nil {
+		fail(t, err)
+	}
+	problems := []string{DuplicateKey, DuplicateKey, MisplacedRow, MalformedRow}
+	if report.Rows != 6 || len(report.Problems) != len(problems) {
+		fail(t, report.Problems)
+	}
+	for i, p := range report.Problems {
+		if p.Problem != problems[i] {
+			fail(t, "Unexpected problem "+p.String())
+		}
+	}
+	if report.Problems[2].Key[0] != int64(7) || !report.Problems[2].Repairable {
+		fail(t, "The misplaced row should be repairable")
+	}
+
+	report, err = RepairKeys(stub, new(TestStruct))
+	if err != nil || report.Repaired != 1 {
+		fail(t, "The misplaced row should be repaired")
+	}
+	var item TestStruct
+	if err := Get(mock, &item, 7); err != nil {
+		fail(t, err)
+	}
+}
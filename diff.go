@@ -0,0 +1,56 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// A field that differs between two versions of an item
+type Change struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Field, c.Old, c.New)
+}
+
+// Get the stored fields that differ between two items of the same type, in the order of the columns. Fields are
+// compared as they are stored, so times at the same instant in other locations are equal. Use it to write audit
+// messages, e.g. by comparing the result of Get with the item that is about to be updated.
+func Diff(a, b BlockchainItemizer) ([]Change, error) {
+	if err := checkItem(a); err != nil {
+		return nil, err
+	}
+	if err := checkItem(b); err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return nil, errors.Errorf("Cannot diff %v with %v", reflect.TypeOf(a).Elem(), reflect.TypeOf(b).Elem())
+	}
+
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var changes []Change
+	for _, f := range fieldsOf(va.Type()) {
+		old, new := va.FieldByIndex(f.Index).Interface(), vb.FieldByIndex(f.Index).Interface()
+		if !sameStoredValue(f, old, new) {
+			changes = append(changes, Change{Field: f.Name, Old: old, New: new})
+		}
+	}
+	return changes, nil
+}
+
+// Check whether two values of a field are stored as the same column
+func sameStoredValue(f field, a, b interface{}) bool {
+	ca, err := createColumnValue(f.StructField, a)
+	if err != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	cb, err := createColumnValue(f.StructField, b)
+	if err != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return reflect.DeepEqual(ca, cb)
+}
@@ -0,0 +1,51 @@
+package orm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	a := TestDeep{Name: "a", TestBase: TestBase{Owner: "alice"}}
+	b := a
+	b.Owner = "bob"
+	b.Id = 3
+
+	changes, err := Diff(&a, &b)
+	if err != nil {
+		fail(t, err)
+	}
+	if len(changes) != 2 {
+		fail(t, "Owner and Id should have changed")
+	}
+	if changes[0].Field != "Owner" || changes[0].Old != "alice" || changes[0].New != "bob" {
+		fail(t, "Change of Owner should hold the old and new value")
+	}
+	if changes[1].String() != "Id: 0 -> 3" {
+		fail(t, "Unexpected change "+changes[1].String())
+	}
+
+	if changes, _ := Diff(&a, &a); len(changes) != 0 {
+		fail(t, "An item should not differ from itself")
+	}
+	if _, err := Diff(&a, &TestStruct{}); err == nil {
+		fail(t, "Items of different types should not be compared")
+	}
+}
+
+type TestDated struct {
+	At time.Time
+	Saveable
+}
+
+func TestDiffTimes(t *testing.T) {
+	now := time.Now()
+	a, b := TestDated{At: now}, TestDated{At: now.UTC().Round(0)}
+	if changes, err := Diff(&a, &b); err != nil || len(changes) != 0 {
+		fail(t, "Times at the same instant should not differ")
+	}
+	b.At = now.Add(time.Nanosecond)
+	if changes, err := Diff(&a, &b); err != nil || len(changes) != 1 {
+		fail(t, "Times at different instants should differ")
+	}
+}
@@ -0,0 +1,78 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Get the unique values of a field of all items in a table, e.g. to fill a dropdown. If the field has its own index,
+// only the index is read. The values have the type of the field and are in no particular order.
+func Distinct(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, field string, opts ...Option) ([]interface{}, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	f, ok := t.FieldByName(field)
+	if !ok {
+		return nil, errors.New("Field " + field + " not found in " + t.Name())
+	}
+
+	o := newOptions(stub, opts)
+	idx, err := indexOn(t, field, o)
+	if err != nil {
+		return nil, err
+	} else if idx != nil && idx.where == nil && policyOf(t) == nil {
+		return distinctFromIndex(stub, *idx, f.Type)
+	}
+
+	var values []interface{}
+	seen := map[interface{}]bool{}
+	WithProjection(field)(o)
+	err = scan(stub, t, o, func(item interface{}) error {
+		val := reflect.ValueOf(item).Elem().FieldByIndex(f.Index).Interface()
+		// Values like byte slices and structs can't be map keys, their columns can
+		c, err := createColumnValue(f, val)
+		if err != nil {
+			return err
+		}
+		if key := distinctKey(&c); !seen[key] {
+			seen[key] = true
+			values = append(values, val)
+		}
+		return nil
+	})
+	return values, err
+}
+
+// Get a value of a column that can be compared and used as a map key
+func distinctKey(c *shim.Column) interface{} {
+	if b, ok := c.GetValue().(*shim.Column_Bytes); ok {
+		return string(b.Bytes)
+	}
+	return columnValue(c)
+}
+
+// Index rows are ordered by their key, so rows with the same value are next to each other
+func distinctFromIndex(stub shim.ChaincodeStubInterface, idx index, typ reflect.Type) ([]interface{}, error) {
+	logger.Debugf("Reading distinct values from index %s", idx.table)
+	var values []interface{}
+	var last interface{}
+	err := scanRows(stub, idx.table, func(tbl *shim.Table, row shim.Row) error {
+		key := distinctKey(row.Columns[0])
+		if len(values) == 0 || key != last {
+			// Decoded like the field, e.g. times and floats from the numbers that store them
+			val := reflect.New(typ).Elem()
+			if err := setValue(val, columnValue(row.Columns[0])); err != nil {
+				return errors.Wrap(err, "Could not read "+idx.table)
+			}
+			values = append(values, val.Interface())
+			last = key
+		}
+		return nil
+	})
+	return values, err
+}
@@ -0,0 +1,75 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+	"time"
+)
+
+func checkDistinct(t *testing.T, stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, field string, expected ...interface{}) {
+	values, err := Distinct(stub, prototype, field)
+	if err != nil {
+		fail(t, err)
+	}
+	if len(values) != len(expected) {
+		fail(t, "Not the right amount of distinct values")
+	}
+	found := map[interface{}]bool{}
+	for _, val := range values {
+		found[val] = true
+	}
+	for _, val := range expected {
+		if !found[val] {
+			fail(t, val)
+		}
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "b", "c")
+
+	checkDistinct(t, stub, &TestStruct{}, "Str", "a", "b", "c")
+	checkDistinct(t, stub, &TestStruct{}, "Bool", true)
+	if _, err := Distinct(stub, &TestStruct{}, "Unknown"); err == nil {
+		fail(t, "Distinct should fail with an unknown field")
+	}
+}
+
+func TestDistinctFromIndex(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "NL", "DE", "BE")
+
+	checkDistinct(t, stub, &TestIndexed{}, "Country", "NL", "BE", "DE")
+}
+
+type TestDistinctTypes struct {
+	At    time.Time `index:"at"`
+	Ratio float64   `index:"ratio"`
+	Data  []byte
+	Saveable
+}
+
+func TestDistinctDecodesValues(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestDistinctTypes{}); err != nil {
+		fail(t, err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, data := range []string{"a", "b", "a"} {
+		if err := Create(stub, &TestDistinctTypes{At: at, Ratio: 2.5, Data: []byte(data)}); err != nil {
+			fail(t, err)
+		}
+	}
+
+	checkDistinct(t, stub, &TestDistinctTypes{}, "At", at)
+	checkDistinct(t, stub, &TestDistinctTypes{}, "Ratio", 2.5)
+	values, err := Distinct(stub, &TestDistinctTypes{}, "Data")
+	if err != nil || len(values) != 2 || string(values[0].([]byte))+string(values[1].([]byte)) != "ab" {
+		fail(t, "Distinct byte slices should be found")
+	}
+}
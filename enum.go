@@ -0,0 +1,39 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Implemented by enum types, like type Status int32, to reject values that are not allowed before they are written.
+// Named types of bool, int32, int64, string, uint32 and uint64 are stored as their underlying type.
+type EnumValidator interface {
+	Valid() bool
+}
+
+var enumValidatorType = reflect.TypeOf((*EnumValidator)(nil)).Elem()
+
+// Check the values of the fields of an item whose type is an EnumValidator before it is written
+func checkEnums(item BlockchainItemizer) error {
+	v := reflect.ValueOf(item).Elem()
+	for _, f := range fieldsOf(v.Type()) {
+		var validator EnumValidator
+		if fv := v.FieldByIndex(f.Index); f.Type.Implements(enumValidatorType) {
+			validator = fv.Interface().(EnumValidator)
+		} else if reflect.PtrTo(f.Type).Implements(enumValidatorType) {
+			validator = fv.Addr().Interface().(EnumValidator)
+		} else {
+			continue
+		}
+		if !validator.Valid() {
+			value := v.FieldByIndex(f.Index).Interface()
+			// A Stringer names the value, e.g. from go generate stringer
+			if s, ok := value.(fmt.Stringer); ok {
+				return errors.Errorf("%s of %s is not a valid value: %s", f.Name, v.Type().Name(), s.String())
+			}
+			return errors.Errorf("%s of %s is not a valid value: %v", f.Name, v.Type().Name(), value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type testStatus int32
+
+const (
+	testOpen testStatus = iota + 1
+	testClosed
+)
+
+func (s testStatus) Valid() bool {
+	return s == testOpen || s == testClosed
+}
+
+func (s testStatus) String() string {
+	switch s {
+	case testOpen:
+		return "open"
+	case testClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+type testPriority uint64
+
+type TestTicket struct {
+	Status   testStatus `index:"status"`
+	Priority testPriority
+	Saveable
+}
+
+func TestEnums(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestTicket)); err != nil {
+		fail(t, err)
+	}
+	if typ, _ := ColumnType(stub, new(TestTicket), "Status"); typ != shim.ColumnDefinition_INT32 {
+		fail(t, "Enums should be stored as their underlying type")
+	}
+	if err := Create(stub, &TestTicket{Status: testClosed, Priority: 3}); err != nil {
+		fail(t, err)
+	}
+
+	var ticket TestTicket
+	if err := Get(stub, &ticket, 1); err != nil || ticket.Status != testClosed || ticket.Priority != 3 {
+		fail(t, "Enums should be read back")
+	}
+	if n, err := CountBy(stub, new(TestTicket), "Status", testClosed); err != nil || n != 1 {
+		fail(t, "Enums should be indexed")
+	}
+	checkErrorContains(t, Create(stub, &TestTicket{Status: 7}), "Status of TestTicket is not a valid value: unknown")
+}
@@ -0,0 +1,120 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+)
+
+// Events implement this interface to be stored in an append-only table. Embed a Saveable and return the
+// id of the aggregate (e.g. the account) the event belongs to.
+//
+// Events can only be added with Append; Update and Delete return ErrEventSourced.
+type EventSourcer interface {
+	BlockchainItemizer
+	GetAggregateId() int64
+}
+
+var ErrEventSourced = errors.New("Events are append-only and cannot be updated or deleted.")
+
+// Append an event to the table of its type. Ids are increasing, so they reflect the order of the events: the
+// IdStrategy should be the default MaxIdPlusOne or NextId.
+func Append(stub shim.ChaincodeStubInterface, event EventSourcer, opts ...Option) error {
+	if err := checkItem(event); err != nil {
+		return err
+	}
+	if strategy := newOptions(stub, opts).conf().IdStrategy; strategy != nil && !increasing(strategy) {
+		return errors.New("Events need increasing ids: use the default IdStrategy or NextId")
+	}
+	if event.GetId() != 0 {
+		return errors.New("Event has already been appended.")
+	}
+	if event.GetAggregateId() == 0 {
+		return errors.New("Event should belong to an aggregate with an id larger than 0")
+	}
+	return Create(stub, event, opts...)
+}
+
+// Replay all events of an aggregate in the order they were appended. Pass a pointer to the event type to
+// select the table; apply is called once for every event and can rebuild the state of the aggregate. Index the field
+// that holds the aggregate id, e.g. AccountId int64 `index:"account"`, to read only the events of the aggregate
+// instead of the whole table. When the limits of a session stop the read, nothing is replayed and the error wraps
+// ErrResultTruncated.
+func Replay(stub shim.ChaincodeStubInterface, event EventSourcer, aggregateId int64, apply func(EventSourcer) error, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if aggregateId == 0 {
+		return errors.New("Aggregate id should be larger than 0")
+	}
+	if err := checkItem(event); err != nil {
+		return err
+	}
+
+	var events eventsById
+	t := reflect.TypeOf(event).Elem()
+	o := newOptions(stub, opts)
+	var err error
+	if field := aggregateField(t, o); field != "" {
+		found := reflect.New(reflect.SliceOf(t))
+		err = Find(stub, found.Interface(), Where(field, "=", aggregateId), opts...)
+		for i := 0; i < found.Elem().Len(); i++ {
+			events = append(events, found.Elem().Index(i).Addr().Interface().(EventSourcer))
+		}
+	} else {
+		err = scan(stub, t, o, func(item interface{}) error {
+			if e := item.(EventSourcer); e.GetAggregateId() == aggregateId {
+				events = append(events, e)
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return errors.Wrap(err, "Could not read events")
+	}
+
+	// Rows are not returned in order of insertion
+	sort.Sort(events)
+
+	logger.Debugf("Replaying %d %vs for aggregate %d", len(events), t.Name(), aggregateId)
+	for _, e := range events {
+		if err := apply(e); err != nil {
+			return errors.Wrapf(err, "Could not apply event %d", e.GetId())
+		}
+	}
+	return nil
+}
+
+type eventsById []EventSourcer
+
+func (e eventsById) Len() int           { return len(e) }
+func (e eventsById) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e eventsById) Less(i, j int) bool { return e[i].GetId() < e[j].GetId() }
+
+// Check whether an IdStrategy gives out increasing ids
+func increasing(strategy IdStrategy) bool {
+	p := reflect.ValueOf(strategy).Pointer()
+	return p == reflect.ValueOf(MaxIdPlusOne).Pointer() || p == reflect.ValueOf(NextId).Pointer()
+}
+
+// Get the indexed field of event type t that holds the aggregate id, or "" if there is none
+func aggregateField(t reflect.Type, o *options) string {
+	indexes, err := indexesOf(t, o)
+	if err != nil {
+		return ""
+	}
+	// An id no event has, to find the field GetAggregateId returns
+	const probe = 1<<62 + 7
+	for _, idx := range indexes {
+		if len(idx.fields) != 1 || idx.where != nil || idx.fields[0].Type.Kind() != reflect.Int64 {
+			continue
+		}
+		e := reflect.New(t)
+		e.Elem().FieldByIndex(idx.fields[0].Index).SetInt(probe)
+		if e.Interface().(EventSourcer).GetAggregateId() == probe {
+			return idx.fields[0].Name
+		}
+	}
+	return ""
+}
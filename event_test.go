@@ -0,0 +1,132 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+type TestEvent struct {
+	AccountId int64
+	Amount    int64
+	Saveable
+}
+
+func (e *TestEvent) GetAggregateId() int64 { return e.AccountId }
+
+func checkCreateEventTable(t *testing.T, stub shim.ChaincodeStubInterface) {
+	if err := CreateTable(stub, &TestEvent{}); err != nil {
+		fail(t, err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateEventTable(t, stub)
+
+	e := TestEvent{AccountId: 1, Amount: 10}
+	if err := Append(stub, &e); err != nil {
+		fail(t, err)
+	}
+	if e.Id == 0 {
+		fail(t, "Id is not set")
+	}
+	if err := Append(stub, &e); err == nil {
+		fail(t, "Appending an event twice should fail")
+	}
+	if err := Append(stub, &TestEvent{Amount: 10}); err == nil {
+		fail(t, "Appending an event without aggregate should fail")
+	}
+}
+
+func TestUpdateEventShouldFail(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateEventTable(t, stub)
+
+	e := TestEvent{AccountId: 1, Amount: 10}
+	if err := Append(stub, &e); err != nil {
+		fail(t, err)
+	}
+	e.Amount = 20
+	if err := Update(stub, &e); err != ErrEventSourced {
+		fail(t, "Update of an event should fail")
+	}
+	if err := Delete(stub, &e); err != ErrEventSourced {
+		fail(t, "Delete of an event should fail")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateEventTable(t, stub)
+
+	for _, e := range []TestEvent{{AccountId: 1, Amount: 10}, {AccountId: 2, Amount: 5}, {AccountId: 1, Amount: -3}} {
+		if err := Append(stub, &e); err != nil {
+			fail(t, err)
+		}
+	}
+
+	balance := int64(0)
+	var ids []int64
+	err := Replay(stub, &TestEvent{}, 1, func(e EventSourcer) error {
+		balance += e.(*TestEvent).Amount
+		ids = append(ids, e.GetId())
+		return nil
+	})
+	if err != nil {
+		fail(t, err)
+	}
+	if balance != 7 {
+		fail(t, "Balance should be 7")
+	}
+	if len(ids) != 2 || ids[0] > ids[1] {
+		fail(t, "Events should be replayed in order")
+	}
+}
+
+type TestIndexedEvent struct {
+	AccountId int64 `index:"account"`
+	Amount    int64
+	Saveable
+}
+
+func (e *TestIndexedEvent) GetAggregateId() int64 { return e.AccountId }
+
+func TestReplayFromIndex(t *testing.T) {
+	stub := &countingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode)), table: "TestIndexedEvent"}
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestIndexedEvent{}); err != nil {
+		fail(t, err)
+	}
+	for _, e := range []TestIndexedEvent{{AccountId: 1, Amount: 10}, {AccountId: 2, Amount: 5}, {AccountId: 1, Amount: -3}} {
+		if err := Append(stub, &e); err != nil {
+			fail(t, err)
+		}
+	}
+	stub.rows = 0
+
+	var ids []int64
+	err := Replay(stub, &TestIndexedEvent{}, 1, func(e EventSourcer) error {
+		ids = append(ids, e.GetId())
+		return nil
+	})
+	if err != nil || len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		fail(t, "The events of the aggregate should be replayed in order")
+	}
+	if stub.rows != 0 {
+		fail(t, "Events should be read from the index of the aggregate id")
+	}
+
+	session := NewSession(stub)
+	session.MaxRows = 1
+	err = Replay(session, &TestIndexedEvent{}, 1, func(e EventSourcer) error { return nil })
+	if errors.Cause(err) != ErrResultTruncated {
+		fail(t, "A truncated replay should fail")
+	}
+
+	custom := WithIdStrategy(func(stub shim.ChaincodeStubInterface, table string) (int64, error) { return 100, nil })
+	checkErrorContains(t, Append(stub, &TestIndexedEvent{AccountId: 1}, custom), "Events need increasing ids")
+}
@@ -0,0 +1,66 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Up to this many ids, ExistAll reads each row by key; above it, it reads the table once
+const existByKeyLimit = 32
+
+// Check which of the ids have an item in the table of prototype, e.g. to validate the references sent by a client.
+// The result has an entry for every id.
+func ExistAll(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, ids []int64, opts ...Option) (map[int64]bool, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	name := tableName(reflect.TypeOf(prototype).Elem(), newOptions(stub, opts))
+
+	exists := map[int64]bool{}
+	for _, id := range ids {
+		exists[id] = false
+	}
+	if len(exists) == 0 {
+		return exists, nil
+	}
+
+	// Rows of a table that is not keyed by id alone can't be read by id
+	if len(exists) <= existByKeyLimit && keyedById(reflect.TypeOf(prototype).Elem()) {
+		for id := range exists {
+			row, err := stub.GetRow(name, []shim.Column{{Value: &shim.Column_Int64{Int64: id}}})
+			if err != nil {
+				return nil, errors.Wrapf(err, "Could not get %s with id %d", name, id)
+			}
+			exists[id] = len(row.Columns) > 0
+		}
+		return exists, nil
+	}
+
+	idColumn := -1
+	err := scanRows(stub, name, func(tbl *shim.Table, row shim.Row) error {
+		if idColumn == -1 {
+			for i, cd := range tbl.ColumnDefinitions {
+				if cd.Name == "Id" {
+					idColumn = i
+				}
+			}
+			if idColumn == -1 {
+				return errors.New("Table " + name + " has no Id column")
+			}
+		}
+		if id := row.Columns[idColumn].GetInt64(); !exists[id] {
+			if _, wanted := exists[id]; wanted {
+				exists[id] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return exists, nil
+}
@@ -0,0 +1,33 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestExistAll(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "c")
+
+	exists, err := ExistAll(stub, &TestStruct{}, []int64{1, 3, 4})
+	if err != nil {
+		fail(t, err)
+	}
+	if len(exists) != 3 || !exists[1] || !exists[3] || exists[4] {
+		fail(t, exists)
+	}
+
+	// More ids than are checked by key
+	var ids []int64
+	for id := int64(0); id < existByKeyLimit+10; id++ {
+		ids = append(ids, id)
+	}
+	if exists, err = ExistAll(stub, &TestStruct{}, ids); err != nil {
+		fail(t, err)
+	}
+	if len(exists) != len(ids) || exists[0] || !exists[1] || !exists[2] || !exists[3] || exists[4] {
+		fail(t, exists)
+	}
+}
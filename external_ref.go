@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Embed ExternalReferenced next to Saveable to identify items by the key of another system, like an ERP or a legacy
+// database, instead of the id generated on the chain:
+//
+// type Customer struct {
+//   Name string
+//   orm.Saveable
+//   orm.ExternalReferenced
+// }
+//
+// The reference is indexed and unique within the table; items without one are not constrained. Read items with
+// GetByExternalRef.
+type ExternalReferenced struct {
+	ExternalRef string `json:"externalRef,omitempty" index:"external_ref" unique:"{table}_external_ref"`
+}
+
+// Get an item by the reference of an external system, from the index on ExternalRef. Returns ErrNotFound if no item
+// has the reference.
+func GetByExternalRef(stub shim.ChaincodeStubInterface, item BlockchainItemizer, ref string, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	if ref == "" {
+		return errors.New("External reference is empty")
+	}
+	t := reflect.TypeOf(item).Elem()
+	o := newOptions(stub, opts)
+	idx, err := indexOn(t, "ExternalRef", o)
+	if err != nil {
+		return err
+	} else if idx == nil {
+		return errors.New(t.Name() + " has no ExternalRef; embed orm.ExternalReferenced")
+	}
+	ids, err := idx.lookup(stub, ref)
+	if err != nil {
+		return errors.Wrapf(err, "Could not look up %s %s", t.Name(), ref)
+	}
+	if len(ids) == 0 {
+		return ErrNotFound
+	}
+	return Get(stub, item, ids[0], opts...)
+}
@@ -0,0 +1,55 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestCustomer struct {
+	Name string
+	Saveable
+	ExternalReferenced
+}
+
+type TestSupplier struct {
+	Name string
+	Saveable
+	ExternalReferenced
+}
+
+func TestGetByExternalRef(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	for _, prototype := range []BlockchainItemizer{new(TestCustomer), new(TestSupplier)} {
+		if err := CreateTable(stub, prototype); err != nil {
+			fail(t, err)
+		}
+	}
+	for _, name := range []string{"acme", "globex"} {
+		c := &TestCustomer{Name: name}
+		c.ExternalRef = "ERP-" + name
+		if err := Create(stub, c); err != nil {
+			fail(t, err)
+		}
+	}
+
+	var c TestCustomer
+	if err := GetByExternalRef(stub, &c, "ERP-globex"); err != nil || c.Name != "globex" || c.Id != 2 {
+		fail(t, "Items should be found by their external reference")
+	}
+	if err := GetByExternalRef(stub, &c, "ERP-initech"); err != ErrNotFound {
+		fail(t, "Unknown references should not be found")
+	}
+
+	duplicate := &TestCustomer{Name: "acme 2"}
+	duplicate.ExternalRef = "ERP-acme"
+	checkErrorContains(t, Create(stub, duplicate), "already used")
+
+	// References are unique within a table only
+	s := &TestSupplier{Name: "acme"}
+	s.ExternalRef = "ERP-acme"
+	if err := Create(stub, s); err != nil {
+		fail(t, err)
+	}
+	checkErrorContains(t, GetByExternalRef(stub, &TestStruct{}, "ERP-acme"), "has no ExternalRef")
+}
@@ -0,0 +1,75 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// What to do when a stored row has a column without a matching field in the struct, e.g. because an older
+// version of the chaincode wrote a field that has since been removed.
+type UnknownColumnPolicy int
+
+const (
+	// Skip the column and log a warning
+	SkipUnknownColumns UnknownColumnPolicy = iota
+	// Put the value in the Extra field of the item. Falls back to skipping when the item has no Extra field.
+	CollectUnknownColumns
+	// Return an error
+	RejectUnknownColumns
+)
+
+// Add a field of type Extra to your struct to collect columns without a field when reading with
+// CollectUnknownColumns. The Extra field itself is not stored.
+type Extra map[string]interface{}
+
+var extraType = reflect.TypeOf(Extra{})
+
+// Handle a column value of a row that has no (settable) field in item v
+func unknownColumn(v reflect.Value, name string, value interface{}, o *options) error {
+	switch o.unknownColumnPolicy() {
+	case RejectUnknownColumns:
+		return errors.New("Column " + name + " has no field in " + v.Type().Name())
+	case CollectUnknownColumns:
+		if extra := extraField(v); extra.IsValid() {
+			if extra.IsNil() {
+				extra.Set(reflect.MakeMap(extraType))
+			}
+			extra.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(value))
+			return nil
+		}
+	}
+	logger.Warningf("Skipping column %s: no field in %s", name, v.Type().Name())
+	return nil
+}
+
+// Find the Extra field of an item. Returns the zero Value if there is none.
+func extraField(v reflect.Value) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Type() == extraType && f.CanSet() {
+			return f
+		}
+	}
+	return reflect.Value{}
+}
+
+// Get the value of a column as an interface
+func columnValue(c *shim.Column) interface{} {
+	switch val := c.GetValue().(type) {
+	case *shim.Column_Bool:
+		return val.Bool
+	case *shim.Column_Bytes:
+		return val.Bytes
+	case *shim.Column_Int32:
+		return val.Int32
+	case *shim.Column_Int64:
+		return val.Int64
+	case *shim.Column_String_:
+		return val.String_
+	case *shim.Column_Uint32:
+		return val.Uint32
+	case *shim.Column_Uint64:
+		return val.Uint64
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestExtraStruct struct {
+	Name  string
+	Extra Extra
+	Saveable
+}
+
+// Create a table with a column that TestExtraStruct doesn't have, and a row with id 1
+func checkCreateLegacyRow(t *testing.T, stub shim.ChaincodeStubInterface) {
+	cds := []*shim.ColumnDefinition{
+		{Name: "Name", Type: shim.ColumnDefinition_STRING},
+		{Name: "Removed", Type: shim.ColumnDefinition_INT64},
+		{Name: "Id", Type: shim.ColumnDefinition_INT64, Key: true},
+	}
+	if err := stub.CreateTable("TestExtraStruct", cds); err != nil {
+		fail(t, err)
+	}
+	row := shim.Row{Columns: []*shim.Column{
+		{Value: &shim.Column_String_{String_: "name"}},
+		{Value: &shim.Column_Int64{Int64: 7}},
+		{Value: &shim.Column_Int64{Int64: 1}},
+	}}
+	if _, err := stub.InsertRow("TestExtraStruct", row); err != nil {
+		fail(t, err)
+	}
+}
+
+func TestSkipUnknownColumns(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateLegacyRow(t, stub)
+
+	var s TestExtraStruct
+	if err := Get(stub, &s, 1); err != nil {
+		fail(t, err)
+	}
+	if s.Name != "name" || s.Extra != nil {
+		fail(t, "Unknown column should be skipped")
+	}
+}
+
+func TestCollectUnknownColumns(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateLegacyRow(t, stub)
+
+	Configure(Config{UnknownColumns: CollectUnknownColumns})
+	defer Configure(Config{})
+
+	var s TestExtraStruct
+	if err := Get(stub, &s, 1); err != nil {
+		fail(t, err)
+	}
+	if s.Name != "name" || s.Extra["Removed"] != int64(7) {
+		fail(t, "Unknown column should be collected")
+	}
+}
+
+func TestRejectUnknownColumns(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateLegacyRow(t, stub)
+
+	Configure(Config{UnknownColumns: RejectUnknownColumns})
+	defer Configure(Config{})
+
+	var s TestExtraStruct
+	if err := Get(stub, &s, 1); err == nil {
+		fail(t, "Unknown column should be rejected")
+	}
+}
+
+func TestExtraIsNotStored(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestExtraStruct{}); err != nil {
+		fail(t, err)
+	}
+	s := TestExtraStruct{Name: "name", Extra: Extra{"a": 1}}
+	if err := Create(stub, &s); err != nil {
+		fail(t, err)
+	}
+	var a TestExtraStruct
+	if err := Get(stub, &a, s.Id); err != nil {
+		fail(t, err)
+	}
+	if a.Name != "name" || a.Extra != nil {
+		fail(t, "Extra should not be stored")
+	}
+}
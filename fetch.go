@@ -0,0 +1,146 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"sync"
+)
+
+// Fetches all items of several tables at once:
+//
+// err := orm.Fetch(stub).Add(&users).Add(&orders).Run()
+//
+// The shim handles one request of a transaction at a time, so the tables are read one after another. The rows are
+// decoded concurrently by a bounded number of workers.
+type Fetcher struct {
+	stub    shim.ChaincodeStubInterface
+	targets []reflect.Value
+	workers int
+	opts    *options
+	err     error
+}
+
+// A row to decode into position index of the items of a target
+type fetchJob struct {
+	tbl    *shim.Table
+	row    shim.Row
+	target int
+	index  int
+	// The options to read the row with, once the schema of its table is checked
+	opts *options
+}
+
+// Start fetching from a stub. The options apply to all tables.
+func Fetch(stub shim.ChaincodeStubInterface, opts ...Option) *Fetcher {
+	return &Fetcher{stub: stub, workers: 4, opts: newOptions(stub, opts)}
+}
+
+// Add a pointer to a slice that will be filled with all items of its type
+func (f *Fetcher) Add(items interface{}) *Fetcher {
+	if err := checkSlice(items); err != nil {
+		f.err = errors.Wrap(err, "Object passed to Add should be a pointer to a slice")
+		return f
+	}
+	f.targets = append(f.targets, reflect.ValueOf(items).Elem())
+	return f
+}
+
+// Set the maximum number of rows that are decoded at the same time. Defaults to 4.
+func (f *Fetcher) Workers(n int) *Fetcher {
+	if n > 0 {
+		f.workers = n
+	}
+	return f
+}
+
+// Fetch the items of all added slices. If a scan exceeds the limits of the session, the other tables are still read
+// and ErrResultTruncated is returned.
+func (f *Fetcher) Run() error {
+	if f.err != nil {
+		return f.err
+	}
+	if err := checkStub(f.stub, false); err != nil {
+		return err
+	}
+
+	// Read all rows, one table at a time
+	var jobs []fetchJob
+	truncated := false
+	for i, target := range f.targets {
+		name := tableName(target.Type().Elem(), f.opts)
+		key, err := checksumKeyOf(f.stub, target.Type().Elem())
+		if err != nil {
+			return err
+		}
+		index := 0
+		var read *options
+		err = scanRows(f.stub, name, func(tbl *shim.Table, row shim.Row) error {
+			if err := verifyRow(key, tbl, row); err != nil {
+				return err
+			}
+			if read == nil {
+				if read, err = checkSchemaOnce(tbl, target.Type().Elem(), f.opts); err != nil {
+					return err
+				}
+			}
+			jobs = append(jobs, fetchJob{tbl: tbl, row: row, target: i, index: index, opts: read})
+			index++
+			return nil
+		})
+		if err == ErrResultTruncated {
+			truncated = true
+		} else if err != nil {
+			return errors.Wrap(err, "Could not fetch "+name)
+		}
+		target.Set(reflect.MakeSlice(target.Type(), index, index))
+	}
+
+	// Decode them concurrently
+	queue := make(chan fetchJob)
+	errs := make(chan error, len(jobs))
+	var wg sync.WaitGroup
+	for w := 0; w < f.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				item := f.targets[job.target].Index(job.index).Addr().Interface()
+				if err := setValues(job.tbl, job.row, item, job.opts); err != nil {
+					errs <- errors.Wrap(err, "Error setting values.")
+				}
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	// Leave out the items the caller may not read
+	for _, target := range f.targets {
+		authorizer, err := authorizerOf(f.stub, target.Type().Elem(), "read")
+		if err != nil {
+			return err
+		} else if authorizer == nil {
+			continue
+		}
+		readable := reflect.MakeSlice(target.Type(), 0, target.Len())
+		for i := 0; i < target.Len(); i++ {
+			if authorizer.check(target.Index(i).Addr().Interface()) == nil {
+				readable = reflect.Append(readable, target.Index(i))
+			}
+		}
+		target.Set(readable)
+	}
+	if truncated {
+		return ErrResultTruncated
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateEventTable(t, stub)
+	checkCreate(t, stub)
+	checkCreate(t, stub)
+	for i := int64(1); i <= 5; i++ {
+		if err := Append(stub, &TestEvent{AccountId: i, Amount: i}); err != nil {
+			fail(t, err)
+		}
+	}
+
+	var items []TestStruct
+	var events []TestEvent
+	if err := Fetch(stub).Add(&items).Add(&events).Workers(2).Run(); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 2 || len(events) != 5 {
+		fail(t, "Not the right amount of items fetched")
+	}
+	checkEqual(t, items[0], getTestStruct())
+	for _, e := range events {
+		if e.Id == 0 || e.Amount != e.AccountId {
+			fail(t, "Event not fetched correctly")
+		}
+	}
+}
+
+func TestFetchShouldFail(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	var items []TestStruct
+	if err := Fetch(stub).Add(items).Run(); err == nil {
+		fail(t, "Fetch should fail when not passing a pointer")
+	}
+	var events []TestEvent
+	if err := Fetch(stub).Add(&items).Add(&events).Run(); err == nil {
+		fail(t, "Fetch should fail when a table doesn't exist")
+	}
+}
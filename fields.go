@@ -0,0 +1,124 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A field of an entity that is stored in a column. The index of the StructField is the path from the entity, so
+// fields of anonymous structs (at any depth) can be accessed with FieldByIndex.
+type field struct {
+	reflect.StructField
+	key bool
+	// The name of the column, the name of the field unless it is tagged with orm:"column=<name>"
+	column string
+}
+
+// The fields of the types seen so far. The reflection is done once per type; Warm does it at initialization.
+var fieldCache = struct {
+	sync.RWMutex
+	fields map[reflect.Type][]field
+}{fields: map[reflect.Type][]field{}}
+
+// Get the fields of type t that are stored, in order. The fields of anonymous structs (like Saveable or your own
+// mixins) are flattened recursively, so an entity can be composed of several embedded structs. Anonymous structs that
+// are stored as a column themselves, like TextMarshalers, are not flattened. As in Go, a field hides the fields with
+// the same name deeper in the embedded structs; fields with the same name at the same depth are ambiguous and not
+// stored.
+func fieldsOf(t reflect.Type) []field {
+	fieldCache.RLock()
+	fields, ok := fieldCache.fields[t]
+	fieldCache.RUnlock()
+	if !ok {
+		fields = storedFieldsOf(t)
+		fieldCache.Lock()
+		fieldCache.fields[t] = fields
+		fieldCache.Unlock()
+	}
+	// A copy, so callers can't change the cache
+	return append([]field(nil), fields...)
+}
+
+func storedFieldsOf(t reflect.Type) []field {
+	all := embeddedFieldsOf(t)
+	depths := map[string]int{}
+	counts := map[string]int{}
+	for _, f := range all {
+		if depth, ok := depths[f.Name]; !ok || len(f.Index) < depth {
+			depths[f.Name] = len(f.Index)
+			counts[f.Name] = 1
+		} else if len(f.Index) == depth {
+			counts[f.Name]++
+		}
+	}
+	var fields []field
+	for _, f := range all {
+		if len(f.Index) == depths[f.Name] && counts[f.Name] == 1 {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Get the exported fields of type t and its anonymous structs, in order, including hidden fields
+func embeddedFieldsOf(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type == extraType {
+			continue
+		}
+		if f.Anonymous && isJSON(f.Type) {
+			for _, sub := range embeddedFieldsOf(f.Type) {
+				sub.Index = append([]int{i}, sub.Index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // Field not exported
+		}
+		skip, column := ormTagOf(f)
+		if skip {
+			continue
+		}
+		fields = append(fields, field{StructField: f, key: f.Tag.Get("key") == "true", column: column})
+	}
+	return fields
+}
+
+// Get the fields of type t that form the key of its table, in order
+func keyFieldsOf(t reflect.Type) []field {
+	var keys []field
+	for _, f := range fieldsOf(t) {
+		if f.key {
+			keys = append(keys, f)
+		}
+	}
+	return keys
+}
+
+// Read the orm tag of a field: `orm:"-"` leaves it out, `orm:"column=<name>"` stores it in a column with another name
+// than the field, so the column stays the same when the field is renamed.
+func ormTagOf(f reflect.StructField) (skip bool, column string) {
+	column = f.Name
+	for _, option := range strings.Split(f.Tag.Get("orm"), ",") {
+		option = strings.TrimSpace(option)
+		if option == "-" {
+			skip = true
+		} else if strings.HasPrefix(option, "column=") {
+			column = strings.TrimPrefix(option, "column=")
+		}
+	}
+	return skip, column
+}
+
+// Get the stored fields of type t by column name
+func fieldsByColumn(t reflect.Type) map[string]field {
+	fields := map[string]field{}
+	for _, f := range fieldsOf(t) {
+		fields[f.column] = f
+	}
+	return fields
+}
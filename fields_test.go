@@ -0,0 +1,136 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestBase struct {
+	Owner string `index:"owner"`
+	Saveable
+}
+
+type TestDeep struct {
+	Name string
+	TestBase
+}
+
+func TestDeepEmbedding(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestDeep{}); err != nil {
+		fail(t, err)
+	}
+	tbl, _ := stub.GetTable("TestDeep")
+	if len(tbl.ColumnDefinitions) != 3 || tbl.ColumnDefinitions[2].Name != "Id" || !tbl.ColumnDefinitions[2].Key {
+		fail(t, "Fields of embedded structs should be columns")
+	}
+
+	s := TestDeep{Name: "name"}
+	s.Owner = "owner"
+	if err := Create(stub, &s); err != nil {
+		fail(t, err)
+	}
+	if s.Id != 1 {
+		fail(t, "Id should be set")
+	}
+
+	var a TestDeep
+	if err := Get(stub, &a, 1); err != nil {
+		fail(t, err)
+	}
+	if a.Name != "name" || a.Owner != "owner" || a.Id != 1 {
+		fail(t, "Fields of embedded structs should be read")
+	}
+
+	owners, err := Distinct(stub, &TestDeep{}, "Owner")
+	if err != nil || len(owners) != 1 || owners[0] != "owner" {
+		fail(t, "Fields of embedded structs should be indexed")
+	}
+}
+
+type TestAuditable struct {
+	CreatedBy string
+	UpdatedBy string
+}
+
+type TestLabeled struct {
+	Label string
+	Note  string
+}
+
+type TestNoted struct {
+	Note string
+}
+
+type TestComposed struct {
+	Label string
+	TestAuditable
+	TestLabeled
+	TestNoted
+	Saveable
+}
+
+func TestMixins(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestComposed{}); err != nil {
+		fail(t, err)
+	}
+	tbl, _ := stub.GetTable("TestComposed")
+	var names []string
+	for _, cd := range tbl.ColumnDefinitions {
+		names = append(names, cd.Name)
+	}
+	if fmt.Sprint(names) != "[Label CreatedBy UpdatedBy Id]" {
+		fail(t, fmt.Sprintf("Mixins should be flattened, hidden and ambiguous fields skipped, got %v", names))
+	}
+
+	s := TestComposed{Label: "outer"}
+	s.CreatedBy = "alice"
+	s.TestLabeled.Label = "hidden"
+	if err := Create(stub, &s); err != nil {
+		fail(t, err)
+	}
+	var a TestComposed
+	if err := Get(stub, &a, 1); err != nil {
+		fail(t, err)
+	}
+	if a.Label != "outer" || a.CreatedBy != "alice" || a.TestLabeled.Label != "" {
+		fail(t, "Fields of mixins should be read")
+	}
+}
+
+type TestRenamed struct {
+	Owner string `orm:"column=owner_id"`
+	Saveable
+}
+
+func TestColumnNames(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestRenamed{}); err != nil {
+		fail(t, err)
+	}
+	if typ, err := ColumnType(stub, &TestRenamed{}, "owner_id"); err != nil || typ != shim.ColumnDefinition_STRING {
+		fail(t, "The field should be stored in the tagged column")
+	}
+	if err := Create(stub, &TestRenamed{Owner: "alice"}); err != nil {
+		fail(t, err)
+	}
+	var items []TestRenamed
+	if err := Find(stub, &items, Where("Owner", "=", "alice"), WithProjection("Owner")); err != nil || len(items) != 1 || items[0].Owner != "alice" {
+		fail(t, "Renamed fields should be read and queried by field name")
+	}
+	if maps, err := FindMaps(stub, &TestRenamed{}, Where("Owner", "=", "alice")); err != nil || len(maps) != 1 || maps[0]["Owner"] != "alice" {
+		fail(t, "Maps should have the values by field name")
+	}
+
+	type TestClashing struct {
+		Owner  string `orm:"column=owner"`
+		Holder string `orm:"column=owner"`
+		Saveable
+	}
+	checkErrorContains(t, CreateTable(stub, &TestClashing{}), "both stored in column owner")
+}
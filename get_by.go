@@ -0,0 +1,46 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+var ErrAmbiguous = errors.New("More than one item matches.")
+
+// Get the item whose fields have the given values, passed as pairs of a field name and a value:
+//
+// err := orm.GetBy(stub, &order, "Owner", "alice", "Status", "open")
+//
+// The item is read from the index whose leading fields have values, like a compound index on Owner and Status.
+// Returns ErrNotFound if no item matches, and ErrAmbiguous if more than one does.
+func GetBy(stub shim.ChaincodeStubInterface, item BlockchainItemizer, fieldsAndValues ...interface{}) error {
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	if len(fieldsAndValues) == 0 || len(fieldsAndValues)%2 != 0 {
+		return errors.New("Expected pairs of a field and a value")
+	}
+	q := new(Query)
+	for i := 0; i < len(fieldsAndValues); i += 2 {
+		field, ok := fieldsAndValues[i].(string)
+		if !ok {
+			return errors.Errorf("Expected a field name instead of %v", fieldsAndValues[i])
+		}
+		q.And(field, "=", fieldsAndValues[i+1])
+	}
+
+	t := reflect.TypeOf(item).Elem()
+	items := reflect.New(reflect.SliceOf(t))
+	if err := Find(stub, items.Interface(), q.Limit(2)); err != nil {
+		return err
+	}
+	switch items.Elem().Len() {
+	case 0:
+		return ErrNotFound
+	case 1:
+		reflect.ValueOf(item).Elem().Set(items.Elem().Index(0))
+		return nil
+	}
+	return ErrAmbiguous
+}
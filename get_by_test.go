@@ -0,0 +1,70 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestOwnedOrder struct {
+	Owner  string `index:"owner_status"`
+	Status string `index:"owner_status"`
+	Saveable
+}
+
+func TestGetBy(t *testing.T) {
+	stub := &countingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode)), table: "TestOwnedOrder"}
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestOwnedOrder{}); err != nil {
+		fail(t, err)
+	}
+	tbl, err := stub.GetTable("TestOwnedOrder_idx_owner_status")
+	if err != nil || len(tbl.ColumnDefinitions) != 3 {
+		fail(t, "The compound index should have a column for each field and the id")
+	}
+	for _, o := range []TestOwnedOrder{{Owner: "alice", Status: "open"}, {Owner: "alice", Status: "closed"}, {Owner: "bob", Status: "open"}, {Owner: "bob", Status: "open"}} {
+		if err := Create(stub, &o); err != nil {
+			fail(t, err)
+		}
+	}
+	stub.rows = 0
+
+	var order TestOwnedOrder
+	if err := GetBy(stub, &order, "Owner", "alice", "Status", "closed"); err != nil || order.Id != 2 {
+		fail(t, "The item with the values should be found")
+	}
+	var orders []TestOwnedOrder
+	if err := Find(stub, &orders, Where("Owner", "=", "alice")); err != nil || len(orders) != 2 {
+		fail(t, "The leading field of the index should be looked up")
+	}
+	if stub.rows != 0 {
+		fail(t, "Only the index should be scanned")
+	}
+
+	if err := GetBy(stub, &order, "Owner", "carol", "Status", "open"); err != ErrNotFound {
+		fail(t, "Missing items should not be found")
+	}
+	if err := GetBy(stub, &order, "Owner", "bob", "Status", "open"); err != ErrAmbiguous {
+		fail(t, "More than one match should be ambiguous")
+	}
+
+	// Updates move the item in the index
+	order = TestOwnedOrder{}
+	if err := Get(stub, &order, 1); err != nil {
+		fail(t, err)
+	}
+	order.Status = "closed"
+	if err := Update(stub, &order); err != nil {
+		fail(t, err)
+	}
+	if err := GetBy(stub, &order, "Owner", "alice", "Status", "open"); err != ErrNotFound {
+		fail(t, "The old values should be removed from the index")
+	}
+	orders = nil
+	if err := Find(stub, &orders, Where("Status", "=", "closed").And("Owner", "=", "alice")); err != nil || len(orders) != 2 {
+		fail(t, "The new values should be in the index")
+	}
+
+	if err := GetBy(stub, &order, "Owner"); err == nil {
+		fail(t, "A field without a value should fail")
+	}
+}
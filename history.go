@@ -0,0 +1,318 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+var keptVersions = map[string]bool{}
+
+var retentions = map[string]Retention{}
+
+// How long the versions of an item are kept. The zero Retention keeps all versions; the last version of an item is
+// always kept.
+type Retention struct {
+	// Keep only the last n versions of an item
+	Versions int
+	// Keep only the versions written in the last n days, by the time of their transaction
+	Days int
+}
+
+// The result of a pass of PurgeVersions
+type PurgeResult struct {
+	// Versions removed
+	Deleted int
+	// No expired versions are left. When false, run PurgeVersions again in a new transaction.
+	Done bool
+}
+
+// A stored version of an item
+type storedVersion struct {
+	id      int64
+	version uint64
+	written int64
+}
+
+// Keep every version of the items of the table of prototype in the table <Table>_versions, for products that need
+// to retrieve a version explicitly instead of going through the history of the ledger. Versions are numbered from 1
+// for the created item, with the id of the transaction that wrote them. Deleting an item deletes its versions, so an
+// item that gets its id again, e.g. from MaxIdPlusOne, doesn't inherit them. Declare it at initialization of the
+// chaincode, before the table is created.
+func KeepVersions(prototype BlockchainItemizer) {
+	state.Lock()
+	defer state.Unlock()
+	keptVersions[reflect.TypeOf(prototype).Elem().Name()] = true
+}
+
+// Check whether the versions of the items of type t are kept
+func keepsVersions(t reflect.Type) bool {
+	state.RLock()
+	defer state.RUnlock()
+	return keptVersions[t.Name()]
+}
+
+// Limit the versions kept of the items of the table of prototype, so the versions table doesn't grow without bound.
+// Expired versions of an item are removed when the item is written; remove those of items that are no longer written
+// with PurgeVersions. Versions written without a transaction time are not expired by age.
+func RetainVersions(prototype BlockchainItemizer, retention Retention) {
+	state.Lock()
+	defer state.Unlock()
+	retentions[reflect.TypeOf(prototype).Elem().Name()] = retention
+}
+
+// Get the retention of the versions of the items of type t
+func retentionOf(t reflect.Type) Retention {
+	state.RLock()
+	defer state.RUnlock()
+	return retentions[t.Name()]
+}
+
+// Get a version of an item, as written by Create or Update. Returns ErrNotFound if there is no such version.
+func GetVersion(stub shim.ChaincodeStubInterface, item BlockchainItemizer, id int64, version uint64, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	t := reflect.TypeOf(item).Elem()
+	if !keepsVersions(t) {
+		return errors.New("Versions of " + t.Name() + " are not kept")
+	}
+	o := newOptions(stub, opts).unprojected()
+	name := versionsTableName(tableName(t, o))
+
+	row, err := stub.GetRow(name, versionKey(id, version))
+	if err != nil {
+		return errors.Wrapf(err, "Could not get version %d of %d from %s", version, id, name)
+	}
+	if len(row.Columns) == 0 {
+		return errors.Wrapf(ErrNotFound, "Version %d of %s with id %d", version, tableName(t, o), id)
+	}
+	var snapshot rowSnapshot
+	if err := json.Unmarshal(row.Columns[4].GetBytes(), &snapshot); err != nil || len(snapshot.Rows) != 1 {
+		return errors.Errorf("Invalid version %d of %s with id %d", version, tableName(t, o), id)
+	}
+	tbl := &shim.Table{Name: snapshot.Table, ColumnDefinitions: fromWireDefinitions(snapshot.Columns)}
+	value := reflect.New(t)
+	if err := setValues(tbl, shim.Row{Columns: fromWireRow(snapshot.Rows[0])}, value.Interface(), o); err != nil {
+		return errors.Wrap(err, "Error setting values")
+	}
+	if err := authorize(stub, "read", value.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(item).Elem().Set(value.Elem())
+	return nil
+}
+
+// Remove the expired versions of all items of the table of prototype. It reads the whole versions table; with
+// WithChunkSize, a pass stops after that many deletes. Repeat it until the result is Done.
+func PurgeVersions(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, opts ...Option) (*PurgeResult, error) {
+	if err := checkStub(stub, true); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	if !keepsVersions(t) {
+		return nil, errors.New("Versions of " + t.Name() + " are not kept")
+	}
+	o := newOptions(stub, opts).unprojected()
+	name := versionsTableName(tableName(t, o))
+
+	var versions []storedVersion
+	last := map[int64]uint64{}
+	err := scanRows(stub, name, func(tbl *shim.Table, row shim.Row) error {
+		v := versionOf(row)
+		versions = append(versions, v)
+		if v.version > last[v.id] {
+			last[v.id] = v.version
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	expired, err := expiredVersions(stub, t, versions, last, o)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PurgeResult{Done: true}
+	for _, v := range expired {
+		if o.chunkSize > 0 && result.Deleted >= o.chunkSize {
+			result.Done = false
+			break
+		}
+		if err := stub.DeleteRow(name, versionKey(v.id, v.version)); err != nil {
+			return result, errors.Wrapf(err, "Could not delete version %d of %d from %s", v.version, v.id, name)
+		}
+		result.Deleted++
+	}
+	logger.Infof("Purged %d versions from %s", result.Deleted, name)
+	return result, nil
+}
+
+func versionsTableName(table string) string {
+	return table + "_versions"
+}
+
+func versionKey(id int64, version uint64) []shim.Column {
+	return []shim.Column{{Value: &shim.Column_Int64{Int64: id}}, {Value: &shim.Column_Uint64{Uint64: version}}}
+}
+
+func versionOf(row shim.Row) storedVersion {
+	return storedVersion{id: row.Columns[0].GetInt64(), version: row.Columns[1].GetUint64(), written: row.Columns[3].GetInt64()}
+}
+
+// Select the versions that are expired by the retention of type t, given the last version of every item
+func expiredVersions(stub shim.ChaincodeStubInterface, t reflect.Type, versions []storedVersion, last map[int64]uint64, o *options) ([]storedVersion, error) {
+	retention := retentionOf(t)
+	var oldest int64
+	if retention.Days > 0 {
+		at, err := now(stub, o)
+		if err != nil {
+			return nil, err
+		}
+		oldest = at.AddDate(0, 0, -retention.Days).Unix()
+	}
+	var expired []storedVersion
+	for _, v := range versions {
+		switch {
+		case v.version == last[v.id]:
+		case retention.Versions > 0 && v.version+uint64(retention.Versions) <= last[v.id]:
+			expired = append(expired, v)
+		case retention.Days > 0 && v.written > 0 && v.written < oldest:
+			expired = append(expired, v)
+		}
+	}
+	return expired, nil
+}
+
+// Create the versions table of type t, if its versions are kept
+func createVersionsTable(stub shim.ChaincodeStubInterface, t reflect.Type, o *options) error {
+	if !keepsVersions(t) {
+		return nil
+	}
+	name := versionsTableName(tableName(t, o))
+	logger.Infof("Create Table %s", name)
+	return stub.CreateTable(name, []*shim.ColumnDefinition{
+		{Name: "Id", Type: shim.ColumnDefinition_INT64, Key: true},
+		{Name: "Version", Type: shim.ColumnDefinition_UINT64, Key: true},
+		{Name: "TxId", Type: shim.ColumnDefinition_STRING},
+		{Name: "Written", Type: shim.ColumnDefinition_INT64},
+		{Name: "Row", Type: shim.ColumnDefinition_BYTES},
+	})
+}
+
+// Get the transaction that wrote the last version of an item, or "" if its versions are not kept
+func lastWriteOf(stub shim.ChaincodeStubInterface, t reflect.Type, id int64, o *options) (string, error) {
+	if !keepsVersions(t) {
+		return "", nil
+	}
+	var last uint64
+	var txId string
+	err := scanKey(stub, versionsTableName(tableName(t, o)), []shim.Column{{Value: &shim.Column_Int64{Int64: id}}}, func(tbl *shim.Table, row shim.Row) error {
+		if v := versionOf(row); v.version > last {
+			last, txId = v.version, row.Columns[2].GetString_()
+		}
+		return nil
+	})
+	return txId, err
+}
+
+// Delete the versions of a deleted item, if its versions are kept
+func deleteVersions(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(item).Elem()
+	if !keepsVersions(t) {
+		return nil
+	}
+	name := versionsTableName(tableName(t, o))
+	var versions []storedVersion
+	err := scanKey(stub, name, []shim.Column{{Value: &shim.Column_Int64{Int64: item.GetId()}}}, func(tbl *shim.Table, row shim.Row) error {
+		versions = append(versions, versionOf(row))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if err := stub.DeleteRow(name, versionKey(v.id, v.version)); err != nil {
+			return errors.Wrapf(err, "Could not delete version %d of %d from %s", v.version, v.id, name)
+		}
+	}
+	return nil
+}
+
+// Store the written row of an item as its next version, if its versions are kept, and remove its expired versions
+func recordVersion(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(item).Elem()
+	if !keepsVersions(t) {
+		return nil
+	}
+	table := tableName(t, o)
+	name := versionsTableName(table)
+	row, err := createRow(t, reflect.ValueOf(item).Elem())
+	if err != nil {
+		return err
+	}
+	if row, err = sealRow(stub, table, item, row); err != nil {
+		return err
+	}
+	tbl, err := stub.GetTable(table)
+	if err != nil {
+		return errors.Wrap(err, "Could not get table "+table)
+	}
+	data, err := json.Marshal(rowSnapshot{Table: table, Columns: toWireDefinitions(tbl.ColumnDefinitions), Rows: [][]wireColumn{toWireRow(row.Columns)}})
+	if err != nil {
+		return errors.Wrap(err, "Could not serialize version")
+	}
+
+	var versions []storedVersion
+	err = scanKey(stub, name, []shim.Column{{Value: &shim.Column_Int64{Int64: item.GetId()}}}, func(tbl *shim.Table, row shim.Row) error {
+		versions = append(versions, versionOf(row))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var version uint64
+	for _, v := range versions {
+		if v.version > version {
+			version = v.version
+		}
+	}
+	version++
+
+	// Without a transaction time, e.g. in a MockStub, the version can't expire by age
+	var written int64
+	if at, err := now(stub, o); err == nil {
+		written = at.Unix()
+	} else if retentionOf(t).Days > 0 {
+		return err
+	}
+	_, err = stub.InsertRow(name, shim.Row{Columns: []*shim.Column{
+		{Value: &shim.Column_Int64{Int64: item.GetId()}},
+		{Value: &shim.Column_Uint64{Uint64: version}},
+		{Value: &shim.Column_String_{String_: stub.GetTxID()}},
+		{Value: &shim.Column_Int64{Int64: written}},
+		{Value: &shim.Column_Bytes{Bytes: data}},
+	}})
+	if err != nil {
+		return errors.Wrapf(err, "Could not store version %d of %s with id %d", version, table, item.GetId())
+	}
+
+	expired, err := expiredVersions(stub, t, versions, map[int64]uint64{item.GetId(): version}, o)
+	if err != nil {
+		return err
+	}
+	for _, v := range expired {
+		if err := stub.DeleteRow(name, versionKey(v.id, v.version)); err != nil {
+			return errors.Wrapf(err, "Could not delete version %d of %d from %s", v.version, v.id, name)
+		}
+	}
+	return nil
+}
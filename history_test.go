@@ -0,0 +1,122 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+type TestHistoric struct {
+	Status string
+	Saveable
+}
+
+func TestGetVersion(t *testing.T) {
+	KeepVersions(new(TestHistoric))
+	defer delete(keptVersions, "TestHistoric")
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("create")
+	if err := CreateTable(stub, new(TestHistoric)); err != nil {
+		fail(t, err)
+	}
+	item := TestHistoric{Status: "draft"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	stub.MockTransactionStart("publish")
+	item.Status = "published"
+	if err := Update(stub, &item); err != nil {
+		fail(t, err)
+	}
+
+	var version TestHistoric
+	if err := GetVersion(stub, &version, 1, 1); err != nil || version.Status != "draft" {
+		fail(t, "The first version should be the created item")
+	}
+	if err := GetVersion(stub, &version, 1, 2); err != nil || version.Status != "published" {
+		fail(t, "The second version should be the updated item")
+	}
+	if err := GetVersion(stub, &version, 1, 3); errors.Cause(err) != ErrNotFound {
+		fail(t, "A version that was not written should not be found")
+	}
+	row, err := stub.GetRow("TestHistoric_versions", []shim.Column{{Value: &shim.Column_Int64{Int64: 1}}, {Value: &shim.Column_Uint64{Uint64: 2}}})
+	if err != nil || row.Columns[2].GetString_() != "publish" {
+		fail(t, "The version should record its transaction")
+	}
+	checkErrorContains(t, GetVersion(stub, new(TestStruct), 1, 1), "Versions of TestStruct are not kept")
+
+	// The id of a deleted item is given out again, without its versions
+	if err := Delete(stub, &item); err != nil {
+		fail(t, err)
+	}
+	reused := TestHistoric{Status: "new"}
+	if err := Create(stub, &reused); err != nil || reused.Id != 1 {
+		fail(t, "The id of the deleted item should be given out again")
+	}
+	if err := GetVersion(stub, &version, 1, 1); err != nil || version.Status != "new" {
+		fail(t, "The first version of a new item should be its own")
+	}
+	if err := GetVersion(stub, &version, 1, 2); errors.Cause(err) != ErrNotFound {
+		fail(t, "The versions of a deleted item should be deleted")
+	}
+}
+
+func TestRetainVersions(t *testing.T) {
+	KeepVersions(new(TestHistoric))
+	defer delete(keptVersions, "TestHistoric")
+	RetainVersions(new(TestHistoric), Retention{Versions: 2})
+	defer delete(retentions, "TestHistoric")
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestHistoric)); err != nil {
+		fail(t, err)
+	}
+	item := TestHistoric{Status: "draft"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	for _, status := range []string{"review", "published"} {
+		item.Status = status
+		if err := Update(stub, &item); err != nil {
+			fail(t, err)
+		}
+	}
+	var version TestHistoric
+	if err := GetVersion(stub, &version, 1, 1); errors.Cause(err) != ErrNotFound {
+		fail(t, "Versions beyond the last 2 should be removed on write")
+	}
+	if err := GetVersion(stub, &version, 1, 2); err != nil || version.Status != "review" {
+		fail(t, "The last 2 versions should be kept")
+	}
+
+	// Versions older than a day are purged, except the last version of an item
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	Configure(Config{Clock: FixedClock(start)})
+	defer Configure(Config{})
+	RetainVersions(new(TestHistoric), Retention{Days: 1})
+	other := TestHistoric{Status: "draft"}
+	if err := Create(stub, &other); err != nil {
+		fail(t, err)
+	}
+	for _, status := range []string{"review", "published"} {
+		other.Status = status
+		if err := Update(stub, &other); err != nil {
+			fail(t, err)
+		}
+	}
+	Configure(Config{Clock: FixedClock(start.AddDate(0, 0, 2))})
+	result, err := PurgeVersions(stub, new(TestHistoric), WithChunkSize(1))
+	if err != nil || result.Deleted != 1 || result.Done {
+		fail(t, "A pass should stop after the chunk size")
+	}
+	if result, err = PurgeVersions(stub, new(TestHistoric)); err != nil || result.Deleted != 1 || !result.Done {
+		fail(t, "The next pass should purge the rest")
+	}
+	if err := GetVersion(stub, &version, 2, 3); err != nil || version.Status != "published" {
+		fail(t, "The last version should be kept")
+	}
+	if err := GetVersion(stub, &version, 1, 2); err != nil {
+		fail(t, "Versions without a time should not expire by age")
+	}
+}
@@ -0,0 +1,328 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// A secondary index on a field, declared with a tag: Country string `index:"country"`. Tag several fields with the
+// same name to index them together, e.g. the owner and status of orders.
+//
+// The index is stored in the table <Table>_idx_<name>, with the values of the fields and the id of the item as key.
+// Create, Update and Delete keep it up to date.
+//
+// Add unique to the tag of any of its fields to allow only one item per combination of values, e.g.
+// `index:"tenant_email,unique"` on both TenantId and Email. Combinations with a zero value are not constrained.
+//
+// A partial index only has rows for the items that match a condition on the field, declared with a where tag in the
+// syntax of ParseQuery: Status string `index:"status" where:"Status != 'archived'"`. It is only used to look up values
+// that match the condition; other values are found by scanning the table.
+type index struct {
+	name   string
+	table  string
+	fields []reflect.StructField
+	where  *Query
+	unique bool
+}
+
+// Get the indexes declared on the fields of type t. Fields with the same index name form a compound index, in the
+// order of the fields.
+func indexesOf(t reflect.Type, o *options) ([]index, error) {
+	var indexes []index
+	positions := map[string]int{}
+	for _, f := range fieldsOf(t) {
+		options := strings.Split(f.Tag.Get("index"), ",")
+		name := options[0]
+		if name == "" && f.Tag.Get("ref") != "" {
+			name = refIndexName(f)
+		}
+		if name == "" {
+			continue
+		}
+		where, err := whereOf(t, f.StructField)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid condition of index "+name+" of "+t.Name())
+		}
+		if i, ok := positions[name]; ok {
+			if where != nil || indexes[i].where != nil {
+				return nil, errors.New("Compound index " + name + " of " + t.Name() + " can't be partial")
+			}
+			indexes[i].fields = append(indexes[i].fields, f.StructField)
+		} else {
+			positions[name] = len(indexes)
+			indexes = append(indexes, index{name: name, table: tableName(t, o) + "_idx_" + name, fields: []reflect.StructField{f.StructField}, where: where})
+		}
+		for _, option := range options[1:] {
+			if strings.TrimSpace(option) != "unique" {
+				return nil, errors.New("Unknown option " + option + " of index " + name + " of " + t.Name())
+			}
+			indexes[positions[name]].unique = true
+		}
+	}
+	return indexes, nil
+}
+
+// Parse the condition of a partial index on a field, if it has one
+func whereOf(t reflect.Type, f reflect.StructField) (*Query, error) {
+	text := f.Tag.Get("where")
+	if text == "" {
+		return nil, nil
+	}
+	if f.Tag.Get("ref") != "" {
+		return nil, errors.New("The index of a ref field can't be partial")
+	}
+	q, err := ParseQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.conditions) == 0 || len(q.order) > 0 || q.limit > 0 {
+		return nil, errors.New("Expected only conditions in " + text)
+	}
+	for _, c := range q.conditions {
+		if c.field != f.Name {
+			return nil, errors.New("Conditions can only be on " + f.Name + ", not " + c.field)
+		}
+	}
+	return q, q.validate(t)
+}
+
+// Check whether the index has a row for an item
+func (idx index) includes(item BlockchainItemizer) (bool, error) {
+	return idx.where.matches(item)
+}
+
+// Check whether the index has the rows of all items with a value of its field. Only the rows of values that match
+// the condition of a partial index are kept.
+func (idx index) covers(value interface{}) bool {
+	if idx.where == nil {
+		return true
+	}
+	f := idx.fields[0]
+	val := reflect.ValueOf(value)
+	if !val.IsValid() || !val.Type().ConvertibleTo(f.Type) {
+		return false
+	}
+	for _, c := range idx.where.conditions {
+		cmp, err := compare(val.Convert(f.Type), c.value)
+		if err != nil || !c.holds(cmp) {
+			return false
+		}
+	}
+	return true
+}
+
+// Create the index tables of type t
+func createIndexTables(stub shim.ChaincodeStubInterface, t reflect.Type, o *options) error {
+	indexes, err := indexesOf(t, o)
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		var cds []*shim.ColumnDefinition
+		for _, f := range idx.fields {
+			typ, ok := fieldColumnType(f)
+			if !ok {
+				return errors.New("Cannot index field " + f.Name + " of type " + f.Type.Name())
+			}
+			cds = append(cds, &shim.ColumnDefinition{Name: f.Name, Type: typ, Key: true})
+		}
+		cds = append(cds, &shim.ColumnDefinition{Name: "Id", Type: shim.ColumnDefinition_INT64, Key: true})
+
+		logger.Infof("Create Index %s", idx.table)
+		if err := stub.CreateTable(idx.table, cds); err != nil {
+			return errors.Wrap(err, "Could not create index "+idx.name)
+		}
+	}
+	return nil
+}
+
+// Create the row of an index for an item
+func (idx index) row(item BlockchainItemizer) (shim.Row, error) {
+	v := reflect.ValueOf(item).Elem()
+	row := shim.Row{}
+	for _, f := range idx.fields {
+		column, err := createColumnValue(f, v.FieldByIndex(f.Index).Interface())
+		if err != nil {
+			return row, errors.Wrap(err, "Can't create index column value")
+		}
+		row.Columns = append(row.Columns, &column)
+	}
+	row.Columns = append(row.Columns, &shim.Column{Value: &shim.Column_Int64{Int64: item.GetId()}})
+	return row, nil
+}
+
+// Get the row of an item in the index, or nil if there is no item or the index doesn't include it
+func (idx index) rowOf(item BlockchainItemizer) (*shim.Row, error) {
+	if item == nil || reflect.ValueOf(item).IsNil() {
+		return nil, nil
+	}
+	if ok, err := idx.includes(item); err != nil || !ok {
+		return nil, err
+	}
+	row, err := idx.row(item)
+	return &row, err
+}
+
+// Get the ids of the items with the given values of the first fields of the index, in order of their value
+func (idx index) lookup(stub shim.ChaincodeStubInterface, values ...interface{}) ([]int64, error) {
+	var key []shim.Column
+	for i, value := range values {
+		f := idx.fields[i]
+		val := reflect.ValueOf(value)
+		if !val.IsValid() || !val.Type().ConvertibleTo(f.Type) {
+			return nil, errors.Errorf("Cannot look up %v in index %s on %s", value, idx.name, f.Name)
+		}
+		column, err := createColumnValue(f, val.Convert(f.Type).Interface())
+		if err != nil {
+			return nil, err
+		}
+		key = append(key, column)
+	}
+	sessionOf(stub).countLookup(idx.table)
+	var ids []int64
+	err := scanKey(stub, idx.table, key, func(tbl *shim.Table, row shim.Row) error {
+		ids = append(ids, row.Columns[len(row.Columns)-1].GetInt64())
+		return nil
+	})
+	return ids, err
+}
+
+// Find the index on exactly the given field, if there is one
+func indexOn(t reflect.Type, field string, o *options) (*index, error) {
+	indexes, err := indexesOf(t, o)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range indexes {
+		if len(idx.fields) == 1 && idx.fields[0].Name == field {
+			return &idx, nil
+		}
+	}
+	return nil, nil
+}
+
+// All columns of an index row are part of the key
+func indexKey(row shim.Row) []shim.Column {
+	var key []shim.Column
+	for _, c := range row.Columns {
+		key = append(key, *c)
+	}
+	return key
+}
+
+// Add the index rows of a new item
+func insertIndexes(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	return updateIndexes(stub, nil, item, o)
+}
+
+// Remove the index rows of a deleted item
+func deleteIndexes(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	return updateIndexes(stub, item, nil, o)
+}
+
+// Remove the index rows of the old version of an item that the new version changes, so another item can take their
+// values in a unique index before the new version is written
+func releaseIndexes(stub shim.ChaincodeStubInterface, old, new BlockchainItemizer, o *options) error {
+	indexes, err := indexesOf(reflect.TypeOf(old).Elem(), o)
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		oldRow, err := idx.rowOf(old)
+		if err != nil {
+			return err
+		}
+		newRow, err := idx.rowOf(new)
+		if err != nil {
+			return err
+		}
+		if oldRow == nil || newRow != nil && reflect.DeepEqual(oldRow, newRow) {
+			continue
+		}
+		if err := stub.DeleteRow(idx.table, indexKey(*oldRow)); err != nil {
+			return errors.Wrap(err, "Could not update index "+idx.name)
+		}
+		sessionOf(stub).countIndexWrite(idx.table)
+	}
+	return nil
+}
+
+// Replace the index rows of the old version of an item by those of the new version. Either can be nil.
+func updateIndexes(stub shim.ChaincodeStubInterface, old, new BlockchainItemizer, o *options) error {
+	item := new
+	if item == nil {
+		item = old
+	}
+	indexes, err := indexesOf(reflect.TypeOf(item).Elem(), o)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range indexes {
+		var oldRow, newRow *shim.Row
+		if oldRow, err = idx.rowOf(old); err != nil {
+			return err
+		}
+		if newRow, err = idx.rowOf(new); err != nil {
+			return err
+		}
+		if oldRow != nil && newRow != nil && reflect.DeepEqual(oldRow, newRow) {
+			continue
+		}
+
+		if oldRow != nil {
+			if err := stub.DeleteRow(idx.table, indexKey(*oldRow)); err != nil {
+				return errors.Wrap(err, "Could not update index "+idx.name)
+			}
+			sessionOf(stub).countIndexWrite(idx.table)
+		}
+		if newRow != nil {
+			if _, err := stub.InsertRow(idx.table, *newRow); err != nil {
+				return errors.Wrap(err, "Could not update index "+idx.name)
+			}
+			sessionOf(stub).countIndexWrite(idx.table)
+		}
+	}
+	return nil
+}
+
+// Check that no other item has the values of an item in a unique index. Returns an error before anything is written.
+func checkUniqueIndexes(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(item).Elem()
+	indexes, err := indexesOf(t, o)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(item).Elem()
+	for _, idx := range indexes {
+		if !idx.unique {
+			continue
+		}
+		var names []string
+		var values []interface{}
+		for _, f := range idx.fields {
+			value := v.FieldByIndex(f.Index)
+			if reflect.DeepEqual(value.Interface(), reflect.Zero(f.Type).Interface()) {
+				values = nil
+				break
+			}
+			names = append(names, f.Name)
+			values = append(values, value.Interface())
+		}
+		if values == nil {
+			continue
+		}
+		ids, err := idx.lookup(stub, values...)
+		if err != nil {
+			return errors.Wrap(err, "Could not check index "+idx.name)
+		}
+		for _, id := range ids {
+			if id != item.GetId() {
+				return errors.Errorf("%s %v is already used by %s %d", strings.Join(names, ", "), values, t.Name(), id)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"reflect"
+	"testing"
+)
+
+type TestIndexed struct {
+	Country string `index:"country"`
+	Name    string
+	Saveable
+}
+
+func checkCreateIndexed(t *testing.T, stub shim.ChaincodeStubInterface, countries ...string) {
+	if err := CreateTable(stub, &TestIndexed{}); err != nil {
+		fail(t, err)
+	}
+	for _, country := range countries {
+		if err := Create(stub, &TestIndexed{Country: country}); err != nil {
+			fail(t, err)
+		}
+	}
+}
+
+// Get the ids in the index for a country
+func checkIndexIds(t *testing.T, stub shim.ChaincodeStubInterface, country string) []int64 {
+	rows, err := stub.GetRows("TestIndexed_idx_country", []shim.Column{{Value: &shim.Column_String_{String_: country}}})
+	if err != nil {
+		fail(t, err)
+	}
+	var ids []int64
+	for row := range rows {
+		ids = append(ids, row.Columns[1].GetInt64())
+	}
+	return ids
+}
+
+func TestIndex(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "NL")
+
+	if ids := checkIndexIds(t, stub, "NL"); len(ids) != 2 {
+		fail(t, "Index should have 2 rows for NL")
+	}
+
+	var a TestIndexed
+	if err := Get(stub, &a, 1); err != nil {
+		fail(t, err)
+	}
+	a.Country = "DE"
+	if err := Update(stub, &a); err != nil {
+		fail(t, err)
+	}
+	if ids := checkIndexIds(t, stub, "NL"); len(ids) != 1 || ids[0] != 3 {
+		fail(t, "Update should remove the old index row")
+	}
+	if ids := checkIndexIds(t, stub, "DE"); len(ids) != 1 || ids[0] != 1 {
+		fail(t, "Update should add the new index row")
+	}
+
+	// Delete uses the stored values
+	a.Country = "BE"
+	if err := Delete(stub, &a); err != nil {
+		fail(t, err)
+	}
+	if ids := checkIndexIds(t, stub, "DE"); len(ids) != 0 {
+		fail(t, "Delete should remove the index row")
+	}
+	if ids := checkIndexIds(t, stub, "BE"); len(ids) != 1 {
+		fail(t, "Delete should not remove other index rows")
+	}
+}
+
+func TestIndexSaveWith(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL")
+
+	incoming := TestIndexed{Country: "BE"}
+	incoming.Id = 1
+	err := SaveWith(stub, &incoming, func(existing, incoming BlockchainItemizer) (BlockchainItemizer, error) {
+		existing.(*TestIndexed).Country = incoming.(*TestIndexed).Country
+		return existing, nil
+	})
+	if err != nil {
+		fail(t, err)
+	}
+	if len(checkIndexIds(t, stub, "NL")) != 0 || len(checkIndexIds(t, stub, "BE")) != 1 {
+		fail(t, "SaveWith should update the index")
+	}
+}
+
+type TestArchivable struct {
+	Status string `index:"status" where:"Status != 'archived'"`
+	Saveable
+}
+
+type TestInvalidPartial struct {
+	Status string `index:"status" where:"Name = 'x'"`
+	Name   string
+	Saveable
+}
+
+func TestPartialIndex(t *testing.T) {
+	stub := &countingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode)), table: "TestArchivable"}
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestArchivable{}); err != nil {
+		fail(t, err)
+	}
+	for _, status := range []string{"open", "archived", "open"} {
+		if err := Create(stub, &TestArchivable{Status: status}); err != nil {
+			fail(t, err)
+		}
+	}
+	countRows := func() int {
+		rows, err := stub.MockStub.GetRows("TestArchivable_idx_status", []shim.Column{})
+		if err != nil {
+			fail(t, err)
+		}
+		n := 0
+		for range rows {
+			n++
+		}
+		return n
+	}
+	if n := countRows(); n != 2 {
+		fail(t, "Archived items should not be indexed")
+	}
+
+	// Values that match the condition are read from the index
+	stub.rows = 0
+	if n, err := CountBy(stub, &TestArchivable{}, "Status", "open"); err != nil || n != 2 || stub.rows != 0 {
+		fail(t, "Indexed values should be counted from the index")
+	}
+	// Other values are found by scanning the table
+	var tickets []TestArchivable
+	if err := Find(stub, &tickets, Where("Status", "=", "archived")); err != nil || len(tickets) != 1 || stub.rows != 1 {
+		fail(t, "Values left out of the index should be found by scanning")
+	}
+	if values, err := Distinct(stub, &TestArchivable{}, "Status"); err != nil || len(values) != 2 {
+		fail(t, "Distinct should not read a partial index")
+	}
+
+	// Archiving removes the index row, and restoring adds it again
+	var ticket TestArchivable
+	if err := Get(stub, &ticket, 1); err != nil {
+		fail(t, err)
+	}
+	ticket.Status = "archived"
+	if err := Update(stub, &ticket); err != nil {
+		fail(t, err)
+	}
+	if n := countRows(); n != 1 {
+		fail(t, "Archiving should remove the index row")
+	}
+	ticket.Status = "closed"
+	if err := Update(stub, &ticket); err != nil {
+		fail(t, err)
+	}
+	if n := countRows(); n != 2 {
+		fail(t, "Restoring should add the index row")
+	}
+	if result, err := RebuildIndexes(stub, &TestArchivable{}); err != nil || result.Inserted+result.Deleted != 0 {
+		fail(t, "Rebuilding should leave out the same items")
+	}
+
+	if err := CreateTable(stub, &TestInvalidPartial{}); err == nil {
+		fail(t, "Conditions on other fields should fail")
+	}
+}
+
+type TestMember struct {
+	TenantId int64  `index:"tenant_email,unique"`
+	Email    string `index:"tenant_email"`
+	Saveable
+}
+
+func TestUniqueIndex(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestMember{}); err != nil {
+		fail(t, err)
+	}
+	for _, m := range []TestMember{{TenantId: 1, Email: "a@x"}, {TenantId: 2, Email: "a@x"}, {TenantId: 1}, {TenantId: 1}} {
+		if err := Create(stub, &m); err != nil {
+			fail(t, err)
+		}
+	}
+	err := Create(stub, &TestMember{TenantId: 1, Email: "a@x"})
+	checkErrorContains(t, err, "TenantId, Email [1 a@x] is already used by TestMember 1")
+	var members []TestMember
+	if err := GetAll(stub, &members); err != nil || len(members) != 4 {
+		fail(t, "Nothing should be written when the values are taken")
+	}
+
+	// Updating an item to its own values is allowed, to the values of another item is not
+	var m TestMember
+	if err := Get(stub, &m, 1); err != nil {
+		fail(t, err)
+	}
+	if err := Update(stub, &m); err != nil {
+		fail(t, err)
+	}
+	m.TenantId = 2
+	if err := Update(stub, &m); err == nil {
+		fail(t, "Updating to the values of another item should fail")
+	}
+
+	if e := describe(reflect.TypeOf(TestMember{})); !e.Indexes[0].Unique {
+		fail(t, "The index should be described as unique")
+	}
+}
@@ -0,0 +1,114 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// A stored row next to the item it decodes to, returned by Inspect
+type Inspection struct {
+	Table   string
+	Id      int64
+	Columns []ColumnInspection
+	// Fields of the struct without a column in the table
+	MissingColumns []string
+	// The decoded item, or nil if it could not be decoded
+	Item BlockchainItemizer
+	// Why the row could not be decoded
+	DecodeError string `json:",omitempty"`
+}
+
+// A column of a stored row
+type ColumnInspection struct {
+	Name string
+	// The type in the definition of the table
+	Type string
+	// The type of the stored value
+	Stored string
+	Value  interface{}
+	// The name and type of the field the column is decoded to, or empty if there is none
+	Field string `json:",omitempty"`
+	// Why the column doesn't match the definition or the field
+	Problem string `json:",omitempty"`
+}
+
+// Get the raw columns of the row with the given id with their ledger types, and the item they decode to. Use it to
+// diagnose rows that don't match the struct definition, e.g. from an admin query:
+//
+// inspection, err := orm.Inspect(stub, &User{}, 1)
+// return json.Marshal(inspection)
+//
+// A row that can't be decoded is no error; DecodeError tells why.
+func Inspect(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, id int64, opts ...Option) (*Inspection, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	o := newOptions(stub, opts).unprojected()
+	name := tableName(t, o)
+
+	tbl, err := stub.GetTable(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get table "+name)
+	}
+	row, err := stub.GetRow(name, []shim.Column{{Value: &shim.Column_Int64{Int64: id}}})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get %s with id %d", name, id)
+	}
+	if len(row.Columns) == 0 {
+		return nil, errors.Errorf("%s with id %d not found", name, id)
+	}
+
+	item := reflect.New(t).Interface().(BlockchainItemizer)
+	inspection := &Inspection{Table: name, Id: id}
+	if err := setValues(tbl, row, item, o); err != nil {
+		inspection.DecodeError = err.Error()
+	} else if err := authorize(stub, "read", item); err != nil {
+		return nil, err
+	} else {
+		inspection.Item = item
+	}
+
+	fields := fieldsByColumn(t)
+	for i, c := range row.Columns {
+		column := ColumnInspection{Stored: "missing"}
+		if c != nil {
+			column.Value = columnValue(c)
+			if typ, ok := storedTypeOf(c); ok {
+				column.Stored = typ.String()
+			}
+		}
+		if i < len(tbl.ColumnDefinitions) {
+			cd := tbl.ColumnDefinitions[i]
+			column.Name, column.Type = cd.Name, cd.Type.String()
+			if column.Stored != column.Type {
+				column.Problem = "stored value is not of the type of the column"
+			}
+		} else {
+			column.Problem = "no column definition"
+		}
+		if f, ok := fields[column.Name]; ok {
+			column.Field = f.Name + " " + f.Type.String()
+			if typ, ok := fieldColumnType(f.StructField); !ok {
+				column.Problem = "field type cannot be stored"
+			} else if typ.String() != column.Type && column.Problem == "" {
+				column.Problem = "field is stored as " + typ.String()
+			}
+			delete(fields, column.Name)
+		}
+		inspection.Columns = append(inspection.Columns, column)
+	}
+	for _, f := range fieldsOf(t) {
+		if _, ok := fields[f.column]; !ok {
+			continue
+		}
+		if _, ok := columnTypeOf(f.Type); ok {
+			inspection.MissingColumns = append(inspection.MissingColumns, f.column)
+		}
+	}
+	return inspection, nil
+}
@@ -0,0 +1,75 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestInspected struct {
+	Name  string
+	Count int64
+	Note  string
+	Saveable
+}
+
+func TestInspect(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreate(t, stub)
+
+	inspection, err := Inspect(stub, new(TestStruct), 1)
+	if err != nil {
+		fail(t, err)
+	}
+	if inspection.Item.(*TestStruct).Str != "isAString" || inspection.DecodeError != "" || len(inspection.MissingColumns) != 0 {
+		fail(t, "The row should decode")
+	}
+	for _, c := range inspection.Columns {
+		if c.Problem != "" {
+			fail(t, c.Name+": "+c.Problem)
+		}
+	}
+	if c := inspection.Columns[0]; c.Name != "Str" || c.Type != "STRING" || c.Stored != "STRING" || c.Value != "isAString" || c.Field != "Str string" {
+		fail(t, c)
+	}
+	if _, err := Inspect(stub, new(TestStruct), 2); err == nil {
+		fail(t, "Inspecting a missing row should fail")
+	}
+}
+
+func TestInspectMismatch(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	// Count was stored as a string by an older version, which had no Note
+	err := stub.CreateTable("TestInspected", []*shim.ColumnDefinition{
+		{Name: "Name", Type: shim.ColumnDefinition_STRING},
+		{Name: "Count", Type: shim.ColumnDefinition_STRING},
+		{Name: "Id", Type: shim.ColumnDefinition_INT64, Key: true},
+	})
+	if err != nil {
+		fail(t, err)
+	}
+	row := shim.Row{Columns: []*shim.Column{
+		{Value: &shim.Column_String_{String_: "a"}},
+		{Value: &shim.Column_String_{String_: "12"}},
+		{Value: &shim.Column_Int64{Int64: 1}},
+	}}
+	if _, err := stub.InsertRow("TestInspected", row); err != nil {
+		fail(t, err)
+	}
+
+	inspection, err := Inspect(stub, new(TestInspected), 1)
+	if err != nil {
+		fail(t, err)
+	}
+	if inspection.Item != nil || inspection.DecodeError == "" {
+		fail(t, "The row should not decode")
+	}
+	if c := inspection.Columns[1]; c.Problem != "field is stored as INT64" || c.Field != "Count int64" {
+		fail(t, c)
+	}
+	if len(inspection.MissingColumns) != 1 || inspection.MissingColumns[0] != "Note" {
+		fail(t, inspection.MissingColumns)
+	}
+}
@@ -0,0 +1,224 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// A query on items that refer to another item by id, with conditions on both:
+//
+// err := orm.Join(&User{}, "UserId").Where("User.Country", "=", "NL").Find(stub, &orders)
+//
+// The parents that match their conditions are read first. Their children are then fetched by id through the index on
+// the foreign key, or by a single scan when the foreign key is not indexed.
+type JoinQuery struct {
+	parent     reflect.Type
+	foreignKey string
+	parents    *Query
+	children   *Query
+}
+
+// Start a query on the items that refer to a parent of the given type with the field foreignKey
+func Join(parent BlockchainItemizer, foreignKey string) *JoinQuery {
+	return &JoinQuery{parent: reflect.TypeOf(parent), foreignKey: foreignKey, parents: new(Query), children: new(Query)}
+}
+
+// Add a condition. Fields prefixed with the name of the parent type, like User.Country, apply to the parent; other
+// fields to the items that are found.
+func (j *JoinQuery) Where(field, op string, value interface{}) *JoinQuery {
+	if j.parent != nil && j.parent.Kind() == reflect.Ptr && strings.HasPrefix(field, j.parent.Elem().Name()+".") {
+		j.parents.And(strings.TrimPrefix(field, j.parent.Elem().Name()+"."), op, value)
+	} else {
+		j.children.And(field, op, value)
+	}
+	return j
+}
+
+// Add a condition, like Where
+func (j *JoinQuery) And(field, op string, value interface{}) *JoinQuery {
+	return j.Where(field, op, value)
+}
+
+// Get the items that match the query by passing a pointer to a slice of the correct type. Items are grouped by
+// parent when the foreign key is indexed.
+func (j *JoinQuery) Find(stub shim.ChaincodeStubInterface, items interface{}, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if j.parent == nil || !j.parent.Implements(reflect.TypeOf((*BlockchainItemizer)(nil)).Elem()) {
+		return errors.New("Pass a pointer to the parent entity to Join")
+	}
+	if err := checkItem(reflect.New(j.parent.Elem()).Interface().(BlockchainItemizer)); err != nil {
+		return err
+	}
+	if err := checkSlice(items); err != nil {
+		return errors.Wrap(err, "Object passed to Find should be a pointer to a slice")
+	}
+	v := reflect.ValueOf(items).Elem()
+	t := v.Type().Elem()
+
+	fk, err := foreignKeyOf(t, j.foreignKey)
+	if err != nil {
+		return err
+	}
+	if err := j.parents.validate(j.parent.Elem()); err != nil {
+		return err
+	}
+	if err := j.children.validate(t); err != nil {
+		return err
+	}
+	o := newOptions(stub, opts)
+	if o.projection != nil {
+		o.projection[j.foreignKey] = true
+		for _, c := range j.children.conditions {
+			o.projection[c.field] = true
+		}
+	}
+
+	parentIds, err := ids(stub, j.parent.Elem(), j.parents, o)
+	if err != nil {
+		return errors.Wrap(err, "Could not read "+j.parent.Elem().Name())
+	}
+	logger.Debugf("Joining %d %vs", len(parentIds), j.parent.Elem().Name())
+
+	add := func(item interface{}) error {
+		if ok, err := j.children.matches(item); err != nil {
+			return err
+		} else if ok {
+			v.Set(reflect.Append(v, reflect.ValueOf(item).Elem()))
+		}
+		return nil
+	}
+
+	idx, err := indexOn(t, j.foreignKey, o)
+	if err != nil {
+		return err
+	}
+	if idx == nil || idx.where != nil {
+		logger.Warningf("Foreign key %s of %s is not fully indexed; scanning", j.foreignKey, t.Name())
+		wanted := map[int64]bool{}
+		for _, id := range parentIds {
+			wanted[id] = true
+		}
+		return scan(stub, t, o, func(item interface{}) error {
+			if !wanted[toInt64(reflect.ValueOf(item).Elem().FieldByIndex(fk.Index))] {
+				return nil
+			}
+			return add(item)
+		})
+	}
+
+	authorizer, err := authorizerOf(stub, t, "read")
+	if err != nil {
+		return err
+	}
+	for _, parentId := range parentIds {
+		ids, err := idx.lookup(stub, parentId)
+		if err != nil {
+			return errors.Wrap(err, "Could not look up "+t.Name())
+		}
+		for _, id := range ids {
+			item := reflect.New(t).Interface().(BlockchainItemizer)
+			if err := get(stub, item, id, o); err != nil {
+				return err
+			}
+			if item.GetId() == 0 {
+				return errors.Errorf("Index %s refers to %s %d, which does not exist", idx.name, t.Name(), id)
+			}
+			if authorizer.check(item) != nil {
+				continue
+			}
+			if err := add(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Get the items that refer to a parent that doesn't exist, e.g. to audit the data after a failed cascade. Items with
+// foreignKey 0 don't refer to a parent and are not orphans. The items are pointers of the type of child.
+func FindOrphans(stub shim.ChaincodeStubInterface, child BlockchainItemizer, foreignKey string, parent BlockchainItemizer, opts ...Option) ([]BlockchainItemizer, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(child); err != nil {
+		return nil, err
+	}
+	if err := checkItem(parent); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(child).Elem()
+	fk, err := foreignKeyOf(t, foreignKey)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(stub, opts)
+	if o.projection != nil {
+		o.projection[foreignKey] = true
+	}
+
+	parentIds, err := ids(stub, reflect.TypeOf(parent).Elem(), nil, o)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read "+reflect.TypeOf(parent).Elem().Name())
+	}
+	exists := map[int64]bool{}
+	for _, id := range parentIds {
+		exists[id] = true
+	}
+
+	var orphans []BlockchainItemizer
+	err = scan(stub, t, o, func(item interface{}) error {
+		if id := toInt64(reflect.ValueOf(item).Elem().FieldByIndex(fk.Index)); id != 0 && !exists[id] {
+			orphans = append(orphans, item.(BlockchainItemizer))
+		}
+		return nil
+	})
+	logger.Debugf("Found %d orphaned %vs", len(orphans), t.Name())
+	return orphans, err
+}
+
+// Get the field of t that refers to the id of another item
+func foreignKeyOf(t reflect.Type, name string) (reflect.StructField, error) {
+	fk, ok := t.FieldByName(name)
+	if !ok {
+		return fk, errors.New("Field " + name + " not found in " + t.Name())
+	}
+	switch fk.Type.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return fk, nil
+	}
+	return fk, errors.New("Foreign key " + name + " of " + t.Name() + " should hold an id")
+}
+
+// Get the ids of the items of type t that match a query. Only the fields of the conditions are read.
+func ids(stub shim.ChaincodeStubInterface, t reflect.Type, q *Query, o *options) ([]int64, error) {
+	po := o.unprojected()
+	WithProjection()(po)
+	if q != nil {
+		for _, c := range q.conditions {
+			po.projection[c.field] = true
+		}
+	}
+	var ids []int64
+	err := scan(stub, t, po, func(item interface{}) error {
+		if ok, err := q.matches(item); err != nil {
+			return err
+		} else if ok {
+			ids = append(ids, item.(BlockchainItemizer).GetId())
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// Get the value of an integer field as int64
+func toInt64(f reflect.Value) int64 {
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(f.Uint())
+	}
+	return f.Int()
+}
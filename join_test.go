@@ -0,0 +1,95 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestOrder struct {
+	ParentId int64 `index:"parent" references:"TestIndexed"`
+	Amount   int64
+	Saveable
+}
+
+type TestUnindexedOrder struct {
+	ParentId int64
+	Amount   int64
+	Saveable
+}
+
+// Create orders for the items with the given parent ids
+func checkCreateOrders(t *testing.T, stub shim.ChaincodeStubInterface, order BlockchainItemizer, parentIds ...int64) {
+	if err := CreateTable(stub, order); err != nil {
+		fail(t, err)
+	}
+	for i, parentId := range parentIds {
+		var item BlockchainItemizer
+		switch order.(type) {
+		case *TestOrder:
+			item = &TestOrder{ParentId: parentId, Amount: int64(i + 1)}
+		default:
+			item = &TestUnindexedOrder{ParentId: parentId, Amount: int64(i + 1)}
+		}
+		if err := Create(stub, item); err != nil {
+			fail(t, err)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "NL")
+	checkCreateOrders(t, stub, new(TestOrder), 1, 2, 3, 3, 1)
+
+	var orders []TestOrder
+	q := Join(&TestIndexed{}, "ParentId").Where("TestIndexed.Country", "=", "NL").And("Amount", ">", 1)
+	if err := q.Find(stub, &orders); err != nil {
+		fail(t, err)
+	}
+	if len(orders) != 3 {
+		fail(t, "Should find 3 orders of parents in NL with an amount above 1")
+	}
+	for _, o := range orders {
+		if o.ParentId == 2 || o.Amount == 1 {
+			fail(t, "Order does not match the query")
+		}
+	}
+}
+
+func TestJoinUnindexed(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE")
+	checkCreateOrders(t, stub, new(TestUnindexedOrder), 1, 2, 2)
+
+	var orders []TestUnindexedOrder
+	if err := Join(&TestIndexed{}, "ParentId").Where("TestIndexed.Country", "=", "BE").Find(stub, &orders); err != nil {
+		fail(t, err)
+	}
+	if len(orders) != 2 {
+		fail(t, "Should find the 2 orders of the parent in BE")
+	}
+
+	checkErrorContains(t, Join(&TestIndexed{}, "Missing").Find(stub, &orders), "Missing")
+}
+
+func TestFindOrphans(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE")
+	checkCreateOrders(t, stub, new(TestOrder), 1, 2, 3, 0, 5)
+
+	orphans, err := FindOrphans(stub, &TestOrder{}, "ParentId", &TestIndexed{})
+	if err != nil {
+		fail(t, err)
+	}
+	if len(orphans) != 2 {
+		fail(t, "Orders of parents 3 and 5 should be orphans")
+	}
+	for _, o := range orphans {
+		if id := o.(*TestOrder).ParentId; id != 3 && id != 5 {
+			fail(t, "Order is not an orphan")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package orm
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Describe the JSON of an entity as a JSON Schema (draft 4), e.g. to let a REST gateway validate the payloads of
+// clients. The properties follow the json tags of the fields. Also usable as an OpenAPI schema object.
+func JSONSchema(prototype BlockchainItemizer) (map[string]interface{}, error) {
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	return jsonSchemaOf(reflect.TypeOf(prototype).Elem()), nil
+}
+
+func jsonSchemaOf(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, f := range fieldsOf(t) {
+		name, ok := jsonNameOf(f)
+		if !ok {
+			continue
+		}
+		if property := jsonPropertyOf(f); property != nil {
+			properties[name] = property
+		}
+	}
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-04/schema#",
+		"title":                t.Name(),
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// Get the name of a field in JSON, or false if it is left out
+func jsonNameOf(f field) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if n := strings.Split(tag, ",")[0]; n != "" {
+		return n, true
+	}
+	return f.Name, true
+}
+
+// Describe the JSON of a stored field, or nil if it isn't stored
+func jsonPropertyOf(f field) map[string]interface{} {
+	t := f.Type
+	if _, ok := columnTypeOf(t); !ok {
+		return nil
+	}
+	nullable := isNullable(t)
+	if nullable {
+		t = t.Elem()
+	}
+	p := map[string]interface{}{}
+	switch {
+	case isValuer(t):
+		// Its JSON doesn't follow from its column, so any value is allowed
+	case t == timeType:
+		p["type"], p["format"] = "string", "date-time"
+	case isText(t) && t != urlType:
+		p["type"] = "string"
+	case t.Kind() == reflect.Struct:
+		// Marshaled by encoding/json as an object, like url.URL
+		p["type"] = "object"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		p["type"], p["format"] = "string", "byte"
+	case t.Kind() == reflect.Bool:
+		p["type"] = "boolean"
+	case t.Kind() == reflect.String:
+		p["type"] = "string"
+	case isFloat(t):
+		p["type"] = "number"
+	case t.Kind() == reflect.Int32 || t.Kind() == reflect.Uint32:
+		p["type"], p["format"] = "integer", "int32"
+	default:
+		p["type"], p["format"] = "integer", "int64"
+	}
+	switch t.Kind() {
+	case reflect.Uint32, reflect.Uint64:
+		p["minimum"] = 0
+	}
+	if size, err := strconv.Atoi(f.Tag.Get("size")); err == nil && p["type"] == "string" {
+		p["maxLength"] = size
+	}
+	if nullable && p["type"] != nil {
+		p["type"] = []interface{}{p["type"], "null"}
+	}
+	return p
+}
@@ -0,0 +1,43 @@
+package orm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type TestSchema struct {
+	Name    string `json:"name" size:"10"`
+	Count   uint32
+	Secret  string `json:"-"`
+	Texts   TestTexts
+	Address TestTexts `json:"address,omitempty"`
+	Saveable
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := JSONSchema(&TestSchema{})
+	if err != nil {
+		fail(t, err)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	if len(properties) != 5 {
+		fail(t, properties)
+	}
+	if properties["address"].(map[string]interface{})["type"] != "object" {
+		fail(t, "Struct fields should be objects")
+	}
+	name := properties["name"].(map[string]interface{})
+	if name["type"] != "string" || name["maxLength"] != 10 {
+		fail(t, name)
+	}
+	count := properties["Count"].(map[string]interface{})
+	if count["type"] != "integer" || count["format"] != "int32" || count["minimum"] != 0 {
+		fail(t, count)
+	}
+	if properties["id"].(map[string]interface{})["format"] != "int64" {
+		fail(t, "Id should be an int64 named id")
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		fail(t, err)
+	}
+}
@@ -0,0 +1,155 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Place an anonymous Keyed in your struct instead of Saveable to key its table on your own fields, like a serial
+// number, instead of the id:
+//
+// type Asset struct {
+//   Serial string `key:"true"`
+//   Owner  string
+//   orm.Keyed
+// }
+//
+// The id is still set by Create, for indexes and versions, but Update and Delete find the item by its key, so an item
+// can be updated without its id. Read items with GetByKeys.
+type Keyed struct {
+	Id int64 `json:"id"`
+}
+
+func (k *Keyed) GetId() int64   { return k.Id }
+func (k *Keyed) SetId(id int64) { k.Id = id }
+
+// Get an item of a table with a composite or natural key by the values of its key fields, in the order of the struct. Fields
+// tagged with `key:"true"` form the key of the table, together with the Id of Saveable:
+//
+// type Asset struct {
+//   OrgId string `key:"true"`
+//   Name  string
+//   orm.Saveable
+// }
+//
+// err := orm.GetByKeys(stub, &asset, "org1", int64(5))
+//
+// The rows of a table are ordered by their key, so the items of an organization are stored together. Get can't read
+// items of such tables, since it only knows the id. Returns ErrNotFound if there is no such item.
+func GetByKeys(stub shim.ChaincodeStubInterface, item BlockchainItemizer, keys ...interface{}) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	o := newOptions(stub, nil)
+	t := reflect.TypeOf(item).Elem()
+	fields := keyFieldsOf(t)
+	if len(keys) != len(fields) {
+		return errors.Errorf("%s has %d key fields, got %d values", t.Name(), len(fields), len(keys))
+	}
+	var columns []shim.Column
+	for i, f := range fields {
+		val := reflect.ValueOf(keys[i])
+		if !val.IsValid() || !val.Type().ConvertibleTo(f.Type) || val.Kind() != f.Type.Kind() {
+			return errors.Errorf("Key field %s of %s has type %v, got %v", f.Name, t.Name(), f.Type, keys[i])
+		}
+		column, err := createColumnValue(f.StructField, val.Convert(f.Type).Interface())
+		if err != nil {
+			return err
+		}
+		columns = append(columns, column)
+	}
+
+	value := reflect.New(t)
+	if err := getByKey(stub, value.Interface().(BlockchainItemizer), columns, o); err != nil {
+		return err
+	}
+	if value.Interface().(BlockchainItemizer).GetId() == 0 {
+		return ErrNotFound
+	}
+	if err := authorize(stub, "read", value.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(item).Elem().Set(value.Elem())
+	return nil
+}
+
+// Check whether the table of type t is keyed by the Id alone, so its items can be read by id
+func keyedById(t reflect.Type) bool {
+	keys := keyFieldsOf(t)
+	return len(keys) == 1 && keys[0].Name == "Id"
+}
+
+// Check that the key of an item is set. Key fields can't be empty, so an item with id 0 can't be written to a table
+// keyed by id.
+func checkKey(item BlockchainItemizer) error {
+	v := reflect.ValueOf(item).Elem()
+	for _, f := range keyFieldsOf(v.Type()) {
+		if !reflect.DeepEqual(v.FieldByIndex(f.Index).Interface(), reflect.Zero(f.Type).Interface()) {
+			continue
+		} else if f.Name == "Id" {
+			return errors.New("Item cannot have id 0")
+		} else {
+			return errors.Errorf("Key field %s of %s cannot be empty", f.Name, v.Type().Name())
+		}
+	}
+	return nil
+}
+
+// Get the values of the key fields of an item as key columns
+func keyOf(item BlockchainItemizer) ([]shim.Column, error) {
+	v := reflect.ValueOf(item).Elem()
+	var columns []shim.Column
+	for _, f := range keyFieldsOf(v.Type()) {
+		column, err := createColumnValue(f.StructField, v.FieldByIndex(f.Index).Interface())
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't create key column value")
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// Set the values of the row with the given key columns to the item. The id of the item stays 0 if there is no such row.
+func getByKey(stub shim.ChaincodeStubInterface, item BlockchainItemizer, columns []shim.Column, o *options) error {
+	name := tableName(reflect.TypeOf(item).Elem(), o)
+	if tbl, err := stub.GetTable(name); err != nil {
+		return errors.Wrap(err, "Could not get table "+name)
+	} else if row, err := stub.GetRow(name, columns); err != nil {
+		return errors.Wrapf(err, "Could not get %s with key %v", name, columnValues(columns))
+	} else if key, err := checksumKeyOf(stub, reflect.TypeOf(item).Elem()); err != nil {
+		return err
+	} else if err = verifyRow(key, tbl, row); err != nil {
+		return err
+	} else if err = setValues(tbl, row, item, o); err != nil {
+		return errors.Wrap(err, "Error setting values")
+	}
+	return nil
+}
+
+// Get the stored version of an item by the values of its key fields, or nil if it doesn't exist
+func getStoredItem(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) (BlockchainItemizer, error) {
+	key, err := keyOf(item)
+	if err != nil {
+		return nil, err
+	}
+	stored := reflect.New(reflect.TypeOf(item).Elem()).Interface().(BlockchainItemizer)
+	if err := getByKey(stub, stored, key, o.unprojected()); err != nil {
+		return nil, err
+	}
+	if stored.GetId() == 0 {
+		return nil, nil
+	}
+	return stored, nil
+}
+
+func columnValues(columns []shim.Column) []interface{} {
+	var values []interface{}
+	for i := range columns {
+		values = append(values, columnValue(&columns[i]))
+	}
+	return values
+}
@@ -0,0 +1,91 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+type TestAsset struct {
+	OrgId string `key:"true"`
+	Name  string `index:"name"`
+	Saveable
+}
+
+func TestCompositeKey(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestAsset)); err != nil {
+		fail(t, err)
+	}
+	tbl, _ := stub.GetTable("TestAsset")
+	if !tbl.ColumnDefinitions[0].Key || tbl.ColumnDefinitions[1].Key || !tbl.ColumnDefinitions[2].Key {
+		fail(t, "The key fields should be key columns")
+	}
+	for _, org := range []string{"org1", "org2"} {
+		if err := Create(stub, &TestAsset{OrgId: org, Name: "asset"}); err != nil {
+			fail(t, err)
+		}
+	}
+
+	var asset TestAsset
+	if err := GetByKeys(stub, &asset, "org2", int64(2)); err != nil || asset.OrgId != "org2" || asset.Id != 2 {
+		fail(t, "The item should be read by its keys")
+	}
+	if err := GetByKeys(stub, &asset, "org1", int64(2)); errors.Cause(err) != ErrNotFound {
+		fail(t, "An item with other keys should not be found")
+	}
+	checkErrorContains(t, GetByKeys(stub, &asset, "org1"), "TestAsset has 2 key fields, got 1 values")
+	checkErrorContains(t, GetByKeys(stub, &asset, "org1", 2), "Key field Id of TestAsset has type int64")
+	checkErrorContains(t, Get(stub, &asset, 1), "TestAsset is not keyed by id alone; use GetByKeys")
+
+	asset = TestAsset{OrgId: "org1", Name: "renamed"}
+	asset.Id = 1
+	if err := Update(stub, &asset); err != nil {
+		fail(t, err)
+	}
+	var assets []TestAsset
+	if err := Find(stub, &assets, Where("Name", "=", "renamed")); err != nil || len(assets) != 1 || assets[0].Id != 1 {
+		fail(t, "The item should be updated")
+	}
+	if err := Delete(stub, &asset); err != nil {
+		fail(t, err)
+	}
+	if err := GetByKeys(stub, &asset, "org1", int64(1)); errors.Cause(err) != ErrNotFound {
+		fail(t, "The item should be deleted")
+	}
+}
+
+type TestSerial struct {
+	Serial string `key:"true"`
+	Owner  string
+	Keyed
+}
+
+func TestNaturalKey(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestSerial)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestSerial{Serial: "SN-1", Owner: "alice"}); err != nil {
+		fail(t, err)
+	}
+	checkErrorContains(t, Create(stub, &TestSerial{Serial: "SN-1"}), "TestSerial with key [SN-1] already exists")
+	checkErrorContains(t, Create(stub, &TestSerial{}), "Key field Serial of TestSerial cannot be empty")
+
+	// Updated without the id
+	if err := Update(stub, &TestSerial{Serial: "SN-1", Owner: "bob"}); err != nil {
+		fail(t, err)
+	}
+	var item TestSerial
+	if err := GetByKeys(stub, &item, "SN-1"); err != nil || item.Owner != "bob" || item.Id != 1 {
+		fail(t, "The item should be updated by its key and keep its id")
+	}
+	if err := Delete(stub, &TestSerial{Serial: "SN-1"}); err != nil {
+		fail(t, err)
+	}
+	if err := GetByKeys(stub, &item, "SN-1"); errors.Cause(err) != ErrNotFound {
+		fail(t, "The item should be deleted by its key")
+	}
+}
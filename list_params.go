@@ -0,0 +1,102 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// The largest page size ListParams accept
+const maxPageSize = 1000
+
+// The largest page number ListParams accept, so the offset of the page fits in an int
+const maxPage = int(^uint(0)>>1) / maxPageSize
+
+// The parameters of a list endpoint, parsed from the JSON argument of a client with ParseListParams, so all list
+// endpoints filter, sort and page the same way:
+//
+// {"Filters": [{"Field": "Status", "Op": "=", "Value": "open"}], "SortBy": "CreatedAt", "SortDir": "desc",
+// "Page": 2, "PageSize": 20}
+//
+// Pages are numbered from 1. Without a PageSize, all results are returned.
+type ListParams struct {
+	Filters  []ListFilter
+	SortBy   string
+	SortDir  string
+	Page     int
+	PageSize int
+}
+
+// A condition of ListParams, with an operator of Where
+type ListFilter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// Parse and validate the parameters of a list endpoint. Fields are checked against the type when the query is run.
+func ParseListParams(data []byte) (*ListParams, error) {
+	p := new(ListParams)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, errors.Wrap(err, "Invalid list parameters")
+		}
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *ListParams) validate() error {
+	for _, f := range p.Filters {
+		if f.Field == "" {
+			return errors.New("Filter without field")
+		}
+		if !operators[f.Op] {
+			return errors.Errorf("Unknown operator %q in filter on %s", f.Op, f.Field)
+		}
+	}
+	switch strings.ToLower(p.SortDir) {
+	case "", "asc", "desc":
+	default:
+		return errors.Errorf("Sort direction should be asc or desc, not %q", p.SortDir)
+	}
+	if p.SortDir != "" && p.SortBy == "" {
+		return errors.New("Sort direction without field")
+	}
+	if p.Page < 0 || p.PageSize < 0 {
+		return errors.New("Page and page size cannot be negative")
+	}
+	if p.PageSize > maxPageSize {
+		return errors.Errorf("Page size cannot be larger than %d", maxPageSize)
+	}
+	if p.Page > maxPage {
+		return errors.Errorf("Page cannot be larger than %d", maxPage)
+	}
+	if p.Page > 1 && p.PageSize == 0 {
+		return errors.New("Page without page size")
+	}
+	return nil
+}
+
+// Get the query of the parameters, to run with Find or FindMaps
+func (p *ListParams) Query() (*Query, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	q := new(Query)
+	for _, f := range p.Filters {
+		q.And(f.Field, f.Op, f.Value)
+	}
+	if p.SortBy != "" {
+		q.OrderBy(p.SortBy, strings.ToLower(p.SortDir) == "desc")
+	}
+	if p.PageSize > 0 {
+		q.Limit(p.PageSize)
+		if p.Page > 1 {
+			q.Offset((p.Page - 1) * p.PageSize)
+		}
+	}
+	return q, nil
+}
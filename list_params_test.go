@@ -0,0 +1,71 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestListParams(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "c", "d", "e")
+
+	p, err := ParseListParams([]byte(`{"Filters": [{"Field": "I64", "Op": ">", "Value": 1}], "SortBy": "I64",
+		"SortDir": "DESC", "Page": 2, "PageSize": 2}`))
+	if err != nil {
+		fail(t, err)
+	}
+	q, err := p.Query()
+	if err != nil {
+		fail(t, err)
+	}
+	var items []TestStruct
+	if err := Find(stub, &items, q); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 2 || items[0].Str != "c" || items[1].Str != "b" {
+		fail(t, "The second page of the sorted, filtered items should be found")
+	}
+
+	// Beyond the last page
+	p.Page = 3
+	q, _ = p.Query()
+	items = nil
+	if err := Find(stub, &items, q); err != nil || len(items) != 0 {
+		fail(t, "A page beyond the results should be empty")
+	}
+
+	for params, message := range map[string]string{
+		`{"Filters": [{"Field": "I64", "Op": "~", "Value": 1}]}`: `Unknown operator "~" in filter on I64`,
+		`{"SortBy": "I64", "SortDir": "up"}`:                     `Sort direction should be asc or desc, not "up"`,
+		`{"Page": 2}`:                                            "Page without page size",
+		`{"PageSize": 5000}`:                                     "Page size cannot be larger than 1000",
+		`{"Page": "one"}`:                                        "Invalid list parameters",
+		`{"Page": 4611686018427387905, "PageSize": 2}`:           "Page cannot be larger than",
+	} {
+		_, err := ParseListParams([]byte(params))
+		checkErrorContains(t, err, message)
+	}
+}
+
+func TestNegativeOffset(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "c")
+
+	var items []TestStruct
+	if err := Find(stub, &items, Where("I64", ">=", 0).Offset(-1)); err != nil || len(items) != 3 {
+		fail(t, "A negative offset should skip nothing")
+	}
+	maps, err := FindMaps(stub, &TestStruct{}, Where("I64", ">=", 0).Offset(-1).Limit(2))
+	if err != nil || len(maps) != 2 {
+		fail(t, "A negative offset should skip nothing in FindMaps")
+	}
+	// The limit of a page far beyond the results doesn't overflow
+	items = nil
+	if err := Find(stub, &items, Where("I64", ">=", 0).Offset(int(^uint(0)>>1)).Limit(2)); err != nil || len(items) != 0 {
+		fail(t, "A page beyond the results should be empty")
+	}
+}
@@ -0,0 +1,168 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+)
+
+// Get the items of the table of prototype that match the query as maps from the JSON names of their fields to their
+// stored values, for handlers that return JSON without using the items:
+//
+// users, err := orm.FindMaps(stub, &User{}, orm.Where("Country", "=", "NL"))
+// return json.Marshal(users)
+//
+// Conditions are evaluated on the stored values. Rows are only decoded into items when the table has a policy, the
+// query has filters, the type has read transforms or the row has an older schema version; conditions and values are
+// then those of the items, as with Find. Values of text and binary types are the stored strings and bytes.
+func FindMaps(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, q *Query, opts ...Option) ([]map[string]interface{}, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	if err := q.validate(t); err != nil {
+		return nil, err
+	}
+	o := newOptions(stub, opts)
+	authorizer, err := authorizerOf(stub, t, "read")
+	if err != nil {
+		return nil, err
+	}
+	key, err := checksumKeyOf(stub, t)
+	if err != nil {
+		return nil, err
+	}
+	versioner, versioned := prototype.(SchemaVersioner)
+	transformed := transformsReads(t)
+	fields := fieldsByColumn(t)
+	ordered := q != nil && len(q.order) > 0
+
+	var results []map[string]interface{}
+	err = scanRows(stub, tableName(t, o), func(tbl *shim.Table, row shim.Row) error {
+		if err := verifyRow(key, tbl, row); err != nil {
+			return err
+		}
+		values := map[string]interface{}{}
+		stored, _ := storedSchemaVersion(tbl, row)
+		if authorizer != nil || transformed || q != nil && len(q.filters) > 0 || versioned && stored != versioner.SchemaVersion() {
+			item := reflect.New(t).Interface()
+			if err := setValues(tbl, row, item, o.unprojected()); err != nil {
+				return errors.Wrap(err, "Error setting values.")
+			}
+			if authorizer.check(item) != nil {
+				return nil
+			}
+			if ok, err := q.matches(item); err != nil || !ok {
+				return err
+			}
+			v := reflect.ValueOf(item).Elem()
+			for _, f := range fields {
+				values[f.Name] = v.FieldByIndex(f.Index).Interface()
+			}
+		} else {
+			for i, c := range row.Columns {
+				if i < len(tbl.ColumnDefinitions) {
+					if f, ok := fields[tbl.ColumnDefinitions[i].Name]; ok {
+						values[f.Name] = storedValue(f.Type, c)
+					}
+				}
+			}
+			if ok, err := q.matchesValues(values); err != nil || !ok {
+				return err
+			}
+		}
+		results = append(results, values)
+		if q.full(len(results)) {
+			return errStopScan
+		}
+		return nil
+	})
+	if err != nil && err != errStopScan && err != ErrResultTruncated {
+		return nil, err
+	}
+	if ordered {
+		sorted := &orderedMaps{maps: results, order: q.order}
+		sort.Stable(sorted)
+		if sorted.err != nil {
+			return nil, sorted.err
+		}
+	}
+	start, end := q.window(len(results))
+	results = results[start:end]
+
+	// Name the values like the JSON of the items
+	for i, values := range results {
+		m := map[string]interface{}{}
+		for _, f := range fields {
+			value, stored := values[f.Name]
+			if jsonName, ok := jsonNameOf(f); ok && stored && o.includes(f.Name, f.key) {
+				m[jsonName] = value
+			}
+		}
+		results[i] = m
+	}
+	if err == ErrResultTruncated {
+		return results, err
+	}
+	return results, nil
+}
+
+// Check whether stored values keyed by field name match all conditions of the query
+func (q *Query) matchesValues(values map[string]interface{}) (bool, error) {
+	if q == nil {
+		return true, nil
+	}
+	for _, c := range q.conditions {
+		value, ok := values[c.field]
+		if !ok {
+			return false, errors.New("Field " + c.field + " is not stored")
+		}
+		var cmp int
+		var err error
+		if c.value == nil && value != nil {
+			cmp = 1 // Only pointer fields can be compared with nil, and their values sort after it
+		} else {
+			cmp, err = compare(reflect.ValueOf(value), c.value)
+		}
+		if err != nil {
+			return false, errors.Wrap(err, "Could not evaluate condition on "+c.field)
+		}
+		if !c.holds(cmp) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Sorts maps of values by the order of a query
+type orderedMaps struct {
+	maps  []map[string]interface{}
+	order []ordering
+	// The first error comparing values, as Less can't return it
+	err error
+}
+
+func (s *orderedMaps) Len() int      { return len(s.maps) }
+func (s *orderedMaps) Swap(i, j int) { s.maps[i], s.maps[j] = s.maps[j], s.maps[i] }
+
+func (s *orderedMaps) Less(i, j int) bool {
+	for _, order := range s.order {
+		a, b := s.maps[i][order.field], s.maps[j][order.field]
+		if a == nil || b == nil {
+			continue
+		}
+		cmp, err := compare(reflect.ValueOf(a), b)
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		if err != nil || cmp == 0 {
+			continue
+		}
+		return (cmp < 0) != order.descending
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestTagged struct {
+	Name   string `json:"name"`
+	Secret string `json:"-"`
+	Saveable
+}
+
+func TestFindMaps(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "b", "c")
+
+	maps, err := FindMaps(stub, new(TestStruct), Where("Str", "=", "b").OrderBy("I64", true))
+	if err != nil {
+		fail(t, err)
+	}
+	if len(maps) != 2 || maps[0]["I64"] != int64(3) || maps[1]["Str"] != "b" || maps[1]["id"] != int64(2) {
+		fail(t, maps)
+	}
+	if maps, err := FindMaps(stub, new(TestStruct), nil, WithProjection("Str")); err != nil || len(maps) != 4 || len(maps[0]) != 2 {
+		fail(t, "Only the projected fields and the key should be set")
+	}
+	if maps, err := FindMaps(stub, new(TestStruct), new(Query).Limit(1)); err != nil || len(maps) != 1 {
+		fail(t, "The limit should apply")
+	}
+	odd := func(item interface{}) bool { return item.(*TestStruct).I64%2 == 1 }
+	if maps, err := FindMaps(stub, new(TestStruct), new(Query).Filter(odd)); err != nil || len(maps) != 2 {
+		fail(t, "Filters should apply")
+	}
+	if _, err := FindMaps(stub, new(TestStruct), Where("Nope", "=", 1)); err == nil {
+		fail(t, "Unknown fields should fail")
+	}
+}
+
+func TestFindMapsJSONNames(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestTagged)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestTagged{Name: "a", Secret: "s"}); err != nil {
+		fail(t, err)
+	}
+	maps, err := FindMaps(stub, new(TestTagged), Where("Name", "=", "a"))
+	if err != nil {
+		fail(t, err)
+	}
+	if len(maps) != 1 || maps[0]["name"] != "a" || len(maps[0]) != 2 {
+		fail(t, maps)
+	}
+}
+
+func TestFindMapsPolicy(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestOwned)); err != nil {
+		fail(t, err)
+	}
+	for _, owner := range []string{"alice", "bob"} {
+		if err := Create(stub, &TestOwned{Owner: owner}); err != nil {
+			fail(t, err)
+		}
+	}
+	Policy(&TestOwned{}).AllowRead(OwnerCaller)
+	defer delete(policies, "TestOwned")
+
+	maps, err := FindMaps(checkCaller(t, stub, "alice", "Org1"), new(TestOwned), nil)
+	if err != nil {
+		fail(t, err)
+	}
+	if len(maps) != 1 || maps[0]["Owner"] != "alice" {
+		fail(t, "Only the items the caller may read should be returned")
+	}
+}
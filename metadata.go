@@ -0,0 +1,123 @@
+package orm
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Describes an entity and its table, e.g. to document the data model with cmd/ormdoc
+type EntityMetadata struct {
+	Name    string
+	Table   string
+	Columns []ColumnMetadata
+	Indexes []IndexMetadata `json:",omitempty"`
+	// Principals allowed per operation, if the table has a Policy
+	Policy map[string][]string `json:",omitempty"`
+	// The JSON Schema of the entity
+	Schema map[string]interface{} `json:",omitempty"`
+}
+
+// Describes a column of a table
+type ColumnMetadata struct {
+	Name   string
+	Type   string
+	Key    bool   `json:",omitempty"`
+	Unique string `json:",omitempty"`
+	Size   int    `json:",omitempty"`
+	// The entity whose id the column holds, declared with a tag: UserId int64 `references:"User"` or `orm:"fk=User"`
+	References string `json:",omitempty"`
+	// The Go field the column stores; empty for the schema version and checksum columns
+	Field string `json:",omitempty"`
+	// The Go type of the field if it is stored as it is, e.g. string or model.Status, for generated codecs; empty for
+	// types with an encoding of their own, like times, floats, pointers, marshalers and Valuers
+	GoType string `json:",omitempty"`
+}
+
+// Describes a secondary index
+type IndexMetadata struct {
+	Name    string
+	Table   string
+	Columns []string
+	Unique  bool `json:",omitempty"`
+	// The condition of a partial index
+	Where string `json:",omitempty"`
+}
+
+var registry = map[string]reflect.Type{}
+
+// Add entities to the metadata registry. CreateTable registers its entity, so this is only needed for entities whose
+// tables are created elsewhere.
+func Register(prototypes ...BlockchainItemizer) {
+	state.Lock()
+	defer state.Unlock()
+	for _, p := range prototypes {
+		t := reflect.TypeOf(p).Elem()
+		registry[t.Name()] = t
+	}
+}
+
+// Describe all registered entities, ordered by name
+func Metadata() []EntityMetadata {
+	var entities []EntityMetadata
+	for _, t := range registered() {
+		entities = append(entities, describe(t))
+	}
+	return entities
+}
+
+// Get the types of all registered entities, ordered by name
+func registered() []reflect.Type {
+	state.RLock()
+	defer state.RUnlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var types []reflect.Type
+	for _, name := range names {
+		types = append(types, registry[name])
+	}
+	return types
+}
+
+// Get the metadata of all registered entities as JSON, the input of cmd/ormdoc
+func MetadataJSON() ([]byte, error) {
+	return json.MarshalIndent(Metadata(), "", "  ")
+}
+
+// Describe the entity of type t
+func describe(t reflect.Type) EntityMetadata {
+	e := EntityMetadata{Name: t.Name(), Table: tableName(t, nil)}
+	for _, f := range fieldsOf(t) {
+		typ, ok := fieldColumnType(f.StructField)
+		if !ok {
+			continue
+		}
+		c := ColumnMetadata{Name: f.column, Type: typ.String(), Key: f.key, Unique: f.Tag.Get("unique"), References: referenceOf(f.StructField)}
+		c.Field, c.GoType = f.Name, plainGoType(f.Type)
+		c.Size, _ = strconv.Atoi(f.Tag.Get("size"))
+		e.Columns = append(e.Columns, c)
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*SchemaVersioner)(nil)).Elem()) {
+		e.Columns = append(e.Columns, ColumnMetadata{Name: schemaVersionColumn, Type: "UINT32"})
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*Checksummer)(nil)).Elem()) {
+		e.Columns = append(e.Columns, ColumnMetadata{Name: checksumColumn, Type: "BYTES"})
+	}
+	indexes, _ := indexesOf(t, nil)
+	for _, idx := range indexes {
+		i := IndexMetadata{Name: idx.name, Table: idx.table, Unique: idx.unique, Where: idx.fields[0].Tag.Get("where")}
+		for _, f := range idx.fields {
+			i.Columns = append(i.Columns, f.Name)
+		}
+		e.Indexes = append(e.Indexes, i)
+	}
+	e.Schema = jsonSchemaOf(t)
+	if policy := policyOf(t); policy != nil {
+		e.Policy = policy.principals()
+	}
+	return e
+}
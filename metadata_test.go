@@ -0,0 +1,46 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub)
+	Register(&TestCompany{}, &TestOrder{})
+	Policy(&TestCompany{}).AllowRead(AnyCaller)
+	defer delete(policies, "TestCompany")
+
+	var indexed, company *EntityMetadata
+	entities := Metadata()
+	for i := range entities {
+		switch entities[i].Name {
+		case "TestIndexed":
+			indexed = &entities[i]
+		case "TestCompany":
+			company = &entities[i]
+		}
+	}
+	if indexed == nil || company == nil {
+		fail(t, "Created and registered entities should be described")
+	}
+	if len(indexed.Columns) != 3 || indexed.Columns[2].Name != "Id" || !indexed.Columns[2].Key || indexed.Columns[0].Type != "STRING" {
+		fail(t, indexed.Columns)
+	}
+	if len(indexed.Indexes) != 1 || indexed.Indexes[0].Table != "TestIndexed_idx_country" {
+		fail(t, indexed.Indexes)
+	}
+	if company.Columns[0].Unique != "registration" || company.Policy["read"][0] != AnyCaller {
+		fail(t, company)
+	}
+	for _, e := range Metadata() {
+		if e.Name == "TestOrder" && e.Columns[0].References != "TestIndexed" {
+			fail(t, "Foreign keys should reference their entity")
+		}
+	}
+	if _, err := MetadataJSON(); err != nil {
+		fail(t, err)
+	}
+}
@@ -0,0 +1,158 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"time"
+)
+
+var trackedModifications = map[string]bool{}
+
+// The last change of a field of an item
+type Modification struct {
+	Field string
+	TxId  string
+	// The name of the caller, empty when the transaction had no certificate
+	Caller string
+	// Zero when the transaction had no time, e.g. in a MockStub
+	Time time.Time
+}
+
+// Record which transaction last changed each field of the items of the table of prototype, and who called it, in the
+// table <Table>_meta. It answers questions like "when did the price last change, and by whom" without going through
+// the history of the ledger. Create records all fields, Update the fields that changed; Delete removes the records.
+// Declare it at initialization of the chaincode, before the table is created.
+func TrackModifications(prototype BlockchainItemizer) {
+	state.Lock()
+	defer state.Unlock()
+	trackedModifications[reflect.TypeOf(prototype).Elem().Name()] = true
+}
+
+// Check whether the modifications of the items of type t are tracked
+func tracksModifications(t reflect.Type) bool {
+	state.RLock()
+	defer state.RUnlock()
+	return trackedModifications[t.Name()]
+}
+
+// Get the last modification of every field of an item, by field name
+func LastModified(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, id int64, opts ...Option) (map[string]Modification, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	if !tracksModifications(t) {
+		return nil, errors.New("Modifications of " + t.Name() + " are not tracked")
+	}
+	name := modificationsTableName(tableName(t, newOptions(stub, opts)))
+
+	modifications := map[string]Modification{}
+	err := scanKey(stub, name, []shim.Column{{Value: &shim.Column_Int64{Int64: id}}}, func(tbl *shim.Table, row shim.Row) error {
+		m := Modification{Field: row.Columns[1].GetString_(), TxId: row.Columns[2].GetString_(), Caller: row.Columns[3].GetString_()}
+		if written := row.Columns[4].GetInt64(); written > 0 {
+			m.Time = time.Unix(written, 0).UTC()
+		}
+		modifications[m.Field] = m
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return modifications, nil
+}
+
+func modificationsTableName(table string) string {
+	return table + "_meta"
+}
+
+// Create the modifications table of type t, if its modifications are tracked
+func createModificationsTable(stub shim.ChaincodeStubInterface, t reflect.Type, o *options) error {
+	if !tracksModifications(t) {
+		return nil
+	}
+	name := modificationsTableName(tableName(t, o))
+	logger.Infof("Create Table %s", name)
+	return stub.CreateTable(name, []*shim.ColumnDefinition{
+		{Name: "Id", Type: shim.ColumnDefinition_INT64, Key: true},
+		{Name: "Field", Type: shim.ColumnDefinition_STRING, Key: true},
+		{Name: "TxId", Type: shim.ColumnDefinition_STRING},
+		{Name: "Caller", Type: shim.ColumnDefinition_STRING},
+		{Name: "Written", Type: shim.ColumnDefinition_INT64},
+	})
+}
+
+// Record the fields of an item that differ from its stored version, or all fields of a new item (old is nil)
+func recordModifications(stub shim.ChaincodeStubInterface, old, new BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(new).Elem()
+	if !tracksModifications(t) {
+		return nil
+	}
+	name := modificationsTableName(tableName(t, o))
+	var fields []string
+	if old == nil {
+		for _, f := range fieldsOf(t) {
+			fields = append(fields, f.Name)
+		}
+	} else {
+		changes, err := Diff(old, new)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			fields = append(fields, c.Field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	caller, err := callerOf(stub)
+	if err != nil {
+		return err
+	}
+	var callerName string
+	if caller != nil {
+		callerName = caller.Name
+	}
+	var written int64
+	if at, err := now(stub, o); err == nil {
+		written = at.Unix()
+	}
+	for _, field := range fields {
+		row := shim.Row{Columns: []*shim.Column{
+			{Value: &shim.Column_Int64{Int64: new.GetId()}},
+			{Value: &shim.Column_String_{String_: field}},
+			{Value: &shim.Column_String_{String_: stub.GetTxID()}},
+			{Value: &shim.Column_String_{String_: callerName}},
+			{Value: &shim.Column_Int64{Int64: written}},
+		}}
+		if ok, err := stub.ReplaceRow(name, row); err != nil {
+			return errors.Wrapf(err, "Could not record modification of %s", field)
+		} else if !ok {
+			if _, err := stub.InsertRow(name, row); err != nil {
+				return errors.Wrapf(err, "Could not record modification of %s", field)
+			}
+		}
+	}
+	return nil
+}
+
+// Remove the modifications of a deleted item
+func deleteModifications(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(item).Elem()
+	if !tracksModifications(t) {
+		return nil
+	}
+	name := modificationsTableName(tableName(t, o))
+	for _, f := range fieldsOf(t) {
+		key := []shim.Column{{Value: &shim.Column_Int64{Int64: item.GetId()}}, {Value: &shim.Column_String_{String_: f.Name}}}
+		if err := stub.DeleteRow(name, key); err != nil {
+			return errors.Wrapf(err, "Could not delete modification of %s", f.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+	"time"
+)
+
+type TestPriced struct {
+	Name  string
+	Price int64
+	Saveable
+}
+
+func TestLastModified(t *testing.T) {
+	TrackModifications(new(TestPriced))
+	defer delete(trackedModifications, "TestPriced")
+	fixed := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	Configure(Config{Clock: FixedClock(fixed)})
+	defer Configure(Config{})
+
+	mock := shim.NewMockStub("cc", new(MockChaincode))
+	mock.MockTransactionStart("create")
+	alice := checkCaller(t, mock, "alice", "Org1")
+	if err := CreateTable(alice, new(TestPriced)); err != nil {
+		fail(t, err)
+	}
+	item := TestPriced{Name: "chair", Price: 100}
+	if err := Create(alice, &item); err != nil {
+		fail(t, err)
+	}
+	mock.MockTransactionStart("reprice")
+	item.Price = 120
+	if err := Update(checkCaller(t, mock, "bob", "Org1"), &item); err != nil {
+		fail(t, err)
+	}
+
+	modifications, err := LastModified(mock, new(TestPriced), 1)
+	if err != nil {
+		fail(t, err)
+	}
+	if m := modifications["Price"]; m.TxId != "reprice" || m.Caller != "bob" || !m.Time.Equal(fixed) {
+		fail(t, "The last change of the price should be recorded")
+	}
+	if m := modifications["Name"]; m.TxId != "create" || m.Caller != "alice" {
+		fail(t, "Fields that didn't change should keep their modification")
+	}
+
+	if err := Delete(mock, &item); err != nil {
+		fail(t, err)
+	}
+	if modifications, err := LastModified(mock, new(TestPriced), 1); err != nil || len(modifications) != 0 {
+		fail(t, "The modifications of a deleted item should be removed")
+	}
+	if _, err := LastModified(mock, new(TestStruct), 1); err == nil {
+		fail(t, "Modifications of untracked types should not be read")
+	}
+}
@@ -0,0 +1,36 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+)
+
+// Builds a query from parameters, e.g. the arguments of an invocation. Parameters parsed from JSON hold numbers as
+// float64; conditions on integer fields accept them.
+type QueryBuilder func(params map[string]interface{}) (*Query, error)
+
+var namedQueries = map[string]QueryBuilder{}
+
+// Register a query under a name, so it can be run from several handlers with RunNamed. Register queries at
+// initialization of the chaincode.
+func RegisterQuery(name string, builder QueryBuilder) {
+	state.Lock()
+	defer state.Unlock()
+	namedQueries[name] = builder
+}
+
+// Run a registered query and set the matching items to a pointer to a slice of the correct type
+func RunNamed(stub shim.ChaincodeStubInterface, name string, params map[string]interface{}, items interface{}, opts ...Option) error {
+	state.RLock()
+	builder, ok := namedQueries[name]
+	state.RUnlock()
+	if !ok {
+		return errors.New("Query " + name + " is not registered")
+	}
+	q, err := builder(params)
+	if err != nil {
+		return errors.Wrap(err, "Could not build query "+name)
+	}
+	logger.Debugf("Running query %s with %v", name, params)
+	return Find(stub, items, q, opts...)
+}
@@ -0,0 +1,30 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestRunNamed(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "b", "c")
+
+	RegisterQuery("testByStr", func(params map[string]interface{}) (*Query, error) {
+		return Where("Str", "=", params["str"]).And("I64", ">=", params["min"]), nil
+	})
+	defer delete(namedQueries, "testByStr")
+
+	var items []TestStruct
+	if err := RunNamed(stub, "testByStr", map[string]interface{}{"str": "b", "min": float64(3)}, &items); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 1 || items[0].I64 != 3 {
+		fail(t, "Named query returned the wrong items")
+	}
+
+	if err := RunNamed(stub, "unknown", nil, &items); err == nil {
+		fail(t, "Running an unregistered query should fail")
+	}
+}
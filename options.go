@@ -0,0 +1,91 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Options change the behavior of a single operation, e.g.
+//
+// err := orm.Get(stub, &user, 1, orm.WithProjection("FirstName"))
+type Option func(*options)
+
+type options struct {
+	// Fields to set when reading. Nil means all fields.
+	projection map[string]bool
+	// The package-wide configuration with the overrides of the operation
+	config Config
+	// Maximum number of rows written by a maintenance operation. 0 means no limit.
+	chunkSize int
+	// The stub of the operation, for the TableNameResolver. Nil when there is none.
+	stub shim.ChaincodeStubInterface
+	// Whether the schema of the table was checked before reading its rows, so StrictSchema checks it once per scan
+	schemaChecked bool
+	// Relations to load when getting an item
+	preload []string
+}
+
+// Collect the options of an operation
+func newOptions(stub shim.ChaincodeStubInterface, opts []Option) *options {
+	o := &options{config: currentConfig(), stub: stub}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Only set the given fields when reading; the others keep their zero value. Key fields (like Id) are always set.
+// Fields used in the conditions of a query are set as well; filters of a query see the projected item.
+func WithProjection(fields ...string) Option {
+	return func(o *options) {
+		if o.projection == nil {
+			o.projection = map[string]bool{}
+		}
+		for _, f := range fields {
+			o.projection[f] = true
+		}
+	}
+}
+
+// Use a different policy for columns without a field than UnknownColumns of the configuration, e.g. to read
+// leniently in an admin query.
+func WithUnknownColumns(policy UnknownColumnPolicy) Option {
+	return func(o *options) {
+		o.config.UnknownColumns = policy
+	}
+}
+
+// Write at most n rows in a maintenance operation like RebuildIndexes, to stay within the limits of a transaction.
+// Repeat the operation in new transactions until it is done.
+func WithChunkSize(n int) Option {
+	return func(o *options) {
+		o.chunkSize = n
+	}
+}
+
+// Check whether a column should be set when reading
+func (o *options) includes(column string, key bool) bool {
+	return o == nil || o.projection == nil || key || o.projection[column]
+}
+
+// Get the policy for columns without a field
+func (o *options) unknownColumnPolicy() UnknownColumnPolicy {
+	return o.conf().UnknownColumns
+}
+
+// Get the configuration that applies to the operation
+func (o *options) conf() Config {
+	if o == nil {
+		return currentConfig()
+	}
+	return o.config
+}
+
+// Get a copy of the options that reads all fields, e.g. to get the stored version of an item
+func (o *options) unprojected() *options {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	c.projection = nil
+	return &c
+}
@@ -0,0 +1,50 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestWithProjection(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b")
+
+	var s TestStruct
+	if err := Get(stub, &s, 1, WithProjection("Str", "Bool")); err != nil {
+		fail(t, err)
+	}
+	if s.Id != 1 || s.Str != "a" || !s.Bool || s.I64 != 0 || s.UI32 != 0 {
+		fail(t, "Only the projected fields and the key should be set")
+	}
+
+	var items []TestStruct
+	if err := Find(stub, &items, Where("I64", "=", 2), WithProjection("Str")); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 1 || items[0].Str != "b" || items[0].I64 != 2 || items[0].UI64 != 0 {
+		fail(t, "Fields of conditions should be set")
+	}
+}
+
+func TestWithUnknownColumns(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateLegacyRow(t, stub)
+
+	var s TestExtraStruct
+	if err := Get(stub, &s, 1, WithUnknownColumns(RejectUnknownColumns)); err == nil {
+		fail(t, "Unknown column should be rejected")
+	}
+	var items []TestExtraStruct
+	if err := GetAll(stub, &items, WithUnknownColumns(CollectUnknownColumns)); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 1 || items[0].Extra["Removed"] != int64(7) {
+		fail(t, "Unknown column should be collected")
+	}
+	if currentConfig().UnknownColumns != SkipUnknownColumns {
+		fail(t, "Options should not change the package-wide policy")
+	}
+}
@@ -11,10 +11,13 @@
 package orm
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/pkg/errors"
+	"math"
 	"reflect"
+	"time"
 )
 
 // Items need to implement this interface to use ORM. You can use an anonymous Saveable in your struct.
@@ -33,172 +36,436 @@ func (s *Saveable) SetId(id int64) { s.Id = id }
 //
 var columnDefinitions = map[string]shim.ColumnDefinition_Type {
 	"bool": shim.ColumnDefinition_BOOL,
-	//"[]uint8": shim.ColumnDefinition_BYTES, // TODO
 	"int32": shim.ColumnDefinition_INT32,
 	"int64": shim.ColumnDefinition_INT64,
 	"string": shim.ColumnDefinition_STRING,
 	"uint32": shim.ColumnDefinition_UINT32,
 	"uint64": shim.ColumnDefinition_UINT64,
-	"Saveable": shim.ColumnDefinition_INT64, // Id field (TODO: recursively find subfields of anonymous fields)
 }
 
-var logger = shim.NewLogger("orm")
+var logger Logger = configuredLogger{}
 
-// Create a table of the passed item. Types are automatically inferred.
-func CreateTable(stub shim.ChaincodeStubInterface, item BlockchainItemizer) error {
-	name := reflect.TypeOf(item).Elem().Name()
+// Returned by Get and Delete when there is no item with the given id.
+var ErrNotFound = errors.New("Item not found.")
+
+// Create a table of the passed item. Types are automatically inferred. Tables for the indexes declared with
+// `index:"<name>"` tags are created as well.
+func CreateTable(stub shim.ChaincodeStubInterface, item BlockchainItemizer, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	o := newOptions(stub, opts)
+	if err := checkNamespace(o); err != nil {
+		return err
+	}
+	name := tableName(reflect.TypeOf(item).Elem(), o)
 	logger.Infof("Create Table %s", name)
+	if err := auditFields(reflect.TypeOf(item).Elem(), o); err != nil {
+		return err
+	}
 
-	cds := createColumnDefinitions(item)
+	cds, err := tableDefinitions(item, o)
+	if err != nil {
+		return err
+	}
 	logger.Debugf("Columns: %v", cds)
-	return stub.CreateTable(name, cds)
+	if err := stub.CreateTable(name, cds); err != nil {
+		return err
+	}
+	Register(item)
+	if err := createIndexTables(stub, reflect.TypeOf(item).Elem(), o); err != nil {
+		return err
+	}
+	if err := createVersionsTable(stub, reflect.TypeOf(item).Elem(), o); err != nil {
+		return err
+	}
+	if err := createModificationsTable(stub, reflect.TypeOf(item).Elem(), o); err != nil {
+		return err
+	}
+	return createUniqueTables(stub, reflect.TypeOf(item).Elem(), o)
 }
 
 // Get an item by Id
-func Get(stub shim.ChaincodeStubInterface, item BlockchainItemizer, id int64) error {
-	if (id == 0) {
-		return errors.New("Id should be larger than 0")
+func Get(stub shim.ChaincodeStubInterface, item BlockchainItemizer, id int64, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
 	}
-
-	// Query
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_Int64{Int64: id}} // How to set key?
-	columns = append(columns, col1)
-
-	// Table / Item name
-	name := reflect.TypeOf(item).Elem().Name()
-
-	// Get table
-	if tbl, err := stub.GetTable(name); err != nil {
-		return errors.Wrap(err, "Could not get table "+name)
-
-	// Get row based on query
-	} else if row, err := stub.GetRow(name, columns); err != nil {
-		return errors.Wrap(err, "Could not get "+name+" with id "+string(id))
-
-	// Set values of item based on row values
-	} else if err = setValues(tbl, row, item); err != nil {
-		return errors.Wrap(err, "Error setting values")
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	o := newOptions(stub, opts)
+	if err := get(stub, item, id, o); err != nil {
+		return err
 	}
 
 	if (item.GetId() == 0) {
-		return errors.New("Item not found.")
+		return ErrNotFound
+	}
+	if err := authorize(stub, "read", item); err != nil {
+		return err
+	}
+	if err := preload(stub, item, o); err != nil {
+		return err
 	}
 
 	logger.Debugf("Got item %v", item)
 	return nil
 }
 
-// Get all items by passing a slice of the correct type
-func GetAll(stub shim.ChaincodeStubInterface, items interface{}) error {
-	v := reflect.ValueOf(items).Elem()
-	if v.Kind() != reflect.Slice {
-		return errors.New("Object passed to GetAll should be a slice.")
+// Set the values of the row with the given id to the item. The id of the item stays 0 if there is no such row.
+func get(stub shim.ChaincodeStubInterface, item BlockchainItemizer, id int64, o *options) error {
+	if (id == 0) {
+		return errors.New("Id should be larger than 0")
+	}
+	if t := reflect.TypeOf(item).Elem(); !keyedById(t) {
+		return errors.New(t.Name() + " is not keyed by id alone; use GetByKeys")
 	}
 
-	t := reflect.TypeOf(items).Elem().Elem()
-	name := t.Name();
+	// Query
+	var columns []shim.Column
+	col1 := shim.Column{Value: &shim.Column_Int64{Int64: id}}
+	columns = append(columns, col1)
 
-	//logger.Debugf("Getting all %vs", name)
+	return getByKey(stub, item, columns, o)
+}
 
-	// Query (TODO)
-	columns := []shim.Column{
-	//	shim.Column{Value: &shim.Column_Int64{Int64: 1}},
-	//	shim.Column{Value: &shim.Column_Int64{Int64: 2}},
+// Get the stored version of an item of type t, or nil if it doesn't exist
+func getStored(stub shim.ChaincodeStubInterface, t reflect.Type, id int64, o *options) (BlockchainItemizer, error) {
+	stored := reflect.New(t).Interface().(BlockchainItemizer)
+	if err := get(stub, stored, id, o.unprojected()); err != nil {
+		return nil, err
 	}
-
-	tbl, err := stub.GetTable(name)
-	if err != nil {
-		return errors.Wrap(err, "Could not get table "+name)
+	if stored.GetId() == 0 {
+		return nil, nil
 	}
+	return stored, nil
+}
 
-	rowChannel, err := stub.GetRows(name, columns)
-	if err != nil {
-		return fmt.Errorf("getRows operation failed. %s", err)
+// Get all items by passing a slice of the correct type. When the stub is a Session, the limits of the session apply:
+// when they are exceeded, the items read so far are set and ErrResultTruncated is returned.
+func GetAll(stub shim.ChaincodeStubInterface, items interface{}, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
 	}
-	for {
-		select {
-		case row, ok := <-rowChannel:
-			if !ok {
-				rowChannel = nil
-			} else {
-				logger.Debugf("Columns: %v", row.Columns)
-				item := reflect.New(t).Interface()
+	if err := checkSlice(items); err != nil {
+		return errors.Wrap(err, "Object passed to GetAll should be a pointer to a slice")
+	}
+	v := reflect.ValueOf(items).Elem()
 
-				if err:= setValues(tbl, row, item); err != nil {
-					return errors.Wrap(err, "Error setting values.")
-				}
+	t := reflect.TypeOf(items).Elem().Elem()
 
-				logger.Debugf("Adding item: %v", item)
-				v.Set(reflect.Append(v, reflect.ValueOf(item).Elem()))
-			}
-		}
-		if rowChannel == nil {
-			break
+	o := newOptions(stub, opts)
+	if !o.conf().SortResults {
+		if err := auditDeterminism(o, "GetAll of %s without SortResults", t.Name()); err != nil {
+			return err
 		}
 	}
-	return nil
+	err := scan(stub, t, o, func(item interface{}) error {
+		logger.Debugf("Adding item: %v", item)
+		v.Set(reflect.Append(v, reflect.ValueOf(item).Elem()))
+		return nil
+	})
+	if o.conf().SortResults && (err == nil || err == ErrResultTruncated) {
+		sortItems(v)
+	}
+	return err
 }
 
 // Insert a row for the item in the database
-func Create(stub shim.ChaincodeStubInterface, item BlockchainItemizer) error {
+func Create(stub shim.ChaincodeStubInterface, item BlockchainItemizer, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	o := newOptions(stub, opts)
 	t := reflect.TypeOf(item).Elem()
 	v := reflect.ValueOf(item).Elem()
-	logger.Infof("Creating %v: %v", t.Name(), v)
+	name := tableName(t, o)
+	logger.Infof("Creating %v: %v", name, v)
+
+	// The item has been created before in this session
+	session := sessionOf(stub)
+	if item.GetId() != 0 && session.lastWrite(name, item.GetId()) == created {
+		if session.MergeRepeatedWrites {
+			return Update(stub, item, opts...)
+		}
+		return errors.Wrapf(ErrRepeatedWrite, "%s %d was already created", name, item.GetId())
+	}
+	if err := authorize(stub, "create", item); err != nil {
+		return err
+	}
 
-	if id, err := generateId(stub, t.Name()); err != nil {
+	if id, err := nextId(stub, name, o); err != nil {
 		return errors.Wrap(err, "Generate id failed.")
 	} else {
 		item.SetId(id)
 	}
-
-	if row, err := createRow(t, v); err != nil {
+	if err := setRef(stub, item, o); err != nil {
 		return err
-	} else {
-		_, err := stub.InsertRow(t.Name(), row)
+	}
+	if err := prepareWrite(item, o); err != nil {
 		return err
 	}
+	return insertItem(stub, name, item, o)
 }
 
 // Update an item
-func Update(stub shim.ChaincodeStubInterface, item BlockchainItemizer) error {
+func Update(stub shim.ChaincodeStubInterface, item BlockchainItemizer, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	o := newOptions(stub, opts)
 	t := reflect.TypeOf(item).Elem()
 	v := reflect.ValueOf(item).Elem()
-	logger.Infof("Updating %v: %v", t.Name(), v)
+	name := tableName(t, o)
+	logger.Infof("Updating %v: %v", name, v)
 
-	if item.GetId() == 0 {
-		return errors.New("Item cannot have id 0")
+	if _, ok := item.(EventSourcer); ok {
+		return ErrEventSourced
 	}
 
-	if row, err := createRow(t, v); err != nil {
+	if err := prepareWrite(item, o); err != nil {
 		return err
-	} else {
-		_, err := stub.ReplaceRow(t.Name(), row)
+	}
+	if sessionOf(stub).lastWrite(name, item.GetId()) == deleted {
+		return errors.Wrapf(ErrRepeatedWrite, "%s %d was deleted", name, item.GetId())
+	}
+
+	stored, err := getStoredItem(stub, item, o)
+	if err != nil {
+		return err
+	} else if stored == nil {
+		_, err := writeRow(stub, name, item, false)
+		return err // ReplaceRow doesn't insert rows that don't exist
+	}
+	item.SetId(stored.GetId()) // Items with a natural key can be updated without their id
+	if err := authorize(stub, "update", stored); err != nil {
+		return err
+	}
+	return replaceItem(stub, name, stored, item, o)
+}
+
+// Check and normalize an item before its row is written: apply the transforms and sizes of its fields, and check its
+// enums and key
+func prepareWrite(item BlockchainItemizer, o *options) error {
+	if err := transformWrites(item); err != nil {
+		return err
+	}
+	if err := applySizes(item, o); err != nil {
+		return err
+	}
+	if err := checkEnums(item); err != nil {
+		return err
+	}
+	return checkKey(item)
+}
+
+// Insert the row of a new, prepared item with its index rows, version and modifications
+func insertItem(stub shim.ChaincodeStubInterface, name string, item BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(item).Elem()
+	if err := updateUniques(stub, nil, item, o); err != nil {
+		return err
+	}
+	if ok, err := writeRow(stub, name, item, true); err != nil {
+		return err
+	} else if !ok && keyedById(t) {
+		return errors.Errorf("%s with id %d already exists", name, item.GetId())
+	} else if !ok {
+		key, _ := keyOf(item)
+		return errors.Errorf("%s with key %v already exists", name, columnValues(key))
+	}
+	if err := insertIndexes(stub, item, o); err != nil {
+		return err
+	}
+	if err := recordVersion(stub, item, o); err != nil {
+		return err
+	}
+	if err := recordModifications(stub, nil, item, o); err != nil {
+		return err
+	}
+	sessionOf(stub).record(name, item.GetId(), created)
+	return emitEvent(stub, name, "Created", item, o)
+}
+
+// Replace the row of a stored item by that of the prepared item, with its index rows, version and modifications
+func replaceItem(stub shim.ChaincodeStubInterface, name string, stored, item BlockchainItemizer, o *options) error {
+	if err := checkRef(stub, item, o); err != nil {
+		return err
+	}
+	if err := updateUniques(stub, stored, item, o); err != nil {
+		return err
+	}
+	if _, err := writeRow(stub, name, item, false); err != nil {
+		return err
+	}
+	if err := updateIndexes(stub, stored, item, o); err != nil {
+		return err
+	}
+	if err := recordVersion(stub, item, o); err != nil {
+		return err
+	}
+	if err := recordModifications(stub, stored, item, o); err != nil {
 		return err
 	}
+	if session := sessionOf(stub); session.lastWrite(name, item.GetId()) != created {
+		session.record(name, item.GetId(), updated)
+	}
+	return emitEvent(stub, name, "Updated", item, o)
+}
 
+// Write the sealed row of an item, inserting or replacing it. Returns whether the row was written.
+func writeRow(stub shim.ChaincodeStubInterface, name string, item BlockchainItemizer, insert bool) (bool, error) {
+	row, err := createRow(reflect.TypeOf(item).Elem(), reflect.ValueOf(item).Elem())
+	if err != nil {
+		return false, err
+	}
+	if row, err = sealRow(stub, name, item, row); err != nil {
+		return false, err
+	}
+	if insert {
+		return stub.InsertRow(name, row)
+	}
+	return stub.ReplaceRow(name, row)
 }
 
-// Delete an item
-func Delete(stub shim.ChaincodeStubInterface, item BlockchainItemizer) error {
+// Delete an item. Returns ErrNotFound if the item doesn't exist. Items of registered types that refer to it with a
+// foreign key tagged ondelete=cascade are deleted with it.
+func Delete(stub shim.ChaincodeStubInterface, item BlockchainItemizer, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	o := newOptions(stub, opts)
 	t := reflect.TypeOf(item).Elem()
 	v := reflect.ValueOf(item).Elem()
-	logger.Infof("Deleting %v: %v", t.Name(), v)
+	name := tableName(t, o)
+	logger.Infof("Deleting %v: %v", name, v)
+
+	if _, ok := item.(EventSourcer); ok {
+		return ErrEventSourced
+	}
 
-	if item.GetId() == 0 {
-		return errors.New("Item cannot have id 0")
+	if err := checkKey(item); err != nil {
+		return err
 	}
 
-	columns := []shim.Column {
-		shim.Column{Value: &shim.Column_Int64{Int64: item.GetId()}},
+	columns, err := keyOf(item)
+	if err != nil {
+		return err
 	}
 
-	return stub.DeleteRow(t.Name(), columns)
+	// DeleteRow doesn't tell whether the row existed. The stored values are needed to clean up the indexes.
+	stored, err := getStoredItem(stub, item, o)
+	if err != nil {
+		return err
+	} else if stored == nil {
+		return ErrNotFound
+	}
+	if err := authorize(stub, "delete", stored); err != nil {
+		return err
+	}
+	if err := updateUniques(stub, stored, nil, o); err != nil {
+		return err
+	}
+
+	if err := stub.DeleteRow(name, columns); err != nil {
+		return err
+	}
+	if err := deleteIndexes(stub, stored, o); err != nil {
+		return err
+	}
+	if err := deleteModifications(stub, stored, o); err != nil {
+		return err
+	}
+	if err := deleteVersions(stub, stored, o); err != nil {
+		return err
+	}
+	if err := deleteChildren(stub, stored, o, opts); err != nil {
+		return err
+	}
+	sessionOf(stub).record(name, stored.GetId(), deleted)
+	return emitEvent(stub, name, "Deleted", stored, o)
+}
+
+// Call fn for every row in the table of type t, with a new item (pointer to t) holding the values of the row. Items
+// the caller may not read are skipped.
+func scan(stub shim.ChaincodeStubInterface, t reflect.Type, o *options, fn func(item interface{}) error) error {
+	authorizer, err := authorizerOf(stub, t, "read")
+	if err != nil {
+		return err
+	}
+	key, err := checksumKeyOf(stub, t)
+	if err != nil {
+		return err
+	}
+	sessionOf(stub).countScan(tableName(t, o))
+	var read *options
+	return scanRows(stub, tableName(t, o), func(tbl *shim.Table, row shim.Row) error {
+		if err := verifyRow(key, tbl, row); err != nil {
+			return err
+		}
+		if read == nil {
+			if read, err = checkSchemaOnce(tbl, t, o); err != nil {
+				return err
+			}
+		}
+		item := reflect.New(t).Interface()
+
+		if err := setValues(tbl, row, item, read); err != nil {
+			return errors.Wrap(err, "Error setting values.")
+		}
+		if authorizer.check(item) != nil {
+			return nil
+		}
+		return fn(item)
+	})
+}
+
+// Call fn for every row in a table. When the stub is a Session, the limits of the session apply.
+func scanRows(stub shim.ChaincodeStubInterface, name string, fn func(tbl *shim.Table, row shim.Row) error) error {
+	return scanKey(stub, name, []shim.Column{}, fn)
 }
 
+// Call fn for every row in a table that starts with the given key columns
+func scanKey(stub shim.ChaincodeStubInterface, name string, key []shim.Column, fn func(tbl *shim.Table, row shim.Row) error) error {
+	tbl, err := stub.GetTable(name)
+	if err != nil {
+		return errors.Wrap(err, "Could not get table "+name)
+	}
+
+	rowChannel, err := stub.GetRows(name, key)
+	if err != nil {
+		return fmt.Errorf("getRows operation failed. %s", err)
+	}
+	session, started, rows := sessionOf(stub), time.Now(), 0
+	defer func() { session.countRead(rows) }()
+	rowChannel, stop := prefetch(stub, rowChannel, prefetchOf(stub))
+	defer stop()
+	for row := range rowChannel {
+		if err := session.guard(rows, started); err != nil {
+			return err
+		}
+		rows++
+		logger.Debugf("Columns: %v", row.Columns)
+		if err := fn(tbl, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
 // Set the values of a retrieved row to an item
-func setValues(tbl *shim.Table, row shim.Row, item interface{}) error {
+func setValues(tbl *shim.Table, row shim.Row, item interface{}, o *options) error {
 	v := reflect.Indirect(reflect.ValueOf(item))
 	if !v.IsValid() {
 		return errors.New("Zero value passed to setValues")
@@ -206,131 +473,281 @@ func setValues(tbl *shim.Table, row shim.Row, item interface{}) error {
 		return errors.New("Cannot set item")
 	}
 
+	if o.conf().StrictSchema && len(row.Columns) > 0 && (o == nil || !o.schemaChecked) {
+		if err := checkSchema(tbl, item, o); err != nil {
+			return err
+		}
+	}
+
+	versioner, versioned := item.(SchemaVersioner)
+	_, checksummed := item.(Checksummer)
+	if versioned {
+		if stored, ok := storedSchemaVersion(tbl, row); ok && stored != versioner.SchemaVersion() {
+			if err := setAdaptedValues(tbl, row, v, stored, versioner.SchemaVersion(), o); err != nil {
+				return err
+			}
+			return transformReads(v, o)
+		}
+	}
+
+	if codec, ok := codecOf(v.Type()); ok && len(row.Columns) > 0 && (o == nil || o.projection == nil) {
+		extra := 0
+		if versioned {
+			extra++
+		}
+		if checksummed {
+			extra++
+		}
+		if decodesTable(tbl, v.Type(), extra) && len(row.Columns) == len(tbl.ColumnDefinitions) {
+			n := len(row.Columns) - extra
+			if err := codec.Unmarshal(row.Columns[:n], v.Addr().Interface().(BlockchainItemizer)); err != nil {
+				return errors.Wrap(err, "Could not unmarshal "+tbl.Name)
+			}
+			return transformReads(v, o)
+		}
+	}
+
 	// Get the column names and set the value based on the row values
+	fields := fieldsByColumn(v.Type())
 	for i, c := range row.GetColumns() {
 		name := tbl.ColumnDefinitions[i].Name
 		fieldType := tbl.ColumnDefinitions[i].Type //ColumnDefinition_Type
 		logger.Debugf("[%v] %v = %v", fieldType, name, c.GetValue())
-		f := v.FieldByName(name)
+		if versioned && name == schemaVersionColumn || checksummed && name == checksumColumn {
+			continue
+		}
+		field, ok := fields[name]
+		if !ok {
+			if err := unknownColumn(v, name, columnValue(c), o); err != nil {
+				return err
+			}
+			continue
+		}
+		if !o.includes(field.Name, tbl.ColumnDefinitions[i].Key) {
+			continue
+		}
+		f := v.FieldByIndex(field.Index)
+		if !decodable(fieldType, f.Type()) {
+			return errors.Errorf("Column %s of type %s cannot be set to field of type %v", name, fieldType, f.Type())
+		}
+		if isValuer(f.Type()) {
+			if err := fromColumn(f, c); err != nil {
+				return errors.Wrap(err, "Could not set "+name)
+			}
+			continue
+		}
 
 		switch fieldType {
 		case shim.ColumnDefinition_BOOL:
 			f.SetBool(c.GetBool())
 			break
 		case shim.ColumnDefinition_BYTES:
-			f.SetBytes(c.GetBytes())
+			if isBinary(f.Type()) {
+				if err := fromBinary(f, c.GetBytes()); err != nil {
+					return errors.Wrap(err, "Could not set "+name)
+				}
+			} else if isNullable(f.Type()) {
+				if err := fromNullable(f, c.GetBytes()); err != nil {
+					return errors.Wrap(err, "Could not set "+name)
+				}
+			} else if isJSON(f.Type()) {
+				p := reflect.New(f.Type())
+				if b := c.GetBytes(); len(b) > 0 {
+					if err := json.Unmarshal(b, p.Interface()); err != nil {
+						return errors.Wrap(err, "Could not set "+name)
+					}
+				}
+				f.Set(p.Elem())
+			} else if b := c.GetBytes(); len(b) > 0 {
+				f.SetBytes(append([]byte{}, b...))
+			} else {
+				f.SetBytes(nil)
+			}
 			break
 		case shim.ColumnDefinition_INT32:
 			f.SetInt(int64(c.GetInt32()))	 // ???
 			break
 		case shim.ColumnDefinition_INT64:
-			f.SetInt(c.GetInt64())
+			if f.Type() == timeType {
+				f.Set(reflect.ValueOf(timeOf(c.GetInt64())))
+			} else {
+				f.SetInt(c.GetInt64())
+			}
 			break
 		case shim.ColumnDefinition_STRING:
-			f.SetString(c.GetString_())
+			if isText(f.Type()) {
+				if err := fromText(f, c.GetString_()); err != nil {
+					return errors.Wrap(err, "Could not set "+name)
+				}
+			} else {
+				f.SetString(c.GetString_())
+			}
 			break
 		case shim.ColumnDefinition_UINT32:
 			f.SetUint(uint64(c.GetUint32())) // ???
 			break
 		case shim.ColumnDefinition_UINT64:
-			f.SetUint(c.GetUint64())
+			if isFloat(f.Type()) {
+				f.SetFloat(math.Float64frombits(c.GetUint64()))
+			} else {
+				f.SetUint(c.GetUint64())
+			}
 			break
 		default:
 			return errors.New("Type " + fieldType.String() + " not recognized.")
 		}
 	}
-	return nil
+	if len(row.Columns) == 0 {
+		return nil
+	}
+	return transformReads(v, o)
 }
 
 // Create a row
 func createRow(t reflect.Type, v reflect.Value) (shim.Row, error) {
 	row := shim.Row{}
-	for i := 0; i < t.NumField(); i++ {
-		f := v.Field(i);
-		if !f.CanSet() {
-			continue // Field not exported?
+	if codec, ok := codecOf(t); ok {
+		columns, err := marshalRow(codec, t, v)
+		if err != nil {
+			return row, err
 		}
-		if column, err := createColumnValue(t.Field(i), f.Interface()); err != nil {
-			return row, errors.Wrap(err, "Create item failed - Can't create column value")
-		} else {
-			row.Columns = append(row.Columns, &column)
+		row.Columns = columns
+	} else {
+		for _, field := range fieldsOf(t) {
+			f := v.FieldByIndex(field.Index)
+			if column, err := createColumnValue(field.StructField, f.Interface()); err != nil {
+				return row, errors.Wrap(err, "Create item failed - Can't create column value")
+			} else {
+				row.Columns = append(row.Columns, &column)
+			}
 		}
 	}
+	if versioner, ok := v.Addr().Interface().(SchemaVersioner); ok {
+		row.Columns = append(row.Columns, &shim.Column{Value: &shim.Column_Uint32{Uint32: versioner.SchemaVersion()}})
+	}
 	return row, nil
 }
 
 
-// Create definitions for the table that will be created.
-func createColumnDefinitions(iface interface{}) []*shim.ColumnDefinition {
+// Create definitions for the table that will be created
+func createColumnDefinitions(iface interface{}, o *options) ([]*shim.ColumnDefinition, error) {
 	defs := make([]*shim.ColumnDefinition, 0)
 	t := reflect.TypeOf(iface).Elem()
-	v := reflect.ValueOf(iface).Elem()
+	if err := checkFields(t); err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < t.NumField(); i++ {
-		if !v.Field(i).CanSet() {
-			continue // Field not exported?
-		}
-		f := t.Field(i)
+	for _, f := range fieldsOf(t) {
 		logger.Debugf("field: %v", f)
-
-		isKey := f.Tag.Get("key") == "true"
-		name := f.Name
-
-		if typ, ok := columnDefinitions[t.Field(i).Type.Name()]; ok {
-			// FIXME: this is a hack. Should be solved recursively
-			if t.Field(i).Type.Name() == "Saveable" {
-				name = "Id"
-				isKey = true
-			}
-			defs = append(defs, &shim.ColumnDefinition{Name: name, Type: typ, Key: isKey})
-		} else {
-			logger.Errorf("Field type not recognized: %v %v", f.Name, t.Field(i).Type.Name())
-		}
+		typ, _ := fieldColumnType(f.StructField)
+		defs = append(defs, &shim.ColumnDefinition{Name: f.column, Type: typ, Key: f.key})
 	}
 
-	return defs
+	return defs, nil
 }
 
 // Set the value of a field
 func createColumnValue(field reflect.StructField, val interface{}) (shim.Column, error) {
-	switch field.Type.Name() {
-	case "bool":
-		return shim.Column{Value: &shim.Column_Bool{Bool: val.(bool)}}, nil
-	// case "[]uint8":
-	// 	return shim.Column{Value: &shim.Column_Bytes{Bytes: val.([]uint8)}}, nil // TODO
-	case "int32":
-		return shim.Column{Value: &shim.Column_Int32{Int32: val.(int32)}}, nil
-	case "int64":
-		return shim.Column{Value: &shim.Column_Int64{Int64: val.(int64)}}, nil
-	case "string":
-		return shim.Column{Value: &shim.Column_String_{String_: val.(string)}}, nil
-	case "uint32":
-		return shim.Column{Value: &shim.Column_Uint32{Uint32: val.(uint32)}}, nil
-	case "uint64":
-		return shim.Column{Value: &shim.Column_Uint64{Uint64: val.(uint64)}}, nil
-	case "Saveable":
-		return shim.Column{Value: &shim.Column_Int64{Int64: val.(Saveable).Id}}, nil //FIXME
-
+	if isValuer(field.Type) {
+		return toColumn(reflect.ValueOf(val))
+	}
+	if t, ok := val.(time.Time); ok && field.Type == timeType {
+		if typ, _ := fieldColumnType(field); typ == shim.ColumnDefinition_STRING {
+			return shim.Column{Value: &shim.Column_String_{String_: t.UTC().Format(time.RFC3339Nano)}}, nil
+		}
+		return shim.Column{Value: &shim.Column_Int64{Int64: nanosOf(t)}}, nil
+	}
+	if isNullable(field.Type) {
+		data, err := toNullable(reflect.ValueOf(val))
+		if err != nil {
+			return shim.Column{}, errors.Wrapf(err, "Could not marshal %s", field.Name)
+		}
+		return shim.Column{Value: &shim.Column_Bytes{Bytes: data}}, nil
+	}
+	if isFloat(field.Type) {
+		return shim.Column{Value: &shim.Column_Uint64{Uint64: math.Float64bits(reflect.ValueOf(val).Float())}}, nil
+	}
+	if _, builtin := columnDefinitions[field.Type.Name()]; !builtin && isText(field.Type) {
+		text, err := toText(reflect.ValueOf(val))
+		if err != nil {
+			return shim.Column{}, err
+		}
+		return shim.Column{Value: &shim.Column_String_{String_: text}}, nil
+	}
+	if _, builtin := columnDefinitions[field.Type.Name()]; !builtin && isBinary(field.Type) {
+		data, err := toBinary(reflect.ValueOf(val))
+		if err != nil {
+			return shim.Column{}, err
+		}
+		return shim.Column{Value: &shim.Column_Bytes{Bytes: data}}, nil
+	}
+	if isBytes(field.Type) {
+		return shim.Column{Value: &shim.Column_Bytes{Bytes: reflect.ValueOf(val).Bytes()}}, nil
+	}
+	if isJSON(field.Type) {
+		data, err := json.Marshal(val)
+		if err != nil {
+			return shim.Column{}, errors.Wrapf(err, "Could not marshal %s", field.Name)
+		}
+		return shim.Column{Value: &shim.Column_Bytes{Bytes: data}}, nil
+	}
+	// By kind, so named types like enums are stored as well
+	v := reflect.ValueOf(val)
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		return shim.Column{Value: &shim.Column_Bool{Bool: v.Bool()}}, nil
+	case reflect.Int32:
+		return shim.Column{Value: &shim.Column_Int32{Int32: int32(v.Int())}}, nil
+	case reflect.Int64:
+		return shim.Column{Value: &shim.Column_Int64{Int64: v.Int()}}, nil
+	case reflect.String:
+		return shim.Column{Value: &shim.Column_String_{String_: v.String()}}, nil
+	case reflect.Uint32:
+		return shim.Column{Value: &shim.Column_Uint32{Uint32: uint32(v.Uint())}}, nil
+	case reflect.Uint64:
+		return shim.Column{Value: &shim.Column_Uint64{Uint64: v.Uint()}}, nil
 	}
 	return shim.Column{}, errors.New("Type of " + field.Type.Name() + " not recognized.")
 }
 
-// Generates an id that's one higher than the latest update.
-// FIXME: race condition when creating multiple items in one call
+// Generates an id that's one higher than the highest id in the table. The id of a deleted item with the highest id is
+// given out again; NextId keeps a counter instead.
 func generateId(stub shim.ChaincodeStubInterface, tableName string) (int64, error) {
+	tbl, err := stub.GetTable(tableName)
+	if err != nil {
+		return 0, errors.Wrap(err, "Could not get table "+tableName)
+	}
+	idColumn := -1
+	for i, cd := range tbl.ColumnDefinitions {
+		if cd.Name == "Id" {
+			idColumn = i
+		}
+	}
+	if idColumn == -1 {
+		return 0, errors.New("Table " + tableName + " has no Id column")
+	}
+
 	rowChannel, err := stub.GetRows(tableName, []shim.Column{})
 	if err != nil {
 		return 0, fmt.Errorf("getRows operation failed. %s", err)
 	}
+	defer drain(rowChannel)
 	id := int64(0)
+	session, started, rows := sessionOf(stub), time.Now(), 0
+	defer func() { session.countRead(rows) }()
 	for {
 		select {
 		case row, ok := <-rowChannel:
 			if !ok {
 				rowChannel = nil
 			} else {
+				// A truncated scan would generate an id that is already taken
+				if err := session.guard(rows, started); err != nil {
+					return 0, errors.Wrap(err, "Could not read all ids")
+				}
+				rows++
 				logger.Debugf("Columns: %v", row.Columns)
-				if val := row.Columns[0].GetInt64(); val > id {
+				if val := row.Columns[idColumn].GetInt64(); val > id {
 					id = val
 				}
 			}
@@ -342,4 +759,4 @@ func generateId(stub shim.ChaincodeStubInterface, tableName string) (int64, erro
 	id++
 	logger.Debugf("Generated id %d for %s", id, tableName)
 	return id, nil
-}
\ No newline at end of file
+}
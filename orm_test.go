@@ -127,6 +127,16 @@ func TestGetShouldFail(t *testing.T) {
 	}
 }
 
+func TestGetNotFound(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	var s TestStruct
+	if err := Get(stub, &s, 10000); err != ErrNotFound {
+		fail(t, "Get should return ErrNotFound with non existing Id")
+	}
+}
+
 
 func checkGetAll(t *testing.T, stub shim.ChaincodeStubInterface) []TestStruct {
 	var items []TestStruct
@@ -183,6 +193,16 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteShouldFail(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	s := getTestStruct()
+	s.Id = 10000
+	if err := Delete(stub, &s); err != ErrNotFound {
+		fail(t, "Delete should return ErrNotFound with non existing Id")
+	}
+}
 
 //Mock not working correctly!
 //func TestGetAll(t *testing.T) {
@@ -0,0 +1,171 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"time"
+)
+
+// Get a page of all items by passing a pointer to a slice of the correct type: at most limit items, after skipping
+// offset items. Only the items of the page are kept in memory, but the rows of the pages before it are still read;
+// use an Iterator to go through a large table once.
+func GetPage(stub shim.ChaincodeStubInterface, items interface{}, offset, limit int, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if err := checkSlice(items); err != nil {
+		return errors.Wrap(err, "Object passed to GetPage should be a pointer to a slice")
+	}
+	if offset < 0 || limit <= 0 {
+		return errors.Errorf("Invalid page of %d items after %d", limit, offset)
+	}
+	v := reflect.ValueOf(items).Elem()
+	t := v.Type().Elem()
+
+	o := newOptions(stub, opts)
+	if !o.conf().SortResults {
+		if err := auditDeterminism(o, "GetPage of %s without SortResults", t.Name()); err != nil {
+			return err
+		}
+	}
+	skipped := 0
+	err := scan(stub, t, o, func(item interface{}) error {
+		if skipped < offset {
+			skipped++
+			return nil
+		}
+		v.Set(reflect.Append(v, reflect.ValueOf(item).Elem()))
+		if v.Len() >= limit {
+			return errStopScan
+		}
+		return nil
+	})
+	if err == errStopScan {
+		err = nil
+	}
+	if o.conf().SortResults && (err == nil || err == ErrResultTruncated) {
+		sortItems(v)
+	}
+	return err
+}
+
+// Goes through all items of a table one at a time, without reading them all into memory:
+//
+// it, err := orm.GetAllIter(stub, &Asset{})
+// if err != nil {
+// 	return err
+// }
+// defer it.Close()
+// var asset Asset
+// for it.Next(&asset) {
+// 	...
+// }
+// err = it.Err()
+//
+// Items the caller may not read are skipped. When the stub is a Session, the limits of the session apply.
+type Iterator struct {
+	stub       shim.ChaincodeStubInterface
+	t          reflect.Type
+	o          *options
+	tbl        *shim.Table
+	key        []byte
+	authorizer *authorizer
+	rows       <-chan shim.Row
+	stop       func()
+	started    time.Time
+	read       int
+	err        error
+}
+
+// Start going through all items of the type of prototype. Close the iterator when done, also when stopping early.
+func GetAllIter(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, opts ...Option) (*Iterator, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	it := &Iterator{stub: stub, t: reflect.TypeOf(prototype).Elem(), o: newOptions(stub, opts), started: time.Now()}
+	var err error
+	if it.authorizer, err = authorizerOf(stub, it.t, "read"); err != nil {
+		return nil, err
+	}
+	if it.key, err = checksumKeyOf(stub, it.t); err != nil {
+		return nil, err
+	}
+	name := tableName(it.t, it.o)
+	if it.tbl, err = stub.GetTable(name); err != nil {
+		return nil, errors.Wrap(err, "Could not get table "+name)
+	}
+	sessionOf(stub).countScan(name)
+	rows, err := stub.GetRows(name, []shim.Column{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read "+name)
+	}
+	it.rows, it.stop = prefetch(stub, rows, prefetchOf(stub))
+	return it, nil
+}
+
+// Set the next item to item, a pointer to the type of the iterator. Returns false when there are no more items or
+// reading failed; check Err.
+func (it *Iterator) Next(item BlockchainItemizer) bool {
+	if it.err != nil || it.stop == nil {
+		return false
+	}
+	v := reflect.ValueOf(item)
+	if v.Type() != reflect.PtrTo(it.t) {
+		it.fail(errors.Errorf("Cannot set %v to %v", it.t, v.Type()))
+		return false
+	}
+	for row := range it.rows {
+		if err := sessionOf(it.stub).guard(it.read, it.started); err != nil {
+			it.fail(err)
+			return false
+		}
+		it.read++
+		if err := verifyRow(it.key, it.tbl, row); err != nil {
+			it.fail(err)
+			return false
+		}
+		if !it.o.schemaChecked {
+			o, err := checkSchemaOnce(it.tbl, it.t, it.o)
+			if err != nil {
+				it.fail(err)
+				return false
+			}
+			it.o = o
+		}
+		v.Elem().Set(reflect.Zero(it.t))
+		if err := setValues(it.tbl, row, item, it.o); err != nil {
+			it.fail(errors.Wrap(err, "Error setting values."))
+			return false
+		}
+		if it.authorizer.check(item) == nil {
+			return true
+		}
+	}
+	it.Close()
+	return false
+}
+
+// Get the error that stopped the iterator, if any
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Stop reading rows. Safe to call more than once.
+func (it *Iterator) Close() {
+	if it.stop == nil {
+		return
+	}
+	it.stop()
+	it.stop = nil
+	sessionOf(it.stub).countRead(it.read)
+}
+
+// Stop the iterator with an error
+func (it *Iterator) fail(err error) {
+	it.err = err
+	it.Close()
+}
@@ -0,0 +1,82 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"runtime"
+	"testing"
+)
+
+func TestGetPage(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "DE", "FR", "LU")
+
+	var items []TestIndexed
+	if err := GetPage(stub, &items, 0, 2); err != nil || len(items) != 2 || items[0].Id != 1 {
+		fail(t, "The first page should have the first items")
+	}
+	items = nil
+	if err := GetPage(stub, &items, 4, 2); err != nil || len(items) != 1 || items[0].Id != 5 {
+		fail(t, "The last page should have the rest of the items")
+	}
+	items = nil
+	if err := GetPage(stub, &items, 6, 2); err != nil || len(items) != 0 {
+		fail(t, "Pages after the last item should be empty")
+	}
+	if err := GetPage(stub, &items, 0, 0); err == nil {
+		fail(t, "Empty pages should fail")
+	}
+}
+
+func TestGetAllIter(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "DE")
+
+	it, err := GetAllIter(stub, &TestIndexed{})
+	if err != nil {
+		fail(t, err)
+	}
+	var countries []string
+	var item TestIndexed
+	for it.Next(&item) {
+		countries = append(countries, item.Country)
+	}
+	if it.Err() != nil || len(countries) != 3 || countries[2] != "DE" {
+		fail(t, "All items should be iterated")
+	}
+
+	// Stopping early leaves no goroutine behind
+	before := runtime.NumGoroutine()
+	it, err = GetAllIter(stub, &TestIndexed{})
+	if err != nil {
+		fail(t, err)
+	}
+	if !it.Next(&item) || item.Id != 1 {
+		fail(t, "The first item should be read")
+	}
+	it.Close()
+	it.Close()
+	checkGoroutines(t, before)
+	if it.Next(&item) {
+		fail(t, "A closed iterator should have no items")
+	}
+
+	// Limits of the session apply
+	session := NewSession(stub)
+	session.MaxRows = 2
+	it, err = GetAllIter(session, &TestIndexed{})
+	if err != nil {
+		fail(t, err)
+	}
+	n := 0
+	for it.Next(&item) {
+		n++
+	}
+	if n != 2 || it.Err() != ErrResultTruncated {
+		fail(t, "The iterator should stop at the limit of the session")
+	}
+	if it, err = GetAllIter(stub, &TestIndexed{}); err != nil || it.Next(&TestStruct{}) || it.Err() == nil {
+		fail(t, "Items of another type should fail")
+	}
+}
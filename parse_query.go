@@ -0,0 +1,235 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Parse a query from text, e.g. the filter parameter of a list endpoint:
+//
+// q, err := orm.ParseQuery("Status = 'open' AND Amount > 100 ORDER BY CreatedAt DESC LIMIT 20")
+//
+// The grammar is whitelisted: conditions of a field, an operator of Where and a value, joined by AND, then optionally
+// ORDER BY fields with ASC or DESC and LIMIT n. Values are 'strings' (a quote is escaped by doubling it), integers,
+// decimals, true and false. Keywords are case insensitive. Fields are checked against the type when the query is run,
+// so unknown fields fail in Find.
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	q := new(Query)
+
+	if !p.atKeyword("ORDER") && !p.atKeyword("LIMIT") && !p.done() {
+		for {
+			field, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			op := p.next()
+			if op.kind != tokenOperator {
+				return nil, p.unexpected(op, "operator")
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			q.And(field, op.text, value)
+			if !p.keyword("AND") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("ORDER") {
+		if !p.keyword("BY") {
+			return nil, p.unexpected(p.next(), "BY")
+		}
+		for {
+			field, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			descending := p.keyword("DESC")
+			if !descending {
+				p.keyword("ASC")
+			}
+			q.OrderBy(field, descending)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.keyword("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokenNumber || err != nil || n <= 0 {
+			return nil, p.unexpected(t, "positive integer")
+		}
+		q.Limit(n)
+	}
+
+	if !p.done() {
+		return nil, p.unexpected(p.next(), "end of query")
+	}
+	return q, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Split the text of a query into tokens
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == ',':
+			i++
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: start})
+		case r == '\'':
+			var s []rune
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("Unterminated string at position %d", start)
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+				s = append(s, runes[i])
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: string(s), pos: start})
+		case strings.ContainsRune("=!<>", r):
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			if !operators[op] {
+				return nil, fmt.Errorf("Unknown operator %s at position %d", op, start)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: start})
+		case r == '-' || unicode.IsDigit(r):
+			for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.'); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+		case r == '_' || unicode.IsLetter(r):
+			for i++; i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[start:i]), pos: start})
+		default:
+			return nil, fmt.Errorf("Unexpected character %q at position %d", r, start)
+		}
+	}
+	return tokens, nil
+}
+
+// Reads the tokens of a query in order
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+var keywords = map[string]bool{"AND": true, "ORDER": true, "BY": true, "ASC": true, "DESC": true, "LIMIT": true,
+	"TRUE": true, "FALSE": true}
+
+func (p *queryParser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokenEnd}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	t := p.peek()
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) done() bool {
+	return p.peek().kind == tokenEnd
+}
+
+func (p *queryParser) atKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && strings.ToUpper(t.text) == keyword
+}
+
+// Skip a keyword if it is next
+func (p *queryParser) keyword(keyword string) bool {
+	if p.atKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) field() (string, error) {
+	t := p.next()
+	if t.kind != tokenWord || keywords[strings.ToUpper(t.text)] {
+		return "", p.unexpected(t, "field")
+	}
+	return t.text, nil
+}
+
+func (p *queryParser) value() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return t.text, nil
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return f, nil
+		}
+	case tokenWord:
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			return true, nil
+		case "FALSE":
+			return false, nil
+		}
+	}
+	return nil, p.unexpected(t, "value")
+}
+
+func (p *queryParser) unexpected(t token, expected string) error {
+	if t.kind == tokenEnd {
+		return errors.New("Expected " + expected + " at end of query")
+	}
+	return fmt.Errorf("Expected %s at position %d, found %q", expected, t.pos, t.text)
+}
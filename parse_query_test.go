@@ -0,0 +1,67 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func checkParseQuery(t *testing.T, text string) *Query {
+	q, err := ParseQuery(text)
+	if err != nil {
+		fail(t, err)
+	}
+	return q
+}
+
+func TestParseQuery(t *testing.T) {
+	q := checkParseQuery(t, "Status = 'it''s open' and Amount > -100.5 AND Paid != TRUE ORDER BY CreatedAt DESC, Id LIMIT 20")
+	if len(q.conditions) != 3 || q.conditions[0].value != "it's open" || q.conditions[1].value != -100.5 ||
+		q.conditions[1].op != ">" || q.conditions[2].value != true {
+		fail(t, "Wrong conditions")
+	}
+	if len(q.order) != 2 || q.order[0].field != "CreatedAt" || !q.order[0].descending || q.order[1].descending {
+		fail(t, "Wrong order")
+	}
+	if q.limit != 20 {
+		fail(t, "Wrong limit")
+	}
+	if q := checkParseQuery(t, "  "); len(q.conditions) != 0 {
+		fail(t, "An empty query should match everything")
+	}
+	checkParseQuery(t, "LIMIT 1")
+	checkParseQuery(t, "ORDER BY Str")
+}
+
+func TestParseQueryShouldFail(t *testing.T) {
+	for text, msg := range map[string]string{
+		"Status = 'open":             "Unterminated string",
+		"Status == 'open'":           "Unknown operator ==",
+		"Status = 'open' OR Id = 1":  "Expected end of query at position 16",
+		"Status = open":              "Expected value",
+		"Status 'open'":              "Expected operator",
+		"AND = 1":                    "Expected field",
+		"Status = 'open' AND":        "Expected field at end of query",
+		"ORDER Status":               "Expected BY",
+		"LIMIT 0":                    "Expected positive integer",
+		"LIMIT 2.5":                  "Expected positive integer",
+		"Status = 'open'; DROP":      "Unexpected character ';'",
+		"Id = 1 LIMIT 1 ORDER BY Id": "Expected end of query",
+	} {
+		_, err := ParseQuery(text)
+		checkErrorContains(t, err, msg)
+	}
+}
+
+func TestFindParsedQuery(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "b", "c")
+
+	items := checkFind(t, stub, checkParseQuery(t, "Str = 'b' AND I64 >= 2 ORDER BY I64 DESC LIMIT 1"), 1)
+	if items[0].I64 != 3 {
+		fail(t, "Wrong item found")
+	}
+	var all []TestStruct
+	checkErrorContains(t, Find(stub, &all, checkParseQuery(t, "Unknown = 1")), "Field Unknown not found")
+}
@@ -0,0 +1,318 @@
+package orm
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Apply a JSON merge patch (RFC 7386) to the stored item with the given id, e.g. a partial update sent by a client:
+//
+// err := orm.MergePatch(stub, &user, 1, []byte(`{"Country": "BE", "Nickname": null}`))
+//
+// Members set to null get their zero value. Members that the JSON of the item doesn't have and changes of the id are
+// rejected; fields hidden from JSON keep their stored value. Afterwards, prototype holds the updated item.
+func MergePatch(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, id int64, patchJSON []byte, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(prototype); err != nil {
+		return err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	stored, err := getStored(stub, t, id, newOptions(stub, opts))
+	if err != nil {
+		return err
+	} else if stored == nil {
+		return ErrNotFound
+	}
+
+	var patch interface{}
+	if err := decodeJSON(patchJSON, &patch); err != nil {
+		return errors.Wrap(err, "Patch is not valid JSON")
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return errors.New("Patch should be a JSON object")
+	}
+
+	doc, err := json.Marshal(stored)
+	if err != nil {
+		return errors.Wrap(err, "Could not marshal "+t.Name())
+	}
+	var target map[string]interface{}
+	if err := decodeJSON(doc, &target); err != nil {
+		return err
+	}
+	seedMembers(t, target)
+	if err := checkPatch(target, patch.(map[string]interface{}), ""); err != nil {
+		return err
+	}
+
+	item, err := decodePatched(stored, mergePatch(target, patch))
+	if err != nil {
+		return err
+	}
+	if item.GetId() != id {
+		return errors.New("Patch cannot change the id")
+	}
+	if err := checkImmutable(stored, item); err != nil {
+		return err
+	}
+
+	if err := Update(stub, item, opts...); err != nil {
+		return err
+	}
+	reflect.ValueOf(prototype).Elem().Set(reflect.ValueOf(item).Elem())
+	return nil
+}
+
+// Apply a JSON patch (RFC 6902) to the stored item with the given id, for clients that speak JSON Patch:
+//
+// err := orm.ApplyPatch(stub, &user, 1, []byte(`[{"op": "replace", "path": "/Country", "value": "BE"}]`))
+//
+// The operations add, replace and remove are supported on the members of the JSON of the item and the objects and
+// arrays in it; removing a member gives its field the zero value. Either all operations are applied or none. The id,
+// key fields and fields tagged `immutable:"true"` cannot be changed; fields hidden from JSON keep their stored value.
+// Afterwards, prototype holds the updated item.
+func ApplyPatch(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, id int64, patchOps []byte, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(prototype); err != nil {
+		return err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	stored, err := getStored(stub, t, id, newOptions(stub, opts))
+	if err != nil {
+		return err
+	} else if stored == nil {
+		return ErrNotFound
+	}
+
+	var ops []map[string]interface{}
+	if err := decodeJSON(patchOps, &ops); err != nil {
+		return errors.Wrap(err, "Patch should be a JSON array of operations")
+	}
+	doc, err := json.Marshal(stored)
+	if err != nil {
+		return errors.Wrap(err, "Could not marshal "+t.Name())
+	}
+	var target map[string]interface{}
+	if err := decodeJSON(doc, &target); err != nil {
+		return err
+	}
+	seedMembers(t, target)
+
+	for i, op := range ops {
+		if err := applyOperation(target, op); err != nil {
+			return errors.Wrapf(err, "Operation %d of patch", i+1)
+		}
+	}
+
+	item, err := decodePatched(stored, target)
+	if err != nil {
+		return err
+	}
+	if item.GetId() != id {
+		return errors.New("Patch cannot change the id")
+	}
+	if err := checkImmutable(stored, item); err != nil {
+		return err
+	}
+
+	if err := Update(stub, item, opts...); err != nil {
+		return err
+	}
+	reflect.ValueOf(prototype).Elem().Set(reflect.ValueOf(item).Elem())
+	return nil
+}
+
+// Add the members of the stored fields that omitempty left out of the JSON of an item, so they can be patched too
+func seedMembers(t reflect.Type, target map[string]interface{}) {
+	for _, f := range fieldsOf(t) {
+		if name, ok := jsonNameOf(f); ok {
+			if _, present := target[name]; !present {
+				target[name] = nil
+			}
+		}
+	}
+}
+
+// Decode the patched JSON of an item into a copy of the stored item. Fields whose member the patch removed get their
+// zero value; fields hidden from JSON with json:"-" keep their stored value instead of being reset.
+func decodePatched(stored BlockchainItemizer, patched interface{}) (BlockchainItemizer, error) {
+	t := reflect.TypeOf(stored).Elem()
+	data, err := json.Marshal(patched)
+	if err != nil {
+		return nil, err
+	}
+	item := reflect.New(t)
+	item.Elem().Set(reflect.ValueOf(stored).Elem())
+	for _, f := range fieldsOf(t) {
+		if _, ok := jsonNameOf(f); ok {
+			v := item.Elem().FieldByIndex(f.Index)
+			v.Set(reflect.Zero(v.Type()))
+		}
+	}
+	if err := json.Unmarshal(data, item.Interface()); err != nil {
+		return nil, errors.Wrap(err, "Patch does not fit "+t.Name())
+	}
+	return item.Interface().(BlockchainItemizer), nil
+}
+
+// Check that a patch didn't change the key fields or the fields tagged `immutable:"true"` of an item
+func checkImmutable(stored, item BlockchainItemizer) error {
+	a, b := reflect.ValueOf(stored).Elem(), reflect.ValueOf(item).Elem()
+	for _, f := range fieldsOf(a.Type()) {
+		if !f.key && f.Tag.Get("immutable") != "true" {
+			continue
+		}
+		if !reflect.DeepEqual(a.FieldByIndex(f.Index).Interface(), b.FieldByIndex(f.Index).Interface()) {
+			return errors.Errorf("Patch cannot change %s of %s", f.Name, a.Type().Name())
+		}
+	}
+	return nil
+}
+
+// Apply one operation of a JSON patch to a decoded JSON object
+func applyOperation(target map[string]interface{}, op map[string]interface{}) error {
+	name, _ := op["op"].(string)
+	path, ok := op["path"].(string)
+	if !ok {
+		return errors.New("Path is missing")
+	}
+	value, hasValue := op["value"]
+	switch name {
+	case "add", "replace":
+		if !hasValue {
+			return errors.New("Value of " + name + " is missing")
+		}
+	case "remove":
+	default:
+		return errors.Errorf("Operation %q is not supported; use add, replace or remove", name)
+	}
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := target[tokens[0]]; !ok {
+		return errors.New("Unknown member " + path)
+	}
+	_, err = patchNode(target, tokens, name, value, path)
+	return err
+}
+
+// Split a JSON pointer (RFC 6901) into its unescaped tokens. The whole document can't be patched.
+func parsePointer(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.Errorf("Path %q should start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// Apply an operation at the path of tokens in a decoded JSON value, and return the value with the operation applied
+func patchNode(node interface{}, tokens []string, op string, value interface{}, path string) (interface{}, error) {
+	token := tokens[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, exists := n[token]
+		if len(tokens) > 1 {
+			if !exists {
+				return nil, errors.New("Path " + path + " not found")
+			}
+			updated, err := patchNode(child, tokens[1:], op, value, path)
+			n[token] = updated
+			return n, err
+		}
+		if !exists && op != "add" {
+			return nil, errors.New("Path " + path + " not found")
+		}
+		if op == "remove" {
+			delete(n, token)
+		} else {
+			n[token] = value
+		}
+		return n, nil
+	case []interface{}:
+		i, err := strconv.Atoi(token)
+		if token == "-" && op == "add" && len(tokens) == 1 {
+			i, err = len(n), nil
+		}
+		max := len(n) - 1
+		if op == "add" && len(tokens) == 1 {
+			max = len(n)
+		}
+		if err != nil || i < 0 || i > max {
+			return nil, errors.New("Path " + path + " not found")
+		}
+		if len(tokens) > 1 {
+			updated, err := patchNode(n[i], tokens[1:], op, value, path)
+			n[i] = updated
+			return n, err
+		}
+		switch op {
+		case "add":
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = value
+		case "replace":
+			n[i] = value
+		case "remove":
+			n = append(n[:i], n[i+1:]...)
+		}
+		return n, nil
+	}
+	return nil, errors.New("Path " + path + " not found")
+}
+
+// Decode JSON keeping numbers as json.Number, so large int64 values don't lose precision
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// Check that every member of a patch exists in the target
+func checkPatch(target, patch map[string]interface{}, path string) error {
+	for key, value := range patch {
+		existing, ok := target[key]
+		if !ok {
+			return errors.New("Unknown member " + path + key)
+		}
+		sub, isObject := value.(map[string]interface{})
+		if current, ok := existing.(map[string]interface{}); ok && isObject {
+			if err := checkPatch(current, sub, path+key+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Apply a merge patch to a decoded JSON value, as described by RFC 7386
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+	return t
+}
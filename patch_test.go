@@ -0,0 +1,152 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL")
+	item := TestIndexed{Saveable: Saveable{Id: 1}, Country: "NL", Name: "a"}
+	if err := Update(stub, &item); err != nil {
+		fail(t, err)
+	}
+
+	var patched TestIndexed
+	if err := MergePatch(stub, &patched, 1, []byte(`{"Country": "BE", "Name": null}`)); err != nil {
+		fail(t, err)
+	}
+	if patched.Id != 1 || patched.Country != "BE" || patched.Name != "" {
+		fail(t, "Patch should be applied")
+	}
+	var stored TestIndexed
+	if err := Get(stub, &stored, 1); err != nil {
+		fail(t, err)
+	}
+	if stored != patched {
+		fail(t, "Patched item should be stored")
+	}
+	if ids := checkIndexIds(t, stub, "BE"); len(ids) != 1 {
+		fail(t, "Index should be updated")
+	}
+
+	checkErrorContains(t, MergePatch(stub, &patched, 1, []byte(`{"Missing": 1}`)), "Missing")
+	checkErrorContains(t, MergePatch(stub, &patched, 1, []byte(`{"id": 2}`)), "id")
+	checkErrorContains(t, MergePatch(stub, &patched, 1, []byte(`{"Country": 3}`)), "TestIndexed")
+	checkErrorContains(t, MergePatch(stub, &patched, 1, []byte(`[]`)), "object")
+	if err := MergePatch(stub, &patched, 2, []byte(`{}`)); err != ErrNotFound {
+		fail(t, "Patching a missing item should return ErrNotFound")
+	}
+}
+
+type TestPatchable struct {
+	Name   string
+	Note   string `json:",omitempty"`
+	Secret string `json:"-"`
+	Saveable
+}
+
+func TestMergePatchKeepsHiddenFields(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestPatchable)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestPatchable{Name: "a", Secret: "s"}); err != nil {
+		fail(t, err)
+	}
+
+	var patched TestPatchable
+	if err := MergePatch(stub, &patched, 1, []byte(`{"Note": "x"}`)); err != nil {
+		fail(t, err)
+	}
+	if patched.Name != "a" || patched.Note != "x" || patched.Secret != "s" {
+		fail(t, "Members left out by omitempty should be patched, keeping fields hidden from JSON")
+	}
+	if err := MergePatch(stub, &patched, 1, []byte(`{"Note": null}`)); err != nil || patched.Note != "" {
+		fail(t, "Members set to null should get their zero value")
+	}
+	var stored TestPatchable
+	if err := Get(stub, &stored, 1); err != nil || stored.Secret != "s" {
+		fail(t, "Fields hidden from JSON should keep their stored value")
+	}
+	checkErrorContains(t, MergePatch(stub, &patched, 1, []byte(`{"Secret": "t"}`)), "Unknown member Secret")
+}
+
+func TestApplyPatchKeepsHiddenFields(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestPatchable)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestPatchable{Name: "a", Secret: "s"}); err != nil {
+		fail(t, err)
+	}
+
+	var patched TestPatchable
+	if err := ApplyPatch(stub, &patched, 1, []byte(`[{"op": "replace", "path": "/Name", "value": "b"}]`)); err != nil {
+		fail(t, err)
+	}
+	var stored TestPatchable
+	if err := Get(stub, &stored, 1); err != nil || stored.Name != "b" || stored.Secret != "s" {
+		fail(t, "Fields hidden from JSON should keep their stored value")
+	}
+	if err := ApplyPatch(stub, &patched, 1, []byte(`[{"op": "remove", "path": "/Name"}]`)); err != nil || patched.Name != "" {
+		fail(t, "Removed members should get their zero value")
+	}
+}
+
+type TestRegistered struct {
+	Name    string
+	Number  string `immutable:"true"`
+	Address TestAddress
+	Saveable
+}
+
+func TestApplyPatch(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestRegistered)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestRegistered{Name: "acme", Number: "123", Address: TestAddress{City: "Utrecht"}}); err != nil {
+		fail(t, err)
+	}
+
+	var patched TestRegistered
+	err := ApplyPatch(stub, &patched, 1, []byte(`[
+		{"op": "replace", "path": "/Name", "value": "Acme"},
+		{"op": "add", "path": "/Address/Street", "value": "Main street"},
+		{"op": "remove", "path": "/Address/City"}
+	]`))
+	if err != nil {
+		fail(t, err)
+	}
+	var stored TestRegistered
+	if err := Get(stub, &stored, 1); err != nil {
+		fail(t, err)
+	}
+	if stored != patched || stored.Name != "Acme" || stored.Address != (TestAddress{Street: "Main street"}) {
+		fail(t, "Patch should be applied and stored")
+	}
+
+	// Nothing is applied when an operation fails
+	checkErrorContains(t, ApplyPatch(stub, &patched, 1, []byte(`[
+		{"op": "replace", "path": "/Name", "value": "Other"},
+		{"op": "replace", "path": "/Missing", "value": 1}
+	]`)), "Unknown member /Missing")
+	if err := Get(stub, &stored, 1); err != nil || stored.Name != "Acme" {
+		fail(t, "A failed patch should not be applied")
+	}
+
+	checkErrorContains(t, ApplyPatch(stub, &patched, 1, []byte(`[{"op": "replace", "path": "/Number", "value": "456"}]`)), "Number")
+	checkErrorContains(t, ApplyPatch(stub, &patched, 1, []byte(`[{"op": "replace", "path": "/id", "value": 2}]`)), "id")
+	checkErrorContains(t, ApplyPatch(stub, &patched, 1, []byte(`[{"op": "move", "path": "/Name"}]`)), "not supported")
+	checkErrorContains(t, ApplyPatch(stub, &patched, 1, []byte(`[{"op": "add", "path": "/Address/Zip/Code", "value": "1"}]`)), "not found")
+	checkErrorContains(t, ApplyPatch(stub, &patched, 1, []byte(`{}`)), "array")
+	if err := ApplyPatch(stub, &patched, 2, []byte(`[]`)); err != ErrNotFound {
+		fail(t, "Patching a missing item should return ErrNotFound")
+	}
+}
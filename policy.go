@@ -0,0 +1,212 @@
+package orm
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Principals that can be allowed an operation, besides the name or organization of a caller
+const (
+	// Everyone, including callers without a certificate
+	AnyCaller = "any"
+	// The caller whose name is in the field of the item tagged `owner:"true"`
+	OwnerCaller = "owner"
+)
+
+// Declares who may create, read, update and delete the items of a table:
+//
+// orm.Policy(&User{}).AllowCreate("Org1").AllowRead("any").AllowUpdate("owner")
+//
+// Callers are identified by the certificate of the transaction: a principal matches the common name or one of the
+// organizations of its subject. Once a table has a policy, operations that are not allowed are denied. Items that may
+// not be read are left out of GetAll and queries; Get returns an *AuthorizationError. Declare policies at
+// initialization of the chaincode.
+//
+// Denied operations are logged as warnings, not written to an audit table: the invocation fails with the error, so
+// its writes, an audit row included, would never be committed.
+type TablePolicy struct {
+	allowed map[string][]string
+}
+
+// Returned when the caller is not allowed an operation on a table
+type AuthorizationError struct {
+	Table     string
+	Operation string
+	Caller    string
+}
+
+func (e *AuthorizationError) Error() string {
+	return fmt.Sprintf("%s is not allowed to %s %s", e.Caller, e.Operation, e.Table)
+}
+
+// The caller of a transaction
+type Identity struct {
+	Name          string
+	Organizations []string
+}
+
+var policies = map[string]*TablePolicy{}
+
+// Get the policy of the table of prototype, declaring it if it doesn't exist yet
+func Policy(prototype BlockchainItemizer) *TablePolicy {
+	state.Lock()
+	defer state.Unlock()
+	name := reflect.TypeOf(prototype).Elem().Name()
+	if policies[name] == nil {
+		policies[name] = &TablePolicy{allowed: map[string][]string{}}
+	}
+	return policies[name]
+}
+
+// Allow principals to create items
+func (p *TablePolicy) AllowCreate(principals ...string) *TablePolicy {
+	return p.allow("create", principals)
+}
+
+// Allow principals to read items
+func (p *TablePolicy) AllowRead(principals ...string) *TablePolicy {
+	return p.allow("read", principals)
+}
+
+// Allow principals to update items. The owner is taken from the stored item, so an update can't claim an item.
+func (p *TablePolicy) AllowUpdate(principals ...string) *TablePolicy {
+	return p.allow("update", principals)
+}
+
+// Allow principals to delete items
+func (p *TablePolicy) AllowDelete(principals ...string) *TablePolicy {
+	return p.allow("delete", principals)
+}
+
+func (p *TablePolicy) allow(operation string, principals []string) *TablePolicy {
+	state.Lock()
+	defer state.Unlock()
+	p.allowed[operation] = append(p.allowed[operation], principals...)
+	return p
+}
+
+// Get a copy of the principals allowed each operation
+func (p *TablePolicy) principals() map[string][]string {
+	state.RLock()
+	defer state.RUnlock()
+	allowed := map[string][]string{}
+	for operation, principals := range p.allowed {
+		allowed[operation] = append([]string(nil), principals...)
+	}
+	return allowed
+}
+
+// Get the policy of the table of the items of type t, or nil if it has none
+func policyOf(t reflect.Type) *TablePolicy {
+	state.RLock()
+	defer state.RUnlock()
+	return policies[t.Name()]
+}
+
+// Check whether a caller may do an operation on an item
+func (p *TablePolicy) allows(operation string, caller *Identity, item reflect.Value) bool {
+	state.RLock()
+	allowed := p.allowed[operation]
+	state.RUnlock()
+	for _, principal := range allowed {
+		switch {
+		case principal == AnyCaller:
+			return true
+		case caller == nil:
+			continue
+		case principal == OwnerCaller:
+			if owner, ok := ownerOf(item); ok && owner == caller.Name {
+				return true
+			}
+		case principal == caller.Name:
+			return true
+		default:
+			for _, org := range caller.Organizations {
+				if principal == org {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Get the value of the owner field of an item
+func ownerOf(item reflect.Value) (string, bool) {
+	if !item.IsValid() {
+		return "", false
+	}
+	item = reflect.Indirect(item)
+	for _, f := range fieldsOf(item.Type()) {
+		if f.Tag.Get("owner") == "true" && f.Type.Kind() == reflect.String {
+			return item.FieldByIndex(f.Index).String(), true
+		}
+	}
+	return "", false
+}
+
+// Get the identity of the caller from its certificate, or nil if there is none
+func callerOf(stub shim.ChaincodeStubInterface) (*Identity, error) {
+	raw, err := stub.GetCallerCertificate()
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get caller certificate")
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse caller certificate")
+	}
+	return &Identity{Name: cert.Subject.CommonName, Organizations: cert.Subject.Organization}, nil
+}
+
+// Checks the policy of a table for the caller of one operation
+type authorizer struct {
+	policy    *TablePolicy
+	table     string
+	operation string
+	caller    *Identity
+}
+
+// Prepare the check of an operation on the items of type t. It allows everything when the table has no policy.
+func authorizerOf(stub shim.ChaincodeStubInterface, t reflect.Type, operation string) (*authorizer, error) {
+	policy := policyOf(t)
+	if policy == nil {
+		return nil, nil
+	}
+	caller, err := callerOf(stub)
+	if err != nil {
+		return nil, err
+	}
+	return &authorizer{policy: policy, table: t.Name(), operation: operation, caller: caller}, nil
+}
+
+// Check the operation on an item
+func (a *authorizer) check(item interface{}) error {
+	if a == nil || a.policy.allows(a.operation, a.caller, reflect.ValueOf(item)) {
+		return nil
+	}
+	err := &AuthorizationError{Table: a.table, Operation: a.operation, Caller: "anonymous caller"}
+	if a.caller != nil {
+		err.Caller = a.caller.Name
+	}
+	logger.Warningf("Denied: %v", err)
+	return err
+}
+
+// Check an operation on an item of the caller of a stub
+func authorize(stub shim.ChaincodeStubInterface, operation string, item interface{}) error {
+	a, err := authorizerOf(stub, reflect.TypeOf(item).Elem(), operation)
+	if err != nil {
+		return err
+	}
+	return a.check(item)
+}
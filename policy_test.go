@@ -0,0 +1,125 @@
+package orm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type TestOwned struct {
+	Owner string `owner:"true"`
+	Name  string
+	Saveable
+}
+
+// Presents a certificate for the caller
+type callerStub struct {
+	*shim.MockStub
+	cert []byte
+}
+
+func (s *callerStub) GetCallerCertificate() ([]byte, error) {
+	return s.cert, nil
+}
+
+// Create a stub with the certificate of a caller
+func checkCaller(t *testing.T, stub *shim.MockStub, name, org string) *callerStub {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fail(t, err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{org}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		fail(t, err)
+	}
+	return &callerStub{MockStub: stub, cert: cert}
+}
+
+func TestPolicy(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestOwned)); err != nil {
+		fail(t, err)
+	}
+	Policy(&TestOwned{}).AllowCreate("Org1").AllowRead(OwnerCaller).AllowUpdate(OwnerCaller).AllowDelete("admin")
+	defer delete(policies, "TestOwned")
+
+	alice := checkCaller(t, stub, "alice", "Org1")
+	bob := checkCaller(t, stub, "bob", "Org2")
+
+	if err := Create(alice, &TestOwned{Owner: "alice"}); err != nil {
+		fail(t, err)
+	}
+	if _, ok := Create(bob, &TestOwned{Owner: "bob"}).(*AuthorizationError); !ok {
+		fail(t, "Bob's organization may not create")
+	}
+	if _, ok := Create(stub, &TestOwned{}).(*AuthorizationError); !ok {
+		fail(t, "Callers without a certificate may not create")
+	}
+
+	var item TestOwned
+	if err := Get(alice, &item, 1); err != nil {
+		fail(t, err)
+	}
+	if _, ok := Get(bob, &item, 1).(*AuthorizationError); !ok {
+		fail(t, "Bob may not read Alice's item")
+	}
+	var items []TestOwned
+	if err := GetAll(bob, &items); err != nil || len(items) != 0 {
+		fail(t, "GetAll should leave out items the caller may not read")
+	}
+
+	// The owner of the stored item decides
+	item.Owner = "bob"
+	if _, ok := Update(bob, &item).(*AuthorizationError); !ok {
+		fail(t, "Bob may not claim Alice's item")
+	}
+	if err := Update(alice, &item); err != nil {
+		fail(t, err)
+	}
+	if _, ok := Delete(alice, &item).(*AuthorizationError); !ok {
+		fail(t, "Only admin may delete")
+	}
+}
+
+func TestAssociatePolicy(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL")
+	if err := CreateTable(stub, new(TestOwned)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestOwned{Owner: "alice"}); err != nil {
+		fail(t, err)
+	}
+	Policy(&TestOwned{}).AllowRead(AnyCaller).AllowUpdate(OwnerCaller)
+	defer delete(policies, "TestOwned")
+
+	alice := checkCaller(t, stub, "alice", "Org1")
+	bob := checkCaller(t, stub, "bob", "Org1")
+	owned, nl := &TestOwned{Owner: "bob", Saveable: Saveable{Id: 1}}, &TestIndexed{Saveable: Saveable{Id: 1}}
+	if _, ok := Associate(bob, owned, nl).(*AuthorizationError); !ok {
+		fail(t, "Bob may not associate Alice's item")
+	}
+	if err := Associate(alice, owned, nl); err != nil {
+		fail(t, err)
+	}
+	if _, ok := Dissociate(bob, nl, owned).(*AuthorizationError); !ok {
+		fail(t, "Bob may not dissociate Alice's item")
+	}
+	if err := Dissociate(alice, nl, owned); err != nil {
+		fail(t, err)
+	}
+}
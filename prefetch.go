@@ -0,0 +1,124 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"reflect"
+	"sync"
+)
+
+// Read up to n rows ahead of the consumer, so decoding overlaps with reading from the ledger. The returned stop
+// function must be called when the consumer is done, also when it returns early: the rows that weren't read are
+// drained in the background, so the goroutine of the shim that sends them is never left blocked, without making the
+// consumer wait for the rest of the table. Writes to the stub wait for the drain.
+func prefetch(stub shim.ChaincodeStubInterface, rows <-chan shim.Row, n int) (<-chan shim.Row, func()) {
+	if n <= 0 {
+		return rows, func() {
+			finished := make(chan struct{})
+			go func() {
+				defer close(finished)
+				drain(rows)
+			}()
+			draining(stub, finished)
+		}
+	}
+	buffered := make(chan shim.Row, n)
+	done, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer close(buffered)
+		for row := range rows {
+			select {
+			case buffered <- row:
+			case <-done:
+				drain(rows)
+				return
+			}
+		}
+	}()
+	return buffered, func() {
+		close(done)
+		draining(stub, finished)
+	}
+}
+
+// Read the remaining rows of a channel of the shim
+func drain(rows <-chan shim.Row) {
+	for range rows {
+	}
+}
+
+// The scans of each transaction whose remaining rows are drained in the background, by the stub of the transaction.
+// A scan is removed when its channel is closed.
+var drains = struct {
+	sync.Mutex
+	pending map[shim.ChaincodeStubInterface][]chan struct{}
+}{pending: map[shim.ChaincodeStubInterface][]chan struct{}{}}
+
+// Get the stub of the transaction that a Session or saga reads and writes through
+func transactionStub(stub shim.ChaincodeStubInterface) shim.ChaincodeStubInterface {
+	for {
+		switch s := stub.(type) {
+		case *Session:
+			if s == nil {
+				return stub
+			}
+			stub = s.ChaincodeStubInterface
+		case *sagaStub:
+			stub = s.ChaincodeStubInterface
+		default:
+			return stub
+		}
+	}
+}
+
+// Remember a scan of a stub that is drained in the background. Stubs that can't be told apart are waited for now.
+func draining(stub shim.ChaincodeStubInterface, finished chan struct{}) {
+	key := transactionStub(stub)
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		<-finished
+		return
+	}
+	drains.Lock()
+	drains.pending[key] = append(drains.pending[key], finished)
+	drains.Unlock()
+
+	go func() {
+		<-finished
+		drains.Lock()
+		defer drains.Unlock()
+		var running []chan struct{}
+		for _, pending := range drains.pending[key] {
+			if pending != finished {
+				running = append(running, pending)
+			}
+		}
+		if len(running) == 0 {
+			delete(drains.pending, key)
+		} else {
+			drains.pending[key] = running
+		}
+	}()
+}
+
+// Wait until the scans of the transaction of a stub that were stopped early are drained, so the shim doesn't read
+// rows while the transaction writes
+func waitForDrains(stub shim.ChaincodeStubInterface) {
+	key := transactionStub(stub)
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		return
+	}
+	drains.Lock()
+	pending := drains.pending[key]
+	drains.Unlock()
+	for _, finished := range pending {
+		<-finished
+	}
+}
+
+// Get the number of rows to read ahead in a scan: that of the session, or else that of the configuration
+func prefetchOf(stub shim.ChaincodeStubInterface) int {
+	if session := sessionOf(stub); session != nil && session.Prefetch > 0 {
+		return session.Prefetch
+	}
+	return currentConfig().Prefetch
+}
@@ -0,0 +1,154 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Check that no goroutine is left behind, allowing the ones that are finishing to exit
+func checkGoroutines(t *testing.T, before int) {
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		fail(t, fmt.Sprintf("Scans stopped early should not leave goroutines behind, got %d", n-before))
+	}
+}
+
+func TestDrainedScans(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "c", "d", "e")
+	defer Configure(Config{})
+
+	for _, prefetch := range []int{0, 2} {
+		Configure(Config{Prefetch: prefetch})
+		before := runtime.NumGoroutine()
+		for i := 0; i < 10; i++ {
+			var items []TestStruct
+			if err := Find(stub, &items, Where("Str", "!=", "").Limit(1)); err != nil || len(items) != 1 {
+				fail(t, "Limited queries should stop early")
+			}
+		}
+		checkGoroutines(t, before)
+
+		var items []TestStruct
+		if err := GetAll(stub, &items); err != nil || len(items) != 5 {
+			fail(t, fmt.Sprintf("All rows should be read with a prefetch of %d", prefetch))
+		}
+	}
+
+	session := NewSession(stub)
+	session.MaxRows = 2
+	session.Prefetch = 3
+	before := runtime.NumGoroutine()
+	var items []TestStruct
+	if err := GetAll(session, &items); err != ErrResultTruncated {
+		fail(t, "The session should stop the scan")
+	}
+	checkGoroutines(t, before)
+}
+
+// Sends the rows of a table slowly, like a large table on a ledger
+type slowStub struct {
+	*shim.MockStub
+	delay time.Duration
+}
+
+func (s *slowStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	rows, err := s.MockStub.GetRows(tableName, key)
+	if err != nil {
+		return nil, err
+	}
+	slow := make(chan shim.Row)
+	go func() {
+		defer close(slow)
+		for row := range rows {
+			time.Sleep(s.delay)
+			slow <- row
+		}
+	}()
+	return slow, nil
+}
+
+func TestStoppedScansReturnEarly(t *testing.T) {
+	stub := &slowStub{MockStub: shim.NewMockStub("cc", new(MockChaincode))}
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	var strs []string
+	for i := 0; i < 40; i++ {
+		strs = append(strs, fmt.Sprint(i))
+	}
+	checkCreateItems(t, stub, strs...)
+	stub.delay = 5 * time.Millisecond
+	defer Configure(Config{})
+
+	// Reading the whole table takes 200ms
+	for _, prefetch := range []int{0, 2} {
+		Configure(Config{Prefetch: prefetch})
+		started := time.Now()
+		var items []TestStruct
+		if err := Find(stub, &items, Where("Str", "!=", "").Limit(1)); err != nil || len(items) != 1 {
+			fail(t, "Limited queries should stop early")
+		}
+		if time.Since(started) > 100*time.Millisecond {
+			fail(t, fmt.Sprintf("Limited queries should not wait for the rest of the table with a prefetch of %d", prefetch))
+		}
+
+		session := NewSession(stub)
+		session.MaxRows = 1
+		started = time.Now()
+		if err := GetAll(session, &items); err != ErrResultTruncated || time.Since(started) > 100*time.Millisecond {
+			fail(t, "MaxRows should stop the scan without reading the rest of the table")
+		}
+
+		session = NewSession(stub)
+		session.MaxDuration = 20 * time.Millisecond
+		started = time.Now()
+		if err := GetAll(session, &items); err != ErrResultTruncated || time.Since(started) > 100*time.Millisecond {
+			fail(t, "MaxDuration should stop the scan without reading the rest of the table")
+		}
+	}
+}
+
+func TestDrainsOfOtherTransactions(t *testing.T) {
+	stub := &slowStub{MockStub: shim.NewMockStub("cc", new(MockChaincode))}
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	var strs []string
+	for i := 0; i < 40; i++ {
+		strs = append(strs, fmt.Sprint(i))
+	}
+	checkCreateItems(t, stub, strs...)
+	stub.delay = 5 * time.Millisecond
+
+	// The rest of the table is drained in the background for 200ms
+	var items []TestStruct
+	if err := Find(stub, &items, Where("Str", "!=", "").Limit(1)); err != nil || len(items) != 1 {
+		fail(t, "Limited queries should stop early")
+	}
+	other := shim.NewMockStub("cc", new(MockChaincode))
+	other.MockTransactionStart("other")
+	started := time.Now()
+	checkCreateTable(t, other)
+	checkCreateItems(t, other, "a")
+	if time.Since(started) > 100*time.Millisecond {
+		fail(t, "Writes should not wait for the scans of other transactions")
+	}
+	waitForDrains(stub)
+	for i := 0; ; i++ {
+		drains.Lock()
+		n := len(drains.pending[stub])
+		drains.Unlock()
+		if n == 0 {
+			break
+		} else if i == 100 {
+			fail(t, "Drained scans should be forgotten")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,497 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// A query selects the items of a table that match all of its conditions and filters:
+//
+// err := orm.Find(stub, &users, orm.Where("Country", "=", "NL").And("Age", ">=", 18))
+//
+// The zero Query matches everything; start with new(orm.Query) to use only filters or a limit.
+type Query struct {
+	conditions []condition
+	filters    []func(item interface{}) bool
+	order      []ordering
+	limit      int
+	offset     int
+}
+
+// Sorts the results of a query on a field
+type ordering struct {
+	field      string
+	descending bool
+}
+
+// A condition on the value of a field
+type condition struct {
+	field string
+	op    string
+	value interface{}
+}
+
+var operators = map[string]bool{"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// Create a query with a condition on a field. Supported operators are =, !=, <, <=, > and >=.
+func Where(field, op string, value interface{}) *Query {
+	return new(Query).And(field, op, value)
+}
+
+// Add a condition to the query
+func (q *Query) And(field, op string, value interface{}) *Query {
+	q.conditions = append(q.conditions, condition{field: field, op: op, value: value})
+	return q
+}
+
+// Add a filter to the query. It is called with a pointer to every item that matches the conditions while the rows
+// are read, so the scan can stop as soon as the limit is reached.
+func (q *Query) Filter(fn func(item interface{}) bool) *Query {
+	q.filters = append(q.filters, fn)
+	return q
+}
+
+// Sort the results on a field. Call it again to sort items with the same value on another field. A query with an
+// order reads all rows before the limit is applied.
+func (q *Query) OrderBy(field string, descending bool) *Query {
+	q.order = append(q.order, ordering{field: field, descending: descending})
+	return q
+}
+
+// Stop reading rows when n items have been found. 0 means no limit.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// Skip the first n results, e.g. the items of the previous pages. Use it with an order, so the pages don't depend on
+// the order of the rows. A negative offset skips nothing.
+func (q *Query) Offset(n int) *Query {
+	if n < 0 {
+		n = 0
+	}
+	q.offset = n
+	return q
+}
+
+// Check whether a scan that found n results can stop, because the results after the offset fill the limit
+func (q *Query) full(n int) bool {
+	return q != nil && q.limit > 0 && n-q.limit >= q.offset && len(q.order) == 0
+}
+
+// Get the bounds of the results after the offset, up to the limit, of n results
+func (q *Query) window(n int) (int, int) {
+	if q == nil {
+		return 0, n
+	}
+	start, end := q.offset, n
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+	if q.limit > 0 && q.limit < end-start {
+		end = start + q.limit
+	}
+	return start, end
+}
+
+// Returned by scan callbacks to stop scanning without error
+var errStopScan = errors.New("Scan stopped")
+
+// Get all items that match the query by passing a pointer to a slice of the correct type. A nil query matches
+// everything.
+func Find(stub shim.ChaincodeStubInterface, items interface{}, q *Query, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if err := checkSlice(items); err != nil {
+		return errors.Wrap(err, "Object passed to Find should be a pointer to a slice")
+	}
+	v := reflect.ValueOf(items).Elem()
+
+	t := reflect.TypeOf(items).Elem().Elem()
+	if err := q.validate(t); err != nil {
+		return err
+	}
+
+	// Conditions need the values of their fields
+	o := newOptions(stub, opts)
+	if o.projection != nil && q != nil {
+		for _, c := range q.conditions {
+			o.projection[c.field] = true
+		}
+		for _, order := range q.order {
+			o.projection[order.field] = true
+		}
+	}
+	ordered := q != nil && len(q.order) > 0
+
+	err := scanQuery(stub, t, q, o, func(item interface{}) error {
+		if ok, err := q.matches(item); err != nil {
+			return err
+		} else if ok {
+			v.Set(reflect.Append(v, reflect.ValueOf(item).Elem()))
+		}
+		if q.full(v.Len()) {
+			return errStopScan
+		}
+		return nil
+	})
+	if err == errStopScan {
+		err = nil
+	} else if err != nil && err != ErrResultTruncated {
+		return err
+	}
+	if ordered {
+		sorted := &orderedItems{items: v, order: q.order, tmp: reflect.New(t).Elem()}
+		sort.Stable(sorted)
+		if sorted.err != nil {
+			return sorted.err
+		}
+	} else if o.conf().SortResults {
+		sortItems(v)
+	}
+	v.Set(v.Slice(q.window(v.Len())))
+	return err
+}
+
+// Call fn for every readable item of type t that can match the query. With equality conditions on indexed fields,
+// only the items in the index with those values are read instead of the whole table.
+func scanQuery(stub shim.ChaincodeStubInterface, t reflect.Type, q *Query, o *options, fn func(item interface{}) error) error {
+	ids, indexed, err := q.indexedIds(stub, t, o)
+	if err != nil {
+		return err
+	} else if !indexed {
+		return scan(stub, t, o, fn)
+	}
+	authorizer, err := authorizerOf(stub, t, "read")
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		item := reflect.New(t).Interface().(BlockchainItemizer)
+		if err := get(stub, item, id, o); err != nil {
+			return err
+		}
+		if item.GetId() == 0 || authorizer.check(item) != nil {
+			continue // Stale index row or not readable
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Look up the ids of the items that can match the query in the index with the most leading fields that have an
+// equality condition. Returns false if there is no such index, the value is left out of a partial index, or the items
+// can't be read by id.
+func (q *Query) indexedIds(stub shim.ChaincodeStubInterface, t reflect.Type, o *options) ([]int64, bool, error) {
+	if q == nil || !keyedById(t) {
+		return nil, false, nil
+	}
+	indexes, err := indexesOf(t, o)
+	if err != nil {
+		return nil, false, err
+	}
+	var best *index
+	var values []interface{}
+	for i, idx := range indexes {
+		var prefix []interface{}
+		for _, f := range idx.fields {
+			value, ok := q.equalTo(f.Name)
+			if !ok || !storedExactly(f.Type, value) || !idx.covers(value) {
+				break
+			}
+			prefix = append(prefix, value)
+		}
+		if len(prefix) > len(values) {
+			best, values = &indexes[i], prefix
+		}
+	}
+	if best == nil {
+		return nil, false, nil
+	}
+	ids, err := best.lookup(stub, values...)
+	return ids, true, err
+}
+
+// Get the value of the first equality condition on a field
+func (q *Query) equalTo(field string) (interface{}, bool) {
+	for _, c := range q.conditions {
+		if c.op == "=" && c.field == field {
+			return c.value, true
+		}
+	}
+	return nil, false
+}
+
+// Check whether a value can be converted to type t without changing it, e.g. not 1.5 for an int field, so it can be
+// looked up in an index
+func storedExactly(t reflect.Type, value interface{}) bool {
+	val := reflect.ValueOf(value)
+	if !val.IsValid() || !val.Type().ConvertibleTo(t) {
+		return false
+	}
+	cmp, err := compare(val.Convert(t), value)
+	return err == nil && cmp == 0
+}
+
+// Check that all conditions can be evaluated for items of type t
+func (q *Query) validate(t reflect.Type) error {
+	if q == nil {
+		return nil
+	}
+	for _, c := range q.conditions {
+		if !operators[c.op] {
+			return errors.New("Operator " + c.op + " not supported")
+		}
+		f, ok := queryableField(t, c.field)
+		if !ok {
+			return errors.New("Field " + c.field + " not found in " + t.Name())
+		} else if c.value == nil && f.Type.Kind() != reflect.Ptr {
+			return errors.New("Cannot compare " + c.field + " of " + t.Name() + " with nil")
+		}
+	}
+	for _, order := range q.order {
+		if _, ok := queryableField(t, order.field); !ok {
+			return errors.New("Field " + order.field + " not found in " + t.Name())
+		}
+	}
+	return nil
+}
+
+// Get a field of type t that a query can use: a stored field that is in the JSON of the item, so a caller can't filter
+// or sort by a value it can't read, like an unexported field or one tagged `json:"-"`
+func queryableField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, f := range fieldsOf(t) {
+		if f.Name != name {
+			continue
+		}
+		if _, ok := jsonNameOf(f); ok {
+			return f.StructField, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Check whether an item (pointer) matches all conditions and filters of the query
+func (q *Query) matches(item interface{}) (bool, error) {
+	if q == nil {
+		return true, nil
+	}
+	v := reflect.ValueOf(item).Elem()
+	for _, c := range q.conditions {
+		cmp, err := compare(v.FieldByName(c.field), c.value)
+		if err != nil {
+			return false, errors.Wrap(err, "Could not evaluate condition on "+c.field)
+		}
+		if !c.holds(cmp) {
+			return false, nil
+		}
+	}
+	for _, filter := range q.filters {
+		if !filter(item) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Check whether the condition holds for the result of comparing the field with the value
+func (c condition) holds(cmp int) bool {
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// Compare the value of a field with a value of any compatible type (e.g. an int with an int64 field, or a
+// float64 from JSON with an integer field). Returns -1, 0 or 1 like strings.Compare.
+func compare(f reflect.Value, value interface{}) (int, error) {
+	val := reflect.ValueOf(value)
+	if !f.IsValid() {
+		// A nil pointer in the values of FindMaps, which sorts before all values
+		if !val.IsValid() || val.Kind() == reflect.Ptr && val.IsNil() {
+			return 0, nil
+		}
+		return -1, nil
+	}
+	if !val.IsValid() && f.Kind() != reflect.Ptr {
+		return 0, errors.New("Cannot compare with nil")
+	} else if !val.IsValid() {
+		// An untyped nil is a nil pointer for pointer fields
+		val = reflect.Zero(f.Type())
+	}
+
+	// Nil pointers sort before all values
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() && f.Kind() == reflect.Ptr && f.IsNil() {
+			return 0, nil
+		} else if val.IsNil() {
+			return 1, nil
+		}
+		val = val.Elem()
+	}
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return -1, nil
+		}
+		f = f.Elem()
+	}
+	// Valuers are compared by their column values
+	if isValuer(f.Type()) {
+		column, err := toColumn(f)
+		if err != nil {
+			return 0, err
+		}
+		if val.Type() == f.Type() {
+			c, err := toColumn(val)
+			if err != nil {
+				return 0, err
+			}
+			value = columnValue(&c)
+		}
+		return compare(reflect.ValueOf(columnValue(&column)), value)
+	}
+	// Times are compared by their instant, as they are stored
+	if f.Type() == timeType && val.Type() == timeType {
+		return compareInts(nanosOf(f.Interface().(time.Time)), nanosOf(val.Interface().(time.Time))), nil
+	}
+	switch f.Kind() {
+	case reflect.Bool:
+		if val.Kind() != reflect.Bool {
+			break
+		}
+		if f.Bool() == val.Bool() {
+			return 0, nil
+		} else if val.Bool() {
+			return -1, nil
+		}
+		return 1, nil
+	case reflect.String:
+		if val.Kind() != reflect.String {
+			break
+		}
+		a, b := f.String(), val.String()
+		if a < b {
+			return -1, nil
+		} else if a > b {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		a := f.Int()
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareInts(a, val.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if a < 0 {
+				return -1, nil
+			}
+			return compareUints(uint64(a), val.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return compareFloats(float64(a), val.Float()), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		a := f.Uint()
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if val.Int() < 0 {
+				return 1, nil
+			}
+			return compareUints(a, uint64(val.Int())), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return compareUints(a, val.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return compareFloats(float64(a), val.Float()), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		a := f.Float()
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareFloats(a, float64(val.Int())), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return compareFloats(a, float64(val.Uint())), nil
+		case reflect.Float32, reflect.Float64:
+			return compareFloats(a, val.Float()), nil
+		}
+	}
+	return 0, fmt.Errorf("Cannot compare %v with %v", f.Type(), val.Type())
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareUints(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Sorts the results of a query by its order
+type orderedItems struct {
+	items reflect.Value
+	order []ordering
+	tmp   reflect.Value
+	// The first error comparing items, as Less can't return it
+	err error
+}
+
+func (s *orderedItems) Len() int { return s.items.Len() }
+
+func (s *orderedItems) Swap(i, j int) {
+	s.tmp.Set(s.items.Index(i))
+	s.items.Index(i).Set(s.items.Index(j))
+	s.items.Index(j).Set(s.tmp)
+}
+
+func (s *orderedItems) Less(i, j int) bool {
+	a, b := s.items.Index(i), s.items.Index(j)
+	for _, order := range s.order {
+		cmp, err := compare(a.FieldByName(order.field), b.FieldByName(order.field).Interface())
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		if err != nil || cmp == 0 {
+			continue
+		}
+		return (cmp < 0) != order.descending
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+	"time"
+)
+
+// Create a TestStruct for every string, with I64 set to its position (1, 2, 3...)
+func checkCreateItems(t *testing.T, stub shim.ChaincodeStubInterface, strs ...string) {
+	for i, str := range strs {
+		s := getTestStruct()
+		s.Str = str
+		s.I64 = int64(i + 1)
+		if err := Create(stub, &s); err != nil {
+			fail(t, err)
+		}
+	}
+}
+
+func checkFind(t *testing.T, stub shim.ChaincodeStubInterface, q *Query, expected int) []TestStruct {
+	var items []TestStruct
+	if err := Find(stub, &items, q); err != nil {
+		fail(t, err)
+	}
+	if len(items) != expected {
+		fail(t, "Not the right amount of items found")
+	}
+	return items
+}
+
+func TestFind(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "b", "c")
+
+	checkFind(t, stub, nil, 4)
+	checkFind(t, stub, Where("Str", "=", "b"), 2)
+	checkFind(t, stub, Where("Str", "!=", "b"), 2)
+	checkFind(t, stub, Where("Str", ">", "a"), 3)
+	checkFind(t, stub, Where("I64", "<=", 2), 2)
+	checkFind(t, stub, Where("I64", ">=", 2.0), 3)
+	checkFind(t, stub, Where("UI32", "=", 99999999), 4)
+	checkFind(t, stub, Where("Bool", "=", false), 0)
+	checkFind(t, stub, Where("Id", ">", 0), 4)
+	items := checkFind(t, stub, Where("Str", "=", "b").And("I64", ">", 2), 1)
+	if items[0].I64 != 3 {
+		fail(t, "Wrong item found")
+	}
+}
+
+func TestFindWithFilter(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "b", "c")
+
+	odd := func(item interface{}) bool { return item.(*TestStruct).I64%2 == 1 }
+	checkFind(t, stub, new(Query).Filter(odd), 2)
+	checkFind(t, stub, Where("Str", "=", "b").Filter(odd), 1)
+
+	calls := 0
+	counting := func(item interface{}) bool {
+		calls++
+		return true
+	}
+	checkFind(t, stub, new(Query).Filter(counting).Limit(2), 2)
+	if calls != 2 {
+		fail(t, "Scan should stop when the limit is reached")
+	}
+}
+
+func TestFindShouldFail(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a")
+
+	var items []TestStruct
+	if err := Find(stub, &items, Where("Unknown", "=", 1)); err == nil {
+		fail(t, "Find should fail with an unknown field")
+	}
+	if err := Find(stub, &items, Where("Str", "~", "a")); err == nil {
+		fail(t, "Find should fail with an unknown operator")
+	}
+	if err := Find(stub, &items, Where("Str", "=", 1)); err == nil {
+		fail(t, "Find should fail when comparing different types")
+	}
+}
+
+type TestHidden struct {
+	Name   string
+	Secret string `json:"-"`
+	Draft  string `orm:"-"`
+	note   string
+	Saveable
+}
+
+func TestFindHiddenFields(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestHidden)); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestHidden{Name: "a", Secret: "s", note: "n"}); err != nil {
+		fail(t, err)
+	}
+
+	var items []TestHidden
+	for _, name := range []string{"Secret", "Draft", "note"} {
+		checkErrorContains(t, Find(stub, &items, Where(name, "=", "s")), "Field "+name+" not found")
+		checkErrorContains(t, Find(stub, &items, new(Query).OrderBy(name, false)), "Field "+name+" not found")
+	}
+	if err := Find(stub, &items, Where("Name", "=", "a")); err != nil || len(items) != 1 {
+		fail(t, "Stored fields in the JSON should be queryable")
+	}
+}
+
+func TestFindOrdered(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "b", "a", "c", "a")
+
+	items := checkFind(t, stub, new(Query).OrderBy("Str", false).OrderBy("I64", true), 4)
+	if items[0].I64 != 4 || items[1].I64 != 2 || items[2].Str != "b" || items[3].Str != "c" {
+		fail(t, "Items not ordered")
+	}
+	// The limit applies after ordering
+	items = checkFind(t, stub, new(Query).OrderBy("I64", true).Limit(2), 2)
+	if items[0].I64 != 4 || items[1].I64 != 3 {
+		fail(t, "Wrong items found")
+	}
+	var all []TestStruct
+	if err := Find(stub, &all, new(Query).OrderBy("Nope", false)); err == nil {
+		fail(t, "Ordering on an unknown field should fail")
+	}
+}
+
+func TestFindByTime(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestTimed)); err != nil {
+		fail(t, err)
+	}
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, hours := range []int{1, 3, 0, 2} {
+		if err := Create(stub, &TestTimed{Created: start.Add(time.Duration(hours) * time.Hour)}); err != nil {
+			fail(t, err)
+		}
+	}
+
+	var items []TestTimed
+	if err := Find(stub, &items, Where("Created", ">", start.Add(time.Hour))); err != nil || len(items) != 2 {
+		fail(t, "Times should be compared")
+	}
+	q, err := ParseQuery("Id > 0 ORDER BY Created DESC")
+	if err != nil {
+		fail(t, err)
+	}
+	for _, q := range []*Query{new(Query).OrderBy("Created", true), q} {
+		items = nil
+		if err := Find(stub, &items, q); err != nil || len(items) != 4 {
+			fail(t, err)
+		}
+		if items[0].Id != 2 || items[1].Id != 4 || items[2].Id != 1 || items[3].Id != 3 {
+			fail(t, "Items should be ordered by time")
+		}
+	}
+}
+
+func TestFindOrderedShouldFail(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestRegistered)); err != nil {
+		fail(t, err)
+	}
+	for _, city := range []string{"Utrecht", "Gent"} {
+		if err := Create(stub, &TestRegistered{Address: TestAddress{City: city}}); err != nil {
+			fail(t, err)
+		}
+	}
+	var items []TestRegistered
+	checkErrorContains(t, Find(stub, &items, new(Query).OrderBy("Address", false)), "Cannot compare")
+}
+
+// Counts the rows read from a table
+type countingStub struct {
+	*shim.MockStub
+	table string
+	rows  int
+}
+
+func (s *countingStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	if tableName == s.table {
+		s.rows++
+	}
+	return s.MockStub.GetRows(tableName, key)
+}
+
+func TestFindIndexed(t *testing.T) {
+	stub := &countingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode)), table: "TestIndexed"}
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "NL", "DE")
+	stub.rows = 0
+
+	var items []TestIndexed
+	if err := Find(stub, &items, Where("Country", "=", "NL").And("Id", ">", 1)); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 1 || items[0].Id != 3 {
+		fail(t, "The query should find the items with the value in the index that match the other conditions")
+	}
+	if stub.rows != 0 {
+		fail(t, "An equality condition on an indexed field should not scan the table")
+	}
+	if err := Find(stub, &items, Where("Country", ">", "BE")); err != nil || stub.rows != 1 {
+		fail(t, "Other conditions should scan the table")
+	}
+}
@@ -0,0 +1,142 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// The result of a pass of RebuildIndexes
+type RebuildResult struct {
+	// Index rows added for items that were missing from an index
+	Inserted int
+	// Index rows removed that didn't match an item
+	Deleted int
+	// All indexes match the table. When false, run RebuildIndexes again in a new transaction.
+	Done bool
+}
+
+// Rebuild the secondary indexes of the table of prototype from its rows, e.g. when failed partial writes of older
+// versions left index rows of deleted items or items missing from an index. The result is the same as dropping and
+// refilling the indexes, but only the rows that differ are written, so the indexes stay usable between passes:
+//
+// result, err := orm.RebuildIndexes(stub, &User{}, orm.WithChunkSize(500))
+//
+// With WithChunkSize, a pass stops after that many writes; repeat it until the result is Done. Every pass reads the
+// table and all of its indexes.
+func RebuildIndexes(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, opts ...Option) (*RebuildResult, error) {
+	if err := checkStub(stub, true); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	o := newOptions(stub, opts).unprojected()
+	indexes, err := indexesOf(t, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rows the indexes should have, in the order of the table
+	type indexRow struct {
+		idx *index
+		key string
+		row shim.Row
+	}
+	var expected []indexRow
+	present := map[string]bool{}
+	var read *options
+	err = scanRows(stub, tableName(t, o), func(tbl *shim.Table, row shim.Row) error {
+		if read == nil {
+			if read, err = checkSchemaOnce(tbl, t, o); err != nil {
+				return err
+			}
+		}
+		item := reflect.New(t).Interface().(BlockchainItemizer)
+		if err := setValues(tbl, row, item, read); err != nil {
+			return errors.Wrap(err, "Error setting values.")
+		}
+		for i := range indexes {
+			if ok, err := indexes[i].includes(item); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+			row, err := indexes[i].row(item)
+			if err != nil {
+				return err
+			}
+			key, err := indexRowKey(indexes[i], row)
+			if err != nil {
+				return err
+			}
+			expected = append(expected, indexRow{idx: &indexes[i], key: key, row: row})
+			present[key] = false
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Index rows are removed after reading, not while the rows are being read
+	var stale []indexRow
+	for i := range indexes {
+		err := scanRows(stub, indexes[i].table, func(tbl *shim.Table, row shim.Row) error {
+			key, err := indexRowKey(indexes[i], row)
+			if err != nil {
+				return err
+			}
+			if _, ok := present[key]; ok {
+				present[key] = true
+			} else {
+				stale = append(stale, indexRow{idx: &indexes[i], key: key, row: row})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &RebuildResult{}
+	full := func() bool { return o.chunkSize > 0 && result.Inserted+result.Deleted >= o.chunkSize }
+	for _, r := range stale {
+		if full() {
+			break
+		}
+		if err := stub.DeleteRow(r.idx.table, indexKey(r.row)); err != nil {
+			return result, errors.Wrap(err, "Could not rebuild index "+r.idx.name)
+		}
+		result.Deleted++
+	}
+	missing := 0
+	for _, r := range expected {
+		if present[r.key] {
+			continue
+		}
+		missing++
+		if full() {
+			continue
+		}
+		if _, err := stub.InsertRow(r.idx.table, r.row); err != nil {
+			return result, errors.Wrap(err, "Could not rebuild index "+r.idx.name)
+		}
+		present[r.key] = true
+		result.Inserted++
+	}
+	result.Done = result.Deleted == len(stale) && result.Inserted == missing
+	logger.Infof("Rebuilt indexes of %s: %d rows inserted, %d deleted", tableName(t, o), result.Inserted, result.Deleted)
+	return result, nil
+}
+
+// Identify a row of an index
+func indexRowKey(idx index, row shim.Row) (string, error) {
+	key, err := json.Marshal(toWireRow(row.Columns))
+	if err != nil {
+		return "", errors.Wrap(err, "Could not read index "+idx.name)
+	}
+	return idx.table + string(key), nil
+}
@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func checkRebuild(t *testing.T, stub shim.ChaincodeStubInterface, inserted, deleted int, done bool, opts ...Option) {
+	result, err := RebuildIndexes(stub, new(TestIndexed), opts...)
+	if err != nil {
+		fail(t, err)
+	}
+	if result.Inserted != inserted || result.Deleted != deleted || result.Done != done {
+		fail(t, result)
+	}
+}
+
+func TestRebuildIndexes(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE", "NL")
+	checkRebuild(t, stub, 0, 0, true)
+
+	// Leave out the index rows of items 1 and 2 and add rows of items that don't exist
+	for _, key := range [][]shim.Column{
+		{{Value: &shim.Column_String_{String_: "NL"}}, {Value: &shim.Column_Int64{Int64: 1}}},
+		{{Value: &shim.Column_String_{String_: "BE"}}, {Value: &shim.Column_Int64{Int64: 2}}},
+	} {
+		if err := stub.DeleteRow("TestIndexed_idx_country", key); err != nil {
+			fail(t, err)
+		}
+	}
+	for _, id := range []int64{3, 9} {
+		row := shim.Row{Columns: []*shim.Column{{Value: &shim.Column_String_{String_: "DE"}}, {Value: &shim.Column_Int64{Int64: id}}}}
+		if _, err := stub.InsertRow("TestIndexed_idx_country", row); err != nil {
+			fail(t, err)
+		}
+	}
+
+	checkRebuild(t, stub, 0, 2, false, WithChunkSize(2))
+	checkRebuild(t, stub, 2, 0, true, WithChunkSize(2))
+	checkRebuild(t, stub, 0, 0, true)
+	if ids := checkIndexIds(t, stub, "NL"); len(ids) != 2 {
+		fail(t, "Index should have 2 rows for NL")
+	}
+	if ids := checkIndexIds(t, stub, "BE"); len(ids) != 1 || ids[0] != 2 {
+		fail(t, "Index should have item 2 for BE")
+	}
+	if ids := checkIndexIds(t, stub, "DE"); len(ids) != 0 {
+		fail(t, "Stale index rows should be removed")
+	}
+}
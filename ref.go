@@ -0,0 +1,132 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// A reference code is a readable identifier generated by Create, declared with a pattern on a string field:
+//
+// Reference string `ref:"INV-{year}-{id:6}"`
+//
+// The placeholders are {id}, {year}, {month} and {day}; a width like {id:6} pads the number with zeros. The date is
+// taken from the Clock. The field is indexed and unique. Create keeps a code that is already set.
+var refPlaceholder = regexp.MustCompile(`\{(id|year|month|day)(:(\d+))?\}`)
+
+// Get the field of type t that holds the reference code
+func refFieldOf(t reflect.Type) (field, bool) {
+	for _, f := range fieldsOf(t) {
+		if f.Tag.Get("ref") != "" && f.Type.Kind() == reflect.String {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Get the name of the index of a reference field
+func refIndexName(f field) string {
+	return "ref_" + f.Name
+}
+
+// Generate the reference code of a created item, unless it is set, and check that it is unique
+func setRef(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(item).Elem()
+	f, ok := refFieldOf(t)
+	if !ok {
+		return nil
+	}
+	v := reflect.ValueOf(item).Elem().FieldByIndex(f.Index)
+	if v.String() == "" {
+		code, err := formatRef(stub, f.Tag.Get("ref"), item.GetId(), o)
+		if err != nil {
+			return errors.Wrap(err, "Could not generate reference code")
+		}
+		v.SetString(code)
+	}
+	return checkRef(stub, item, o)
+}
+
+// Check that no other item has the reference code of an item
+func checkRef(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	t := reflect.TypeOf(item).Elem()
+	f, ok := refFieldOf(t)
+	if !ok {
+		return nil
+	}
+	idx, err := indexOn(t, f.Name, o)
+	if err != nil || idx == nil {
+		return err
+	}
+	code := reflect.ValueOf(item).Elem().FieldByIndex(f.Index).String()
+	ids, err := idx.lookup(stub, code)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id != item.GetId() {
+			return errors.Errorf("Reference %s is already used by %s %d", code, t.Name(), id)
+		}
+	}
+	return nil
+}
+
+// Fill in the placeholders of a reference pattern
+func formatRef(stub shim.ChaincodeStubInterface, pattern string, id int64, o *options) (string, error) {
+	var err error
+	code := refPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		m := refPlaceholder.FindStringSubmatch(placeholder)
+		width, _ := strconv.Atoi(m[3])
+		var n int64
+		if m[1] == "id" {
+			n = id
+		} else {
+			t, e := now(stub, o)
+			if e != nil {
+				err = e
+				return ""
+			}
+			switch m[1] {
+			case "year":
+				n = int64(t.Year())
+			case "month":
+				n = int64(t.Month())
+			case "day":
+				n = int64(t.Day())
+			}
+		}
+		return fmt.Sprintf("%0*d", width, n)
+	})
+	return code, err
+}
+
+// Get an item by its reference code. Returns ErrNotFound if there is no such item.
+func GetByRef(stub shim.ChaincodeStubInterface, item BlockchainItemizer, code string, opts ...Option) error {
+	if err := checkStub(stub, false); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	t := reflect.TypeOf(item).Elem()
+	f, ok := refFieldOf(t)
+	if !ok {
+		return errors.New(t.Name() + " has no field tagged ref")
+	}
+	o := newOptions(stub, opts)
+	idx, err := indexOn(t, f.Name, o)
+	if err != nil {
+		return err
+	}
+	ids, err := idx.lookup(stub, code)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return ErrNotFound
+	}
+	return Get(stub, item, ids[0], opts...)
+}
@@ -0,0 +1,49 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+	"time"
+)
+
+type TestInvoice struct {
+	Reference string `ref:"INV-{year}-{id:6}"`
+	Amount    int64
+	Saveable
+}
+
+func TestRef(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	Configure(Config{Clock: FixedClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))})
+	defer Configure(Config{})
+	if err := CreateTable(stub, new(TestInvoice)); err != nil {
+		fail(t, err)
+	}
+
+	invoice := TestInvoice{Amount: 10}
+	if err := Create(stub, &invoice); err != nil {
+		fail(t, err)
+	}
+	if invoice.Reference != "INV-2024-000001" {
+		fail(t, "Unexpected reference "+invoice.Reference)
+	}
+	if err := Create(stub, &TestInvoice{Reference: "MANUAL"}); err != nil {
+		fail(t, err)
+	}
+
+	var found TestInvoice
+	if err := GetByRef(stub, &found, "INV-2024-000001"); err != nil {
+		fail(t, err)
+	}
+	if found != invoice {
+		fail(t, "Should get the item by its reference")
+	}
+	if err := GetByRef(stub, &found, "INV-2024-000009"); err != ErrNotFound {
+		fail(t, "Unknown references should return ErrNotFound")
+	}
+
+	checkErrorContains(t, Create(stub, &TestInvoice{Reference: "MANUAL"}), "already used")
+	invoice.Reference = "MANUAL"
+	checkErrorContains(t, Update(stub, &invoice), "already used")
+}
@@ -0,0 +1,172 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// Get the entity whose id a field holds, declared with a tag: UserId int64 `references:"User"` or `orm:"fk=User"`
+func referenceOf(f reflect.StructField) string {
+	if ref := f.Tag.Get("references"); ref != "" {
+		return ref
+	}
+	for _, option := range strings.Split(f.Tag.Get("orm"), ",") {
+		if option = strings.TrimSpace(option); strings.HasPrefix(option, "fk=") {
+			return strings.TrimPrefix(option, "fk=")
+		}
+	}
+	return ""
+}
+
+// Load the item a foreign key refers to into target, a pointer to an item or to a pointer to an item:
+//
+// type Order struct {
+// 	OwnerId int64 `orm:"fk=User"`
+// 	Owner   *User `orm:"-"`
+// 	orm.Saveable
+// }
+//
+// err := orm.Load(stub, &order.Owner, order.OwnerId)
+//
+// An id of 0 refers to nothing and sets target to nil or the zero value. Returns ErrNotFound if there is no item
+// with the id.
+func Load(stub shim.ChaincodeStubInterface, target interface{}, id int64, opts ...Option) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("Object passed to Load should be a pointer")
+	}
+	if v.Elem().Kind() == reflect.Ptr {
+		if id == 0 {
+			v.Elem().Set(reflect.Zero(v.Elem().Type()))
+			return nil
+		}
+		p := reflect.New(v.Elem().Type().Elem())
+		item, ok := p.Interface().(BlockchainItemizer)
+		if !ok {
+			return errors.Errorf("Cannot load %v: not an item", v.Elem().Type())
+		}
+		if err := Get(stub, item, id, opts...); err != nil {
+			return err
+		}
+		v.Elem().Set(p)
+		return nil
+	}
+
+	item, ok := target.(BlockchainItemizer)
+	if !ok {
+		return errors.Errorf("Cannot load %v: not an item", v.Type())
+	}
+	if id == 0 {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		return nil
+	}
+	return Get(stub, item, id, opts...)
+}
+
+// Load the given relations when getting an item, e.g. WithPreload("Owner") to load the Owner field from the foreign
+// key OwnerId.
+func WithPreload(relations ...string) Option {
+	return func(o *options) {
+		o.preload = append(o.preload, relations...)
+	}
+}
+
+// Load the relations of an item that were asked for with WithPreload
+func preload(stub shim.ChaincodeStubInterface, item BlockchainItemizer, o *options) error {
+	v := reflect.ValueOf(item).Elem()
+	t := v.Type()
+	for _, name := range o.preload {
+		rel, ok := t.FieldByName(name)
+		if !ok {
+			return errors.New("Field " + name + " not found in " + t.Name())
+		}
+		if skip, _ := ormTagOf(rel); !skip {
+			return errors.New("Relation " + name + " of " + t.Name() + ` should be left out of the table with orm:"-"`)
+		}
+		fk, err := foreignKeyOf(t, name+"Id")
+		if err != nil {
+			return errors.Wrap(err, "Relation "+name+" of "+t.Name()+" has no foreign key")
+		} else if referenceOf(fk) == "" {
+			return errors.New("Foreign key " + fk.Name + " of " + t.Name() + " should declare the entity it refers to")
+		}
+		target := rel.Type
+		if target.Kind() == reflect.Ptr {
+			target = target.Elem()
+		}
+		if referenceOf(fk) != target.Name() {
+			return errors.Errorf("Foreign key %s refers to %s, not %s", fk.Name, referenceOf(fk), target.Name())
+		}
+		if err := Load(stub, v.FieldByIndex(rel.Index).Addr().Interface(), toInt64(v.FieldByIndex(fk.Index))); err != nil {
+			return errors.Wrap(err, "Could not load "+name+" of "+t.Name())
+		}
+	}
+	return nil
+}
+
+// Get the children of an item by passing a pointer to a slice of their type and their foreign key to the parent:
+//
+// var orders []Order
+// err := orm.GetChildren(stub, &user, &orders, "UserId")
+//
+// Index the foreign key to read only the children instead of scanning their table.
+func GetChildren(stub shim.ChaincodeStubInterface, parent BlockchainItemizer, children interface{}, foreignKey string, opts ...Option) error {
+	if err := checkItem(parent); err != nil {
+		return err
+	}
+	if err := checkSlice(children); err != nil {
+		return errors.Wrap(err, "Object passed to GetChildren should be a pointer to a slice")
+	}
+	if parent.GetId() == 0 {
+		return errors.New("Parent has no id")
+	}
+	t := reflect.TypeOf(children).Elem().Elem()
+	fk, err := foreignKeyOf(t, foreignKey)
+	if err != nil {
+		return err
+	}
+	if ref, name := referenceOf(fk), reflect.TypeOf(parent).Elem().Name(); ref != "" && ref != name {
+		return errors.Errorf("Foreign key %s refers to %s, not %s", fk.Name, ref, name)
+	}
+	return Find(stub, children, Where(foreignKey, "=", parent.GetId()), opts...)
+}
+
+// Check whether the items that refer to another item with a foreign key are deleted with it, declared with a tag:
+// UserId int64 `orm:"fk=User,ondelete=cascade"`
+func cascades(f reflect.StructField) bool {
+	for _, option := range strings.Split(f.Tag.Get("orm"), ",") {
+		if strings.TrimSpace(option) == "ondelete=cascade" {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete the items of registered types that refer to a deleted item with a cascading foreign key. Their children are
+// deleted in turn, with the options of the delete, so they are found in the same namespace.
+func deleteChildren(stub shim.ChaincodeStubInterface, parent BlockchainItemizer, o *options, opts []Option) error {
+	parentType := reflect.TypeOf(parent).Elem()
+	for _, t := range registered() {
+		for _, f := range fieldsOf(t) {
+			if referenceOf(f.StructField) != parentType.Name() || !cascades(f.StructField) {
+				continue
+			}
+			if _, err := stub.GetTable(tableName(t, o)); err == shim.ErrTableNotFound {
+				continue
+			}
+			children := reflect.New(reflect.SliceOf(t))
+			if err := Find(stub, children.Interface(), Where(f.Name, "=", parent.GetId()), opts...); err != nil {
+				return errors.Wrapf(err, "Could not find the %s of %s %d", t.Name(), parentType.Name(), parent.GetId())
+			}
+			for i := 0; i < children.Elem().Len(); i++ {
+				child := children.Elem().Index(i).Addr().Interface().(BlockchainItemizer)
+				// A child that was deleted already, through another foreign key, is not found
+				if err := Delete(stub, child, opts...); err != nil && err != ErrNotFound {
+					return errors.Wrapf(err, "Could not delete %s %d of %s %d", t.Name(), child.GetId(), parentType.Name(), parent.GetId())
+				}
+			}
+		}
+	}
+	return nil
+}
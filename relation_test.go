@@ -0,0 +1,188 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"reflect"
+	"testing"
+)
+
+type TestLine struct {
+	ParentId int64        `orm:"fk=TestIndexed"`
+	Parent   *TestIndexed `orm:"-"`
+	Saveable
+}
+
+type TestWronglyRelated struct {
+	ParentId int64      `orm:"fk=TestIndexed"`
+	Parent   *TestOrder `orm:"-"`
+	Saveable
+}
+
+func TestLoad(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL")
+	if err := CreateTable(stub, &TestLine{}); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestLine{ParentId: 1}); err != nil {
+		fail(t, err)
+	}
+
+	var line TestLine
+	if err := Get(stub, &line, 1); err != nil {
+		fail(t, err)
+	}
+	if line.Parent != nil {
+		fail(t, "Relations should not be loaded by default")
+	}
+	if err := Load(stub, &line.Parent, line.ParentId); err != nil || line.Parent == nil || line.Parent.Country != "NL" {
+		fail(t, "The item the foreign key refers to should be loaded")
+	}
+	var parent TestIndexed
+	if err := Load(stub, &parent, line.ParentId); err != nil || parent.Id != 1 {
+		fail(t, "Items should be loaded without a pointer as well")
+	}
+	if err := Load(stub, &line.Parent, 0); err != nil || line.Parent != nil {
+		fail(t, "An id of 0 should load nothing")
+	}
+	if err := Load(stub, &line.Parent, 2); err != ErrNotFound {
+		fail(t, "Missing items should not be found")
+	}
+
+	// Preloaded when getting the item
+	line = TestLine{}
+	if err := Get(stub, &line, 1, WithPreload("Parent")); err != nil || line.Parent == nil || line.Parent.Id != 1 {
+		fail(t, "Preloaded relations should be loaded")
+	}
+	if err := Get(stub, &line, 1, WithPreload("ParentId")); err == nil {
+		fail(t, "Stored fields can't be preloaded")
+	}
+	if err := CreateTable(stub, &TestWronglyRelated{}); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestWronglyRelated{ParentId: 1}); err != nil {
+		fail(t, err)
+	}
+	err := Get(stub, &TestWronglyRelated{}, 1, WithPreload("Parent"))
+	checkErrorContains(t, err, "refers to TestIndexed, not TestOrder")
+
+	if e := describe(reflect.TypeOf(TestLine{})); e.Columns[0].References != "TestIndexed" {
+		fail(t, "The foreign key should be described")
+	}
+}
+
+func TestGetChildren(t *testing.T) {
+	stub := &countingStub{MockStub: shim.NewMockStub("cc", new(MockChaincode)), table: "TestOrder"}
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE")
+	checkCreateOrders(t, stub, &TestOrder{}, 1, 2, 1)
+	stub.rows = 0
+
+	var parent TestIndexed
+	if err := Get(stub, &parent, 1); err != nil {
+		fail(t, err)
+	}
+	var orders []TestOrder
+	if err := GetChildren(stub, &parent, &orders, "ParentId"); err != nil || len(orders) != 2 {
+		fail(t, "The children of the parent should be found")
+	}
+	if stub.rows != 0 {
+		fail(t, "Children should be read from the index of the foreign key")
+	}
+
+	var lines []TestLine
+	err := GetChildren(stub, &TestOrder{Saveable: Saveable{Id: 1}}, &lines, "ParentId")
+	checkErrorContains(t, err, "refers to TestIndexed, not TestOrder")
+	if err := GetChildren(stub, &parent, &orders, "Amount2"); err == nil {
+		fail(t, "Unknown foreign keys should fail")
+	}
+}
+
+type TestAccount struct {
+	Name string
+	Saveable
+}
+
+type TestPosting struct {
+	AccountId int64 `orm:"fk=TestAccount,ondelete=cascade"`
+	Saveable
+}
+
+type TestPostingLine struct {
+	PostingId int64 `index:"posting" orm:"fk=TestPosting,ondelete=cascade"`
+	Saveable
+}
+
+type TestAccountNote struct {
+	AccountId int64 `orm:"fk=TestAccount"`
+	Saveable
+}
+
+func TestCascadeDelete(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	for _, item := range []BlockchainItemizer{&TestAccount{}, &TestPosting{}, &TestPostingLine{}, &TestAccountNote{}} {
+		if err := CreateTable(stub, item); err != nil {
+			fail(t, err)
+		}
+	}
+	items := []BlockchainItemizer{
+		&TestAccount{Name: "a"}, &TestAccount{Name: "b"},
+		&TestPosting{AccountId: 1}, &TestPosting{AccountId: 2},
+		&TestPostingLine{PostingId: 1}, &TestPostingLine{PostingId: 1}, &TestPostingLine{PostingId: 2},
+		&TestAccountNote{AccountId: 1},
+	}
+	for _, item := range items {
+		if err := Create(stub, item); err != nil {
+			fail(t, err)
+		}
+	}
+
+	if err := Delete(stub, &TestAccount{Saveable: Saveable{Id: 1}}); err != nil {
+		fail(t, err)
+	}
+	var postings []TestPosting
+	if err := GetAll(stub, &postings); err != nil || len(postings) != 1 || postings[0].AccountId != 2 {
+		fail(t, "The children of the deleted item should be deleted")
+	}
+	var lines []TestPostingLine
+	if err := GetAll(stub, &lines); err != nil || len(lines) != 1 || lines[0].PostingId != 2 {
+		fail(t, "The children of deleted children should be deleted")
+	}
+	if n, err := CountBy(stub, &TestPostingLine{}, "PostingId", 1); err != nil || n != 0 {
+		fail(t, "The indexes of deleted children should be cleaned up")
+	}
+	var notes []TestAccountNote
+	if err := GetAll(stub, &notes); err != nil || len(notes) != 1 {
+		fail(t, "Items with a foreign key that doesn't cascade should be kept")
+	}
+}
+
+func TestCascadeDeleteNamespaced(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	for _, opts := range [][]Option{nil, {WithNamespace("x_")}} {
+		for _, item := range []BlockchainItemizer{&TestAccount{}, &TestPosting{}} {
+			if err := CreateTable(stub, item, opts...); err != nil {
+				fail(t, err)
+			}
+		}
+		for _, item := range []BlockchainItemizer{&TestAccount{Name: "a"}, &TestPosting{AccountId: 1}} {
+			if err := Create(stub, item, opts...); err != nil {
+				fail(t, err)
+			}
+		}
+	}
+
+	if err := Delete(stub, &TestAccount{Saveable: Saveable{Id: 1}}, WithNamespace("x_")); err != nil {
+		fail(t, err)
+	}
+	var postings []TestPosting
+	if err := GetAll(stub, &postings, WithNamespace("x_")); err != nil || len(postings) != 0 {
+		fail(t, "The children in the namespace of the deleted item should be deleted")
+	}
+	if err := GetAll(stub, &postings); err != nil || len(postings) != 1 {
+		fail(t, "The children in other namespaces should be kept")
+	}
+}
@@ -0,0 +1,174 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+)
+
+// Runs the steps of an operation on several entities as a whole within one invocation:
+//
+// err := orm.NewSaga(stub).
+//   Step("debit", func(stub shim.ChaincodeStubInterface) error { return orm.Update(stub, &from) }, nil).
+//   Step("notify", notify, unnotify).
+//   Step("credit", func(stub shim.ChaincodeStubInterface) error { return orm.Update(stub, &to) }, nil).
+//   Run()
+//
+// The steps get a stub that logs the table writes. When a step fails, the writes of the previous steps are undone in
+// reverse order, and then the compensators of those steps are called in reverse order for effects outside the tables,
+// like a call of another chaincode. Events are only set when all steps succeed. Pass a Session to keep its limits.
+type Saga struct {
+	stub  shim.ChaincodeStubInterface
+	steps []sagaStep
+}
+
+type sagaStep struct {
+	name       string
+	run        func(stub shim.ChaincodeStubInterface) error
+	compensate func(stub shim.ChaincodeStubInterface) error
+}
+
+// Start a saga on a stub
+func NewSaga(stub shim.ChaincodeStubInterface) *Saga {
+	return &Saga{stub: stub}
+}
+
+// Add a step. The compensator can be nil when the step only writes to tables.
+func (s *Saga) Step(name string, run, compensate func(stub shim.ChaincodeStubInterface) error) *Saga {
+	s.steps = append(s.steps, sagaStep{name: name, run: run, compensate: compensate})
+	return s
+}
+
+// Run the steps in order. Returns the error of the failed step, or of the undo when that fails as well; then return it
+// from the invocation, since the state is no longer known to be consistent.
+func (s *Saga) Run() error {
+	if err := checkStub(s.stub, true); err != nil {
+		return err
+	}
+	tx := &sagaStub{ChaincodeStubInterface: s.stub}
+	for i, step := range s.steps {
+		err := step.run(tx)
+		if err == nil {
+			continue
+		}
+		err = errors.Wrapf(err, "Step %s failed", step.name)
+		logger.Warningf("%v; undoing %d steps", err, i)
+		if uerr := tx.undo(); uerr != nil {
+			return errors.Wrapf(uerr, "Could not undo the writes after: %v", err)
+		}
+		for j := i - 1; j >= 0; j-- {
+			if c := s.steps[j].compensate; c != nil {
+				if cerr := c(s.stub); cerr != nil {
+					return errors.Wrapf(cerr, "Could not compensate step %s after: %v", s.steps[j].name, err)
+				}
+			}
+		}
+		return err
+	}
+	if tx.event != nil {
+		return s.stub.SetEvent(tx.event.name, tx.event.payload)
+	}
+	return nil
+}
+
+// A stub that logs how to undo its table writes and holds back its event
+type sagaStub struct {
+	shim.ChaincodeStubInterface
+	undos []func() error
+	event *sagaEvent
+}
+
+type sagaEvent struct {
+	name    string
+	payload []byte
+}
+
+// Undo the logged writes in reverse order
+func (s *sagaStub) undo() error {
+	for i := len(s.undos) - 1; i >= 0; i-- {
+		if err := s.undos[i](); err != nil {
+			return err
+		}
+	}
+	s.undos = nil
+	return nil
+}
+
+func (s *sagaStub) SetEvent(name string, payload []byte) error {
+	s.event = &sagaEvent{name: name, payload: payload}
+	return nil
+}
+
+func (s *sagaStub) CreateTable(name string, columnDefinitions []*shim.ColumnDefinition) error {
+	if err := s.ChaincodeStubInterface.CreateTable(name, columnDefinitions); err != nil {
+		return err
+	}
+	s.undos = append(s.undos, func() error { return s.ChaincodeStubInterface.DeleteTable(name) })
+	return nil
+}
+
+func (s *sagaStub) InsertRow(tableName string, row shim.Row) (bool, error) {
+	ok, err := s.ChaincodeStubInterface.InsertRow(tableName, row)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.logUndo(tableName, row, shim.Row{})
+}
+
+func (s *sagaStub) ReplaceRow(tableName string, row shim.Row) (bool, error) {
+	key, err := s.key(tableName, row)
+	if err != nil {
+		return false, err
+	}
+	previous, err := s.ChaincodeStubInterface.GetRow(tableName, key)
+	if err != nil {
+		return false, err
+	}
+	ok, err := s.ChaincodeStubInterface.ReplaceRow(tableName, row)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, s.logUndo(tableName, row, previous)
+}
+
+func (s *sagaStub) DeleteRow(tableName string, key []shim.Column) error {
+	previous, err := s.ChaincodeStubInterface.GetRow(tableName, key)
+	if err != nil {
+		return err
+	}
+	if err := s.ChaincodeStubInterface.DeleteRow(tableName, key); err != nil {
+		return err
+	}
+	if len(previous.Columns) > 0 {
+		s.undos = append(s.undos, func() error {
+			_, err := s.ChaincodeStubInterface.InsertRow(tableName, previous)
+			return err
+		})
+	}
+	return nil
+}
+
+// Log how to restore the previous row of a written row, or remove it if there was none
+func (s *sagaStub) logUndo(tableName string, row, previous shim.Row) error {
+	if len(previous.Columns) > 0 {
+		s.undos = append(s.undos, func() error {
+			_, err := s.ChaincodeStubInterface.ReplaceRow(tableName, previous)
+			return err
+		})
+		return nil
+	}
+	key, err := s.key(tableName, row)
+	if err != nil {
+		return err
+	}
+	s.undos = append(s.undos, func() error { return s.ChaincodeStubInterface.DeleteRow(tableName, key) })
+	return nil
+}
+
+// Get the key columns of a row of a table
+func (s *sagaStub) key(tableName string, row shim.Row) ([]shim.Column, error) {
+	tbl, err := s.ChaincodeStubInterface.GetTable(tableName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get table "+tableName)
+	}
+	return keyColumns(tbl, row), nil
+}
@@ -0,0 +1,67 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestSaga(t *testing.T) {
+	mock := shim.NewMockStub("cc", new(MockChaincode))
+	mock.MockTransactionStart("test")
+	stub := &eventStub{MockStub: mock, events: map[string][]byte{}}
+	checkCreateIndexed(t, stub, "NL", "BE")
+
+	var compensated []string
+	compensate := func(name string) func(shim.ChaincodeStubInterface) error {
+		return func(shim.ChaincodeStubInterface) error {
+			compensated = append(compensated, name)
+			return nil
+		}
+	}
+	create := func(stub shim.ChaincodeStubInterface) error {
+		return Create(stub, &TestIndexed{Country: "DE"}, WithEventEmission(true))
+	}
+	update := func(stub shim.ChaincodeStubInterface) error {
+		return Update(stub, &TestIndexed{Country: "FR", Saveable: Saveable{Id: 1}})
+	}
+	remove := func(stub shim.ChaincodeStubInterface) error {
+		return Delete(stub, &TestIndexed{Saveable: Saveable{Id: 2}})
+	}
+	failing := func(stub shim.ChaincodeStubInterface) error { return errors.New("Out of stock") }
+
+	err := NewSaga(stub).
+		Step("create", create, compensate("create")).
+		Step("update", update, nil).
+		Step("delete", remove, compensate("delete")).
+		Step("fail", failing, compensate("fail")).
+		Run()
+	checkErrorContains(t, err, "Step fail failed: Out of stock")
+
+	var items []TestIndexed
+	if err := GetAll(stub, &items); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 2 || items[0].Country != "NL" || items[1].Country != "BE" {
+		fail(t, "The writes should be undone")
+	}
+	if ids := checkIndexIds(t, stub, "NL"); len(ids) != 1 || len(checkIndexIds(t, stub, "DE")) != 0 || len(checkIndexIds(t, stub, "FR")) != 0 {
+		fail(t, "The index writes should be undone")
+	}
+	if len(compensated) != 2 || compensated[0] != "delete" || compensated[1] != "create" {
+		fail(t, "The completed steps should be compensated in reverse order")
+	}
+	if len(stub.events) != 0 {
+		fail(t, "Events should not be set")
+	}
+
+	if err := NewSaga(stub).Step("create", create, nil).Step("update", update, nil).Run(); err != nil {
+		fail(t, err)
+	}
+	if _, ok := stub.events["TestIndexed.Created"]; !ok {
+		fail(t, "The event should be set")
+	}
+	if len(checkIndexIds(t, stub, "FR")) != 1 {
+		fail(t, "The update should be stored")
+	}
+}
@@ -0,0 +1,105 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Called by SaveWith when a row with the id of the incoming item already exists. Return the item that should be
+// stored, or an error to abort the save. The id of the returned item is always set to the id of existing.
+type ConflictResolver func(existing, incoming BlockchainItemizer) (BlockchainItemizer, error)
+
+// Save an item that might already exist, e.g. when importing reference data from another organization.
+// Items with id 0 are created. Items with an id that is not in the table yet are inserted with that id.
+// Otherwise onConflict decides what is stored. Afterwards, item holds the stored values.
+func SaveWith(stub shim.ChaincodeStubInterface, item BlockchainItemizer, onConflict ConflictResolver, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	if item.GetId() == 0 {
+		return Create(stub, item, opts...)
+	}
+	o := newOptions(stub, opts)
+	if _, ok := item.(EventSourcer); ok {
+		return ErrEventSourced
+	}
+
+	t := reflect.TypeOf(item).Elem()
+	name := tableName(t, o)
+	existing, err := getStoredItem(stub, item, o)
+	if err != nil {
+		return err
+	}
+
+	// No conflict
+	if existing == nil {
+		if err := authorize(stub, "create", item); err != nil {
+			return err
+		}
+		if err := setRef(stub, item, o); err != nil {
+			return err
+		}
+		if err := prepareWrite(item, o); err != nil {
+			return err
+		}
+		logger.Infof("Inserting %v with id %d", t.Name(), item.GetId())
+		return insertItem(stub, name, item, o)
+	}
+
+	// The resolver may modify existing, but the index rows of the stored values have to be replaced
+	if err := authorize(stub, "update", existing); err != nil {
+		return err
+	}
+	stored := reflect.New(t)
+	stored.Elem().Set(reflect.ValueOf(existing).Elem())
+
+	merged, err := onConflict(existing, item)
+	if err != nil {
+		return errors.Wrapf(err, "Could not resolve conflict for %s with id %d", t.Name(), item.GetId())
+	}
+	if merged == nil || reflect.TypeOf(merged) != reflect.TypeOf(item) {
+		return errors.New("Conflict resolver should return an item of type " + t.Name())
+	}
+	merged.SetId(existing.GetId())
+	if err := prepareWrite(merged, o); err != nil {
+		return err
+	}
+
+	logger.Infof("Merged %v with id %d", t.Name(), merged.GetId())
+	if err := replaceItem(stub, name, stored.Interface().(BlockchainItemizer), merged, o); err != nil {
+		return err
+	}
+	reflect.ValueOf(item).Elem().Set(reflect.ValueOf(merged).Elem())
+	return nil
+}
+
+// A ConflictResolver that stores the incoming item
+func Overwrite(existing, incoming BlockchainItemizer) (BlockchainItemizer, error) {
+	return incoming, nil
+}
+
+// Insert or replace an item, when it isn't known whether it exists. Items with id 0 are created, and other items are
+// inserted or replace the stored item with their id. Items keyed by their own fields, with Keyed, are found by their
+// key instead of their id.
+func Save(stub shim.ChaincodeStubInterface, item BlockchainItemizer, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	if t := reflect.TypeOf(item).Elem(); !keyedById(t) && item.GetId() == 0 {
+		stored, err := getStoredItem(stub, item, newOptions(stub, opts))
+		if err != nil {
+			return err
+		} else if stored == nil {
+			return Create(stub, item, opts...)
+		}
+		return Update(stub, item, opts...)
+	}
+	return SaveWith(stub, item, Overwrite, opts...)
+}
@@ -0,0 +1,135 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+	"time"
+)
+
+func keepExisting(existing, incoming BlockchainItemizer) (BlockchainItemizer, error) {
+	return existing, nil
+}
+
+func TestSaveWithInsertsNewId(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	s := getTestStruct()
+	s.Id = 42
+	if err := SaveWith(stub, &s, keepExisting); err != nil {
+		fail(t, err)
+	}
+	var a TestStruct
+	if err := Get(stub, &a, 42); err != nil {
+		fail(t, err)
+	}
+	checkEqual(t, a, s)
+}
+
+func TestSaveWithMerges(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreate(t, stub)
+
+	incoming := TestStruct{Str: "incoming", I64: 5}
+	incoming.Id = 1
+	err := SaveWith(stub, &incoming, func(existing, incoming BlockchainItemizer) (BlockchainItemizer, error) {
+		merged := existing.(*TestStruct)
+		merged.Str = incoming.(*TestStruct).Str
+		return merged, nil
+	})
+	if err != nil {
+		fail(t, err)
+	}
+
+	expected := getTestStruct()
+	expected.Str = "incoming"
+	checkEqual(t, incoming, expected)
+
+	a := checkGet(t, stub)
+	checkEqual(t, a, expected)
+}
+
+func TestSaveWithKeepExisting(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreate(t, stub)
+
+	incoming := TestStruct{Str: "incoming"}
+	incoming.Id = 1
+	if err := SaveWith(stub, &incoming, keepExisting); err != nil {
+		fail(t, err)
+	}
+	checkEqual(t, checkGet(t, stub), getTestStruct())
+}
+
+func TestSave(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	item := getTestStruct()
+	if err := Save(stub, &item); err != nil || item.Id != 1 {
+		fail(t, "An item with id 0 should be created")
+	}
+	item.Str = "saved"
+	if err := Save(stub, &item); err != nil || checkGet(t, stub).Str != "saved" {
+		fail(t, "An existing item should be replaced")
+	}
+	other := getTestStruct()
+	other.Id = 7
+	if err := Save(stub, &other); err != nil {
+		fail(t, err)
+	}
+	var stored TestStruct
+	if err := Get(stub, &stored, 7); err != nil {
+		fail(t, "An item with an unknown id should be inserted")
+	}
+
+	if err := CreateTable(stub, new(TestSerial)); err != nil {
+		fail(t, err)
+	}
+	for _, owner := range []string{"alice", "bob"} {
+		if err := Save(stub, &TestSerial{Serial: "SN-1", Owner: owner}); err != nil {
+			fail(t, err)
+		}
+	}
+	var serial TestSerial
+	if err := GetByKeys(stub, &serial, "SN-1"); err != nil || serial.Owner != "bob" || serial.Id != 1 {
+		fail(t, "Items with a natural key should be saved by their key")
+	}
+}
+
+func TestSaveWithRefs(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	Configure(Config{Clock: FixedClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))})
+	defer Configure(Config{})
+	if err := CreateTable(stub, new(TestInvoice)); err != nil {
+		fail(t, err)
+	}
+
+	invoice := TestInvoice{Amount: 10, Saveable: Saveable{Id: 7}}
+	if err := Save(stub, &invoice); err != nil {
+		fail(t, err)
+	}
+	if invoice.Reference != "INV-2024-000007" {
+		fail(t, "Inserted items should get a reference code, got "+invoice.Reference)
+	}
+	var found TestInvoice
+	if err := GetByRef(stub, &found, "INV-2024-000007"); err != nil || found.Id != 7 {
+		fail(t, "Inserted items should be found by their reference code")
+	}
+
+	if err := SaveWith(stub, &TestInvoice{Reference: "MANUAL", Saveable: Saveable{Id: 8}}, Overwrite); err != nil {
+		fail(t, err)
+	}
+	invoice.Reference = "MANUAL"
+	checkErrorContains(t, Save(stub, &invoice), "already used")
+	if err := Get(stub, &found, 7); err != nil || found.Reference != "INV-2024-000007" {
+		fail(t, "Merges with a duplicate reference code should not be written")
+	}
+}
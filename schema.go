@@ -0,0 +1,129 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// Returned with StrictSchema when the columns of a table don't match the fields of the struct
+type SchemaMismatchError struct {
+	Table       string
+	Differences []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("Columns of %s do not match the struct: %s", e.Table, strings.Join(e.Differences, "; "))
+}
+
+
+// Check whether the table of an item has a column, e.g. to support several versions of a schema. This looks at the
+// table as it was created, not at the current struct.
+func HasColumn(stub shim.ChaincodeStubInterface, item BlockchainItemizer, column string, opts ...Option) (bool, error) {
+	cd, err := columnDefinition(stub, item, column, newOptions(stub, opts))
+	return cd != nil, err
+}
+
+// Get the type of a column of the table of an item
+func ColumnType(stub shim.ChaincodeStubInterface, item BlockchainItemizer, column string, opts ...Option) (shim.ColumnDefinition_Type, error) {
+	cd, err := columnDefinition(stub, item, column, newOptions(stub, opts))
+	if err != nil {
+		return 0, err
+	}
+	if cd == nil {
+		return 0, errors.New("Column " + column + " not found")
+	}
+	return cd.Type, nil
+}
+
+// Get the definition of a column of the table of an item, or nil if there is no such column
+func columnDefinition(stub shim.ChaincodeStubInterface, item BlockchainItemizer, column string, o *options) (*shim.ColumnDefinition, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(item); err != nil {
+		return nil, err
+	}
+	name := tableName(reflect.TypeOf(item).Elem(), o)
+	tbl, err := stub.GetTable(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get table "+name)
+	}
+	for _, cd := range tbl.ColumnDefinitions {
+		if cd.Name == column {
+			return cd, nil
+		}
+	}
+	return nil, nil
+}
+
+// Get the definitions of the table of an item: the columns of its fields, then the schema version and checksum
+func tableDefinitions(item BlockchainItemizer, o *options) ([]*shim.ColumnDefinition, error) {
+	cds, err := createColumnDefinitions(item, o)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := item.(SchemaVersioner); ok {
+		cds = append(cds, &shim.ColumnDefinition{Name: schemaVersionColumn, Type: shim.ColumnDefinition_UINT32})
+	}
+	if _, ok := item.(Checksummer); ok {
+		cds = append(cds, &shim.ColumnDefinition{Name: checksumColumn, Type: shim.ColumnDefinition_BYTES})
+	}
+	return cds, nil
+}
+
+// Check that a table has the columns of the definitions of an item, in order
+func checkSchema(tbl *shim.Table, item interface{}, o *options) error {
+	prototype, ok := item.(BlockchainItemizer)
+	if !ok {
+		return errors.Errorf("Cannot check the schema of %T", item)
+	}
+	expected, err := tableDefinitions(prototype, o)
+	if err != nil {
+		return err
+	}
+	var differences []string
+	for i := 0; i < len(expected) || i < len(tbl.ColumnDefinitions); i++ {
+		switch {
+		case i >= len(tbl.ColumnDefinitions):
+			differences = append(differences, fmt.Sprintf("column %d %s is missing", i+1, describeColumn(expected[i])))
+		case i >= len(expected):
+			differences = append(differences, fmt.Sprintf("column %d %s has no field", i+1, describeColumn(tbl.ColumnDefinitions[i])))
+		default:
+			cd, e := tbl.ColumnDefinitions[i], expected[i]
+			if cd.Name != e.Name || cd.Type != e.Type || cd.Key != e.Key {
+				differences = append(differences, fmt.Sprintf("column %d is %s, expected %s", i+1, describeColumn(cd), describeColumn(e)))
+			}
+		}
+	}
+	if len(differences) > 0 {
+		return &SchemaMismatchError{Table: tbl.Name, Differences: differences}
+	}
+	return nil
+}
+
+// Check the schema of a table once before reading its rows, when StrictSchema is set. Returns a copy of the options
+// to read the rows with that doesn't check it again; setValues doesn't change it, so concurrent readers can share it.
+func checkSchemaOnce(tbl *shim.Table, t reflect.Type, o *options) (*options, error) {
+	if o.conf().StrictSchema {
+		if err := checkSchema(tbl, reflect.New(t).Interface(), o); err != nil {
+			return nil, err
+		}
+	}
+	c := options{config: currentConfig()}
+	if o != nil {
+		c = *o
+	}
+	c.schemaChecked = true
+	return &c, nil
+}
+
+// Describe a column definition, like "Id INT64 key"
+func describeColumn(cd *shim.ColumnDefinition) string {
+	if cd.Key {
+		return cd.Name + " " + cd.Type.String() + " key"
+	}
+	return cd.Name + " " + cd.Type.String()
+}
@@ -0,0 +1,92 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestHasColumn(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	if ok, err := HasColumn(stub, &TestStruct{}, "Str"); err != nil || !ok {
+		fail(t, "Table should have column Str")
+	}
+	if ok, err := HasColumn(stub, &TestStruct{}, "Unknown"); err != nil || ok {
+		fail(t, "Table should not have column Unknown")
+	}
+	if _, err := HasColumn(stub, &TestEvent{}, "Amount"); err == nil {
+		fail(t, "HasColumn should fail when the table doesn't exist")
+	}
+}
+
+func TestColumnType(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	if typ, err := ColumnType(stub, &TestStruct{}, "UI32"); err != nil || typ != shim.ColumnDefinition_UINT32 {
+		fail(t, "Column UI32 should have type UINT32")
+	}
+	if typ, err := ColumnType(stub, &TestStruct{}, "Id"); err != nil || typ != shim.ColumnDefinition_INT64 {
+		fail(t, "Column Id should have type INT64")
+	}
+	if _, err := ColumnType(stub, &TestStruct{}, "Unknown"); err == nil {
+		fail(t, "ColumnType should fail for an unknown column")
+	}
+}
+
+func TestStrictSchema(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+
+	// A table whose columns were swapped by hand, so reading by name would still work
+	type TestSwapped struct {
+		First  string
+		Second string
+		Saveable
+	}
+	if err := stub.CreateTable("TestSwapped", []*shim.ColumnDefinition{
+		{Name: "Second", Type: shim.ColumnDefinition_STRING},
+		{Name: "First", Type: shim.ColumnDefinition_STRING},
+		{Name: "Id", Type: shim.ColumnDefinition_INT64, Key: true},
+	}); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestSwapped{First: "a", Second: "b"}); err != nil {
+		fail(t, err)
+	}
+
+	var item TestSwapped
+	if err := Get(stub, &item, 1); err != nil {
+		fail(t, err)
+	}
+	e, ok := errors.Cause(Get(stub, &item, 1, WithStrictSchema(true))).(*SchemaMismatchError)
+	if !ok {
+		fail(t, "Tables that don't match the struct should fail with StrictSchema")
+	}
+	if len(e.Differences) != 2 || e.Differences[0] != "column 1 is Second STRING, expected First STRING" {
+		fail(t, "The differences should be listed")
+	}
+
+	stub2 := shim.NewMockStub("cc", new(MockChaincode))
+	stub2.MockTransactionStart("test")
+	checkCreateTable(t, stub2)
+	checkCreateItems(t, stub2, "a", "b")
+	var items []TestStruct
+	if err := GetAll(stub2, &items, WithStrictSchema(true)); err != nil || len(items) != 2 {
+		fail(t, "Tables that match the struct should be read")
+	}
+
+	// Concurrent decoders share the options of the fetch
+	checkCreateItems(t, stub2, "c", "d", "e", "f")
+	if err := Fetch(stub2, WithStrictSchema(true)).Add(&items).Workers(4).Run(); err != nil || len(items) != 6 {
+		fail(t, "Fetched tables that match the struct should be read")
+	}
+	var swapped []TestSwapped
+	if _, ok := errors.Cause(Fetch(stub, WithStrictSchema(true)).Add(&swapped).Workers(4).Run()).(*SchemaMismatchError); !ok {
+		fail(t, "Fetched tables that don't match the struct should fail with StrictSchema")
+	}
+}
@@ -0,0 +1,57 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+)
+
+// The table with the last id of every table that uses NextId
+const sequenceTable = "orm_sequences"
+
+// An IdStrategy that keeps a counter per table in the table orm_sequences, so creating an item reads one row instead
+// of the whole table. The counter is read and written in the state of the transaction, so several items created in
+// one invocation get different ids, and ids of deleted items are never given out again. The counter of a table with
+// items starts at its highest id.
+//
+// orm.Configure(orm.Config{IdStrategy: orm.NextId})
+func NextId(stub shim.ChaincodeStubInterface, table string) (int64, error) {
+	if _, err := stub.GetTable(sequenceTable); err == shim.ErrTableNotFound {
+		logger.Infof("Create Table %s", sequenceTable)
+		err := stub.CreateTable(sequenceTable, []*shim.ColumnDefinition{
+			{Name: "Table", Type: shim.ColumnDefinition_STRING, Key: true},
+			{Name: "Last", Type: shim.ColumnDefinition_INT64},
+		})
+		if err != nil {
+			return 0, errors.Wrap(err, "Could not create table "+sequenceTable)
+		}
+	} else if err != nil {
+		return 0, errors.Wrap(err, "Could not get table "+sequenceTable)
+	}
+
+	key := []shim.Column{{Value: &shim.Column_String_{String_: table}}}
+	row, err := stub.GetRow(sequenceTable, key)
+	if err != nil {
+		return 0, errors.Wrap(err, "Could not get sequence of "+table)
+	}
+	var id int64
+	exists := len(row.Columns) > 0
+	if exists {
+		id = row.Columns[1].GetInt64() + 1
+	} else if id, err = generateId(stub, table); err != nil {
+		return 0, err // The first id of a table that may have been filled with another strategy
+	}
+
+	row = shim.Row{Columns: []*shim.Column{
+		{Value: &shim.Column_String_{String_: table}},
+		{Value: &shim.Column_Int64{Int64: id}},
+	}}
+	if exists {
+		_, err = stub.ReplaceRow(sequenceTable, row)
+	} else {
+		_, err = stub.InsertRow(sequenceTable, row)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "Could not update sequence of "+table)
+	}
+	return id, nil
+}
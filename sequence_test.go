@@ -0,0 +1,35 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestNextId(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b")
+
+	// The counter starts at the highest id of the table
+	Configure(Config{IdStrategy: NextId})
+	defer Configure(Config{})
+	checkCreateItems(t, stub, "c", "d")
+	var items []TestStruct
+	if err := GetAll(stub, &items, WithSortedResults(true)); err != nil || len(items) != 4 || items[3].Id != 4 {
+		fail(t, "Items created in one invocation should get different ids")
+	}
+
+	// Ids of deleted items are not given out again
+	if err := Delete(stub, &items[3]); err != nil {
+		fail(t, err)
+	}
+	item := getTestStruct()
+	if err := Create(stub, &item); err != nil || item.Id != 5 {
+		fail(t, "The id of a deleted item should not be reused")
+	}
+	row, err := stub.GetRow(sequenceTable, []shim.Column{{Value: &shim.Column_String_{String_: "TestStruct"}}})
+	if err != nil || row.Columns[1].GetInt64() != 5 {
+		fail(t, "The counter should hold the last id")
+	}
+}
@@ -0,0 +1,307 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// A Session wraps the stub for the duration of one invocation. It implements shim.ChaincodeStubInterface, so pass it
+// to the functions of this package instead of the stub:
+//
+// session := orm.NewSession(stub)
+// session.MaxRows = 1000
+// err := orm.GetAll(session, &users)
+type Session struct {
+	shim.ChaincodeStubInterface
+
+	// Maximum number of rows a single scan (e.g. GetAll) reads. 0 means no limit.
+	MaxRows int
+	// Maximum duration of a single scan. 0 means no limit. Endorsers read at a different speed, so a scan stopped by
+	// it returns different rows on different endorsers and their endorsements don't match. Return an error to the
+	// client on ErrResultTruncated instead of the rows read so far, or use MaxRows, which stops at the same row
+	// everywhere.
+	MaxDuration time.Duration
+	// Rows read ahead of a scan while the previous rows are decoded. 0 uses Prefetch of the configuration.
+	Prefetch int
+	// Update an item that is created again in the same session, instead of returning ErrRepeatedWrite
+	MergeRepeatedWrites bool
+	// Hold back writes until Flush, so a row that is written more than once is written to the stub once
+	Batch bool
+	// Keep the rows read by key, so reading a row again, e.g. the same owner of many assets, doesn't read it from the
+	// stub again. Writes to a table through the session forget its rows.
+	CacheReads bool
+
+	// The last write of every item in this session, by table and id
+	writes map[string]writeKind
+	stats  SessionStats
+	// The writes held back until Flush when batching
+	batch *batch
+	// The rows read by key when caching reads, by table and key
+	reads map[string]map[string]shim.Row
+}
+
+// What a session has read and written so far, including the rows of indexes
+type SessionStats struct {
+	RowsRead    int
+	RowsWritten int
+	// The size of the values of the written rows
+	BytesWritten int
+	// Rows read again from the session instead of the stub, when caching reads
+	CachedReads int
+	// The use of indexes, by index table, to find unused indexes that only add to the cost of writes
+	Indexes map[string]IndexStats `json:",omitempty"`
+	// Full scans of entity tables, by table, e.g. by queries without an index to use
+	Scans map[string]int `json:",omitempty"`
+}
+
+// How a session used an index
+type IndexStats struct {
+	// Lookups of values in the index instead of scanning the table
+	Lookups int
+	// Rows written to keep the index up to date
+	RowsWritten int
+}
+
+type writeKind int
+
+const (
+	created writeKind = iota + 1
+	updated
+	deleted
+)
+
+// Returned when a scan is stopped because it exceeds MaxRows or MaxDuration of the session. The rows read so far
+// are still returned.
+var ErrResultTruncated = errors.New("Result truncated: the scan exceeded the limits of the session.")
+
+// Returned when a session writes an item in a way that conflicts with an earlier write in the same session: creating
+// it twice, or updating it after it was deleted.
+var ErrRepeatedWrite = errors.New("Conflicting write in the same session.")
+
+// Create a session for a stub
+func NewSession(stub shim.ChaincodeStubInterface) *Session {
+	return &Session{ChaincodeStubInterface: stub, writes: map[string]writeKind{}}
+}
+
+// Get the session of a stub, or nil if the stub is not a session
+func sessionOf(stub shim.ChaincodeStubInterface) *Session {
+	if saga, ok := stub.(*sagaStub); ok {
+		stub = saga.ChaincodeStubInterface
+	}
+	session, _ := stub.(*Session)
+	return session
+}
+
+// Check whether a scan that has read rows since started may read another row
+func (s *Session) guard(rows int, started time.Time) error {
+	if s == nil {
+		return nil
+	}
+	if s.MaxRows > 0 && rows >= s.MaxRows {
+		logger.Warningf("Scan stopped after %d rows", rows)
+		return ErrResultTruncated
+	}
+	if s.MaxDuration > 0 && time.Since(started) > s.MaxDuration {
+		logger.Warningf("Scan stopped after %v", time.Since(started))
+		if err := auditDeterminism(nil, "scan stopped by MaxDuration after %d rows", rows); err != nil {
+			return err
+		}
+		return ErrResultTruncated
+	}
+	return nil
+}
+
+// Get the last write of an item in the session
+func (s *Session) lastWrite(table string, id int64) writeKind {
+	if s == nil {
+		return 0
+	}
+	return s.writes[fmt.Sprintf("%s/%d", table, id)]
+}
+
+// Remember a write of an item
+func (s *Session) record(table string, id int64, kind writeKind) {
+	if s == nil {
+		return
+	}
+	if s.writes == nil {
+		s.writes = map[string]writeKind{}
+	}
+	s.writes[fmt.Sprintf("%s/%d", table, id)] = kind
+}
+
+// Get what the session has read and written so far, e.g. at the end of Invoke to enforce a budget
+func (s *Session) Stats() SessionStats {
+	stats := s.stats
+	stats.Indexes, stats.Scans = nil, nil
+	for table, is := range s.stats.Indexes {
+		if stats.Indexes == nil {
+			stats.Indexes = map[string]IndexStats{}
+		}
+		stats.Indexes[table] = is
+	}
+	for table, n := range s.stats.Scans {
+		if stats.Scans == nil {
+			stats.Scans = map[string]int{}
+		}
+		stats.Scans[table] = n
+	}
+	return stats
+}
+
+// Count a lookup in an index
+func (s *Session) countLookup(table string) {
+	if s == nil {
+		return
+	}
+	if s.stats.Indexes == nil {
+		s.stats.Indexes = map[string]IndexStats{}
+	}
+	is := s.stats.Indexes[table]
+	is.Lookups++
+	s.stats.Indexes[table] = is
+}
+
+// Count a row written to an index
+func (s *Session) countIndexWrite(table string) {
+	if s == nil {
+		return
+	}
+	if s.stats.Indexes == nil {
+		s.stats.Indexes = map[string]IndexStats{}
+	}
+	is := s.stats.Indexes[table]
+	is.RowsWritten++
+	s.stats.Indexes[table] = is
+}
+
+// Count a full scan of an entity table
+func (s *Session) countScan(table string) {
+	if s == nil {
+		return
+	}
+	if s.stats.Scans == nil {
+		s.stats.Scans = map[string]int{}
+	}
+	s.stats.Scans[table]++
+}
+
+// Count the rows of a scan
+func (s *Session) countRead(rows int) {
+	if s != nil {
+		s.stats.RowsRead += rows
+	}
+}
+
+// Count the row that is read. A row written by a batching session is read from the session.
+func (s *Session) GetRow(tableName string, key []shim.Column) (shim.Row, error) {
+	if row, ok, err := s.batchGet(tableName, key); ok || err != nil {
+		return row, err
+	}
+	if !s.CacheReads {
+		row, err := s.ChaincodeStubInterface.GetRow(tableName, key)
+		if err == nil && len(row.Columns) > 0 {
+			s.stats.RowsRead++
+		}
+		return row, err
+	}
+
+	k, err := rowKey(tableName, key)
+	if err != nil {
+		return shim.Row{}, err
+	}
+	if row, ok := s.reads[tableName][k]; ok {
+		s.stats.CachedReads++
+		return row, nil
+	}
+	row, err := s.ChaincodeStubInterface.GetRow(tableName, key)
+	if err != nil {
+		return row, err
+	}
+	if len(row.Columns) > 0 {
+		s.stats.RowsRead++
+	}
+	if s.reads == nil {
+		s.reads = map[string]map[string]shim.Row{}
+	}
+	if s.reads[tableName] == nil {
+		s.reads[tableName] = map[string]shim.Row{}
+	}
+	s.reads[tableName][k] = row
+	return row, nil
+}
+
+// Flush the writes to a table that are held back, so a scan of it sees them
+func (s *Session) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	if err := s.flushTable(tableName); err != nil {
+		return nil, err
+	}
+	return s.ChaincodeStubInterface.GetRows(tableName, key)
+}
+
+// Forget the writes to a table that are held back, and the rows read from it
+func (s *Session) DeleteTable(tableName string) error {
+	s.dropTable(tableName)
+	delete(s.reads, tableName)
+	return s.ChaincodeStubInterface.DeleteTable(tableName)
+}
+
+// Count the row that is written
+func (s *Session) InsertRow(tableName string, row shim.Row) (bool, error) {
+	delete(s.reads, tableName)
+	if s.Batch {
+		return s.holdRow(tableName, row, created)
+	}
+	ok, err := s.ChaincodeStubInterface.InsertRow(tableName, row)
+	if ok {
+		s.countWrite(row)
+	}
+	return ok, err
+}
+
+// Count the row that is written
+func (s *Session) ReplaceRow(tableName string, row shim.Row) (bool, error) {
+	delete(s.reads, tableName)
+	if s.Batch {
+		return s.holdRow(tableName, row, updated)
+	}
+	ok, err := s.ChaincodeStubInterface.ReplaceRow(tableName, row)
+	if ok {
+		s.countWrite(row)
+	}
+	return ok, err
+}
+
+// Count the row that is deleted
+func (s *Session) DeleteRow(tableName string, key []shim.Column) error {
+	delete(s.reads, tableName)
+	if s.Batch {
+		_, err := s.hold(tableName, key, nil, deleted)
+		return err
+	}
+	err := s.ChaincodeStubInterface.DeleteRow(tableName, key)
+	if err == nil {
+		s.stats.RowsWritten++
+	}
+	return err
+}
+
+func (s *Session) countWrite(row shim.Row) {
+	s.stats.RowsWritten++
+	for _, c := range row.Columns {
+		switch val := c.GetValue().(type) {
+		case *shim.Column_String_:
+			s.stats.BytesWritten += len(val.String_)
+		case *shim.Column_Bytes:
+			s.stats.BytesWritten += len(val.Bytes)
+		case *shim.Column_Bool:
+			s.stats.BytesWritten++
+		case *shim.Column_Int32, *shim.Column_Uint32:
+			s.stats.BytesWritten += 4
+		default:
+			s.stats.BytesWritten += 8
+		}
+	}
+}
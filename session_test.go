@@ -0,0 +1,184 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+func TestSessionMaxRows(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreate(t, stub)
+	checkCreate(t, stub)
+	checkCreate(t, stub)
+
+	session := NewSession(stub)
+	session.MaxRows = 2
+	var items []TestStruct
+	if err := GetAll(session, &items); err != ErrResultTruncated {
+		fail(t, "GetAll should be truncated")
+	}
+	if len(items) != 2 {
+		fail(t, "GetAll should return the rows read before truncating")
+	}
+
+	session.MaxRows = 3
+	items = nil
+	if err := GetAll(session, &items); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 3 {
+		fail(t, "GetAll should return all rows within the limit")
+	}
+
+	session.MaxRows = 2
+	if err := Create(session, &TestStruct{}); err == nil {
+		fail(t, "Create should fail when not all ids can be read")
+	}
+}
+
+func TestSessionMaxDuration(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreate(t, stub)
+
+	session := NewSession(stub)
+	session.MaxDuration = time.Nanosecond
+	var items []TestStruct
+	if err := GetAll(session, &items); err != ErrResultTruncated {
+		fail(t, "GetAll should be truncated")
+	}
+}
+
+func TestSessionRepeatedWrites(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+
+	session := NewSession(stub)
+	item := TestStruct{Str: "a"}
+	if err := Create(session, &item); err != nil {
+		fail(t, err)
+	}
+	if err := Create(session, &item); errors.Cause(err) != ErrRepeatedWrite {
+		fail(t, "Creating an item twice should fail")
+	}
+
+	session.MergeRepeatedWrites = true
+	item.Str = "b"
+	if err := Create(session, &item); err != nil {
+		fail(t, err)
+	}
+	var items []TestStruct
+	if err := GetAll(session, &items); err != nil {
+		fail(t, err)
+	}
+	if len(items) != 1 || items[0].Str != "b" {
+		fail(t, "Creating an item twice should update it")
+	}
+
+	if err := Delete(session, &item); err != nil {
+		fail(t, err)
+	}
+	if err := Update(session, &item); errors.Cause(err) != ErrRepeatedWrite {
+		fail(t, "Updating a deleted item should fail")
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE")
+
+	session := NewSession(stub)
+	if err := Create(session, &TestIndexed{Country: "DE", Name: "x"}); err != nil {
+		fail(t, err)
+	}
+	// The item, its index row, and two ids read to generate the id
+	if s := session.Stats(); s.RowsWritten != 2 || s.RowsRead != 2 || s.BytesWritten != 2+1+8+2+8 {
+		fail(t, session.Stats())
+	}
+
+	var item TestIndexed
+	if err := Get(session, &item, 3); err != nil {
+		fail(t, err)
+	}
+	var items []TestIndexed
+	if err := GetAll(session, &items); err != nil {
+		fail(t, err)
+	}
+	if s := session.Stats(); s.RowsRead != 2+1+3 {
+		fail(t, session.Stats())
+	}
+}
+
+func TestSessionIndexStats(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	session := NewSession(stub)
+	checkCreateIndexed(t, session, "NL", "BE")
+
+	var items []TestIndexed
+	if err := Find(session, &items, Where("Country", "=", "NL")); err != nil {
+		fail(t, err)
+	}
+	if err := Find(session, &items, Where("Name", "=", "")); err != nil {
+		fail(t, err)
+	}
+	stats := session.Stats()
+	if is := stats.Indexes["TestIndexed_idx_country"]; is.Lookups != 1 || is.RowsWritten != 2 {
+		fail(t, "The lookups and writes of the index should be counted")
+	}
+	if stats.Scans["TestIndexed"] != 1 {
+		fail(t, "The scan of the unindexed field should be counted")
+	}
+	stats.Scans["TestIndexed"] = 5
+	if session.Stats().Scans["TestIndexed"] != 1 {
+		fail(t, "Stats should be a copy")
+	}
+
+	usages, err := Storage(session)
+	if err != nil {
+		fail(t, err)
+	}
+	for _, u := range usages {
+		if u.Entity == "TestIndexed" && (u.Tables[0].Scans != 1 || u.Tables[1].Lookups != 1 || u.Tables[1].RowsWritten != 2) {
+			fail(t, "The report should include the use of the tables in the session")
+		}
+	}
+}
+
+func TestSessionCacheReads(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE")
+	session := NewSession(stub)
+	session.CacheReads = true
+
+	var item TestIndexed
+	for i := 0; i < 3; i++ {
+		if err := Get(session, &item, 1); err != nil {
+			fail(t, err)
+		}
+	}
+	if stats := session.Stats(); stats.RowsRead != 1 || stats.CachedReads != 2 {
+		fail(t, "A row should be read from the stub once")
+	}
+
+	// Writes through the session forget the rows of the table
+	item.Country = "DE"
+	if err := Update(session, &item); err != nil {
+		fail(t, err)
+	}
+	item = TestIndexed{}
+	if err := Get(session, &item, 1); err != nil || item.Country != "DE" {
+		fail(t, "Rows should be read again after a write")
+	}
+	if err := Get(session, &TestIndexed{}, 3); err != ErrNotFound {
+		fail(t, "Missing rows should not be found from the cache either")
+	}
+}
@@ -0,0 +1,79 @@
+package orm
+
+import (
+	"github.com/pkg/errors"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// What to do with a string or byte slice that is longer than the size in its tag, e.g. Name string `size:"256"`
+type SizePolicy int
+
+const (
+	// Return an error
+	RejectOversized SizePolicy = iota
+	// Cut the value to the size. Strings are cut at a character boundary, so they can be a few bytes shorter.
+	TruncateOversized
+)
+
+// Use a different SizePolicy for this operation
+func WithSizePolicy(policy SizePolicy) Option {
+	return func(o *options) {
+		o.config.Sizes = policy
+	}
+}
+
+// Check the sizes of the fields of an item before it is written. Truncated values are set to the item.
+func applySizes(item BlockchainItemizer, o *options) error {
+	v := reflect.ValueOf(item).Elem()
+	for _, f := range fieldsOf(v.Type()) {
+		tag := f.Tag.Get("size")
+		if tag == "" {
+			continue
+		}
+		size, err := strconv.Atoi(tag)
+		if err != nil || size < 0 {
+			return errors.Errorf("Invalid size %q of %s", tag, f.Name)
+		}
+		fv := v.FieldByIndex(f.Index)
+
+		switch {
+		case fv.Kind() == reflect.String:
+			s := fv.String()
+			if len(s) <= size {
+				continue
+			}
+			if o.conf().Sizes != TruncateOversized {
+				return errors.Errorf("%s is %d bytes, more than its size %d", f.Name, len(s), size)
+			}
+			fv.SetString(s[:runeCut(s, size)])
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8:
+			if fv.Len() <= size {
+				continue
+			}
+			if o.conf().Sizes != TruncateOversized {
+				return errors.Errorf("%s is %d bytes, more than its size %d", f.Name, fv.Len(), size)
+			}
+			fv.Set(fv.Slice(0, size))
+		default:
+			return errors.Errorf("Size of %s: only strings and byte slices have a size", f.Name)
+		}
+		logger.Warningf("Truncated %s of %s to %d bytes", f.Name, v.Type().Name(), size)
+	}
+	return nil
+}
+
+// Get where to cut a string to at most size bytes without splitting the character at the cut. Invalid UTF-8 before
+// the cut is kept.
+func runeCut(s string, size int) int {
+	for i := size - 1; i >= 0 && i > size-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			if _, n := utf8.DecodeRuneInString(s[i:]); i+n > size {
+				return i
+			}
+			break
+		}
+	}
+	return size
+}
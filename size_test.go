@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestSized struct {
+	Name string `size:"5"`
+	Saveable
+}
+
+func TestSize(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestSized)); err != nil {
+		fail(t, err)
+	}
+
+	checkErrorContains(t, Create(stub, &TestSized{Name: "too long"}), "size 5")
+	item := TestSized{Name: "short"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+
+	item.Name = "lengthy"
+	checkErrorContains(t, Update(stub, &item), "size 5")
+	if err := Update(stub, &item, WithSizePolicy(TruncateOversized)); err != nil {
+		fail(t, err)
+	}
+	if item.Name != "lengt" {
+		fail(t, "Name should be truncated: "+item.Name)
+	}
+
+	// Multi-byte characters are not cut in half
+	Configure(Config{Sizes: TruncateOversized})
+	defer Configure(Config{})
+	item.Name = "abcdé"
+	if err := Update(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if item.Name != "abcd" {
+		fail(t, "Name should be truncated at a character boundary: "+item.Name)
+	}
+	item.Name = "a\xffbcdef"
+	if err := Update(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if item.Name != "a\xffbcd" {
+		fail(t, "Invalid UTF-8 before the cut should be kept: "+item.Name)
+	}
+}
@@ -0,0 +1,87 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// The stored rows of a table, as written by SnapshotRows
+type rowSnapshot struct {
+	Table   string
+	Columns []wireDefinition
+	Rows    [][]wireColumn
+}
+
+// Serialize the definition and rows of the table of prototype, to keep in testdata and check with CheckSnapshot after
+// the struct changes:
+//
+// snapshot, err := orm.SnapshotRows(stub, &User{})
+// err = ioutil.WriteFile("testdata/User.json", snapshot, 0644)
+//
+// Take the snapshot in a test that creates items with the current version of the struct.
+func SnapshotRows(stub shim.ChaincodeStubInterface, prototype BlockchainItemizer, opts ...Option) ([]byte, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	if err := checkItem(prototype); err != nil {
+		return nil, err
+	}
+	name := tableName(reflect.TypeOf(prototype).Elem(), newOptions(stub, opts))
+
+	snapshot := rowSnapshot{Table: name, Rows: [][]wireColumn{}}
+	err := scanRows(stub, name, func(tbl *shim.Table, row shim.Row) error {
+		if snapshot.Columns == nil {
+			snapshot.Columns = toWireDefinitions(tbl.ColumnDefinitions)
+		}
+		snapshot.Rows = append(snapshot.Rows, toWireRow(row.Columns))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Columns == nil {
+		tbl, err := stub.GetTable(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not get table "+name)
+		}
+		snapshot.Columns = toWireDefinitions(tbl.ColumnDefinitions)
+	}
+	return json.MarshalIndent(snapshot, "", "  ")
+}
+
+// Check that every row of a snapshot taken with SnapshotRows can still be read into the current version of the struct
+// of prototype, including with its version adapters, e.g. in a test of the package that declares it:
+//
+// snapshot, err := ioutil.ReadFile("testdata/User.json")
+// if err := orm.CheckSnapshot(snapshot, &User{}); err != nil {
+//   t.Error(err)
+// }
+func CheckSnapshot(snapshot []byte, prototype BlockchainItemizer, opts ...Option) error {
+	if err := checkItem(prototype); err != nil {
+		return err
+	}
+	var s rowSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return errors.Wrap(err, "Invalid snapshot")
+	}
+	t := reflect.TypeOf(prototype).Elem()
+	o := newOptions(nil, opts).unprojected()
+	tbl := &shim.Table{Name: s.Table, ColumnDefinitions: fromWireDefinitions(s.Columns)}
+
+	var failures []string
+	for i, row := range s.Rows {
+		item := reflect.New(t).Interface()
+		if err := setValues(tbl, shim.Row{Columns: fromWireRow(row)}, item, o); err != nil {
+			failures = append(failures, fmt.Sprintf("row %d: %v", i+1, err))
+		}
+	}
+	if len(failures) > 0 {
+		return errors.Errorf("%d of %d rows of %s cannot be read into %s: %s", len(failures), len(s.Rows), s.Table,
+			t.Name(), strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+// A later version of TestStruct: a field was added and one was removed
+type TestStructAdded struct {
+	Str   string
+	I64   int64
+	Added string
+	Saveable
+}
+
+// A later version of TestStruct that changed the type of a field
+type TestStructBroken struct {
+	Str int64
+	Saveable
+}
+
+func TestSnapshotRows(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b")
+
+	snapshot, err := SnapshotRows(stub, new(TestStruct))
+	if err != nil {
+		fail(t, err)
+	}
+	if err := CheckSnapshot(snapshot, new(TestStruct)); err != nil {
+		fail(t, err)
+	}
+	if err := CheckSnapshot(snapshot, new(TestStructAdded)); err != nil {
+		fail(t, err)
+	}
+	checkErrorContains(t, CheckSnapshot(snapshot, new(TestStructBroken)), "2 of 2 rows of TestStruct cannot be read into TestStructBroken")
+	checkErrorContains(t, CheckSnapshot([]byte("{"), new(TestStruct)), "Invalid snapshot")
+}
@@ -0,0 +1,43 @@
+package orm
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Sorts a slice of items by a field, and by id when the fields are equal
+type itemSorter struct {
+	items reflect.Value
+	field []int
+	tmp   reflect.Value
+}
+
+// Sort a slice of items by the field tagged `sort:"true"`, or by id
+func sortItems(items reflect.Value) {
+	s := &itemSorter{items: items, tmp: reflect.New(items.Type().Elem()).Elem()}
+	for _, f := range fieldsOf(items.Type().Elem()) {
+		if f.Tag.Get("sort") == "true" {
+			s.field = f.Index
+			break
+		}
+	}
+	sort.Stable(s)
+}
+
+func (s *itemSorter) Len() int { return s.items.Len() }
+
+func (s *itemSorter) Swap(i, j int) {
+	s.tmp.Set(s.items.Index(i))
+	s.items.Index(i).Set(s.items.Index(j))
+	s.items.Index(j).Set(s.tmp)
+}
+
+func (s *itemSorter) Less(i, j int) bool {
+	a, b := s.items.Index(i), s.items.Index(j)
+	if s.field != nil {
+		if cmp, err := compare(a.FieldByIndex(s.field), b.FieldByIndex(s.field).Interface()); err == nil && cmp != 0 {
+			return cmp < 0
+		}
+	}
+	return a.Addr().Interface().(BlockchainItemizer).GetId() < b.Addr().Interface().(BlockchainItemizer).GetId()
+}
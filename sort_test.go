@@ -0,0 +1,48 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestSorted struct {
+	Name string `sort:"true"`
+	Saveable
+}
+
+func TestSortResults(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestSorted)); err != nil {
+		fail(t, err)
+	}
+	// Ids 1 to 11 are not in numeric order in the table
+	names := []string{"k", "j", "i", "h", "g", "f", "e", "d", "c", "b", "b"}
+	for _, name := range names {
+		if err := Create(stub, &TestSorted{Name: name}); err != nil {
+			fail(t, err)
+		}
+	}
+
+	var items []TestSorted
+	if err := GetAll(stub, &items, WithSortedResults(true)); err != nil {
+		fail(t, err)
+	}
+	if items[0].Name != "b" || items[0].Id != 10 || items[1].Id != 11 || items[10].Name != "k" {
+		fail(t, "Items should be sorted by name, then by id")
+	}
+
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k")
+	Configure(Config{SortResults: true})
+	defer Configure(Config{})
+	var structs []TestStruct
+	if err := GetAll(stub, &structs); err != nil {
+		fail(t, err)
+	}
+	for i, s := range structs {
+		if s.Id != int64(i+1) {
+			fail(t, "Items should be sorted by id")
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// The storage used by an entity, for capacity planning
+type StorageUsage struct {
+	Entity string
+	// The table of the entity, then its indexes, versions and modifications
+	Tables []TableUsage
+	Rows   int
+	Bytes  int
+}
+
+// The storage used by a table. Bytes is the size of the rows serialized as JSON, which is close to their size in
+// the state database.
+type TableUsage struct {
+	Table string
+	Rows  int
+	Bytes int
+	// The use of the table so far in the session, when the stub is a Session: lookups in and rows written to an
+	// index, and full scans of the table of an entity
+	Lookups     int `json:",omitempty"`
+	RowsWritten int `json:",omitempty"`
+	Scans       int `json:",omitempty"`
+}
+
+// Measure the tables of all registered entities that exist, ordered by entity name. It reads every row; run it as an
+// admin query, not in a transaction. When the stub is a Session, the limits of the session apply.
+func Storage(stub shim.ChaincodeStubInterface, opts ...Option) ([]StorageUsage, error) {
+	if err := checkStub(stub, false); err != nil {
+		return nil, err
+	}
+	o := newOptions(stub, opts)
+	var usages []StorageUsage
+	for _, t := range registered() {
+		usage, err := storageOf(stub, t, o)
+		if err != nil {
+			return nil, err
+		}
+		if usage != nil {
+			usages = append(usages, *usage)
+		}
+	}
+	return usages, nil
+}
+
+// Measure the tables of all registered entities as JSON
+func StorageReport(stub shim.ChaincodeStubInterface, opts ...Option) ([]byte, error) {
+	usages, err := Storage(stub, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(usages, "", "  ")
+}
+
+// Measure the tables of the entity of type t, or nil if its table doesn't exist
+func storageOf(stub shim.ChaincodeStubInterface, t reflect.Type, o *options) (*StorageUsage, error) {
+	name := tableName(t, o)
+	if _, err := stub.GetTable(name); err == shim.ErrTableNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Could not get table "+name)
+	}
+	tables := []string{name}
+	indexes, err := indexesOf(t, o)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range indexes {
+		tables = append(tables, idx.table)
+	}
+	if keepsVersions(t) {
+		tables = append(tables, versionsTableName(name))
+	}
+	if tracksModifications(t) {
+		tables = append(tables, modificationsTableName(name))
+	}
+
+	usage := &StorageUsage{Entity: t.Name()}
+	for _, table := range tables {
+		tu := TableUsage{Table: table}
+		if session := sessionOf(stub); session != nil {
+			tu.Lookups = session.stats.Indexes[table].Lookups
+			tu.RowsWritten = session.stats.Indexes[table].RowsWritten
+			tu.Scans = session.stats.Scans[table]
+		}
+		err := scanRows(stub, table, func(tbl *shim.Table, row shim.Row) error {
+			data, err := json.Marshal(toWireRow(row.Columns))
+			if err != nil {
+				return errors.Wrap(err, "Could not measure "+table)
+			}
+			tu.Rows++
+			tu.Bytes += len(data)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		usage.Tables = append(usage.Tables, tu)
+		usage.Rows += tu.Rows
+		usage.Bytes += tu.Bytes
+	}
+	return usage, nil
+}
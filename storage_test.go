@@ -0,0 +1,37 @@
+package orm
+
+import (
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+func TestStorageReport(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateIndexed(t, stub, "NL", "BE")
+
+	data, err := StorageReport(stub)
+	if err != nil {
+		fail(t, err)
+	}
+	var usages []StorageUsage
+	if err := json.Unmarshal(data, &usages); err != nil {
+		fail(t, err)
+	}
+	var indexed *StorageUsage
+	for i := range usages {
+		if usages[i].Entity == "TestIndexed" {
+			indexed = &usages[i]
+		} else if usages[i].Rows > 0 {
+			fail(t, "Only the tables in the state should be measured")
+		}
+	}
+	if indexed == nil || len(indexed.Tables) != 2 || indexed.Tables[1].Table != "TestIndexed_idx_country" {
+		fail(t, "The table and indexes of the entity should be measured")
+	}
+	if indexed.Rows != 4 || indexed.Tables[0].Rows != 2 || indexed.Tables[0].Bytes == 0 ||
+		indexed.Bytes != indexed.Tables[0].Bytes+indexed.Tables[1].Bytes {
+		fail(t, indexed)
+	}
+}
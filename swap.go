@@ -0,0 +1,124 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Returned by Swap when an item differs from the stored item
+var ErrStaleItem = errors.New("Item was changed since it was read.")
+
+// Exchange the values of a field of two stored items, e.g. the owners of two assets in a trade:
+//
+// err := orm.Swap(stub, &assetA, "Owner", &assetB, "Owner")
+//
+// The items should be as they were read: when either differs from the stored item, ErrStaleItem is returned and nothing
+// is written. Both updates are authorized and checked before the first is written, and fields in a unique group or
+// index can be swapped as well. If a write still fails,
+// return the error from the invocation so the transaction is not committed. On success, the items hold the swapped
+// values.
+func Swap(stub shim.ChaincodeStubInterface, a BlockchainItemizer, fieldA string, b BlockchainItemizer, fieldB string, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(a); err != nil {
+		return err
+	}
+	if err := checkItem(b); err != nil {
+		return err
+	}
+	o := newOptions(stub, opts)
+	if reflect.TypeOf(a) == reflect.TypeOf(b) && a.GetId() == b.GetId() {
+		return errors.New("Cannot swap the fields of an item with itself")
+	}
+
+	fa, err := swappableField(a, fieldA)
+	if err != nil {
+		return err
+	}
+	fb, err := swappableField(b, fieldB)
+	if err != nil {
+		return err
+	}
+	if fa.Type != fb.Type {
+		return errors.Errorf("Cannot swap %s of type %v with %s of type %v", fieldA, fa.Type, fieldB, fb.Type)
+	}
+
+	// Work on the stored items, so a stale item can't overwrite other fields
+	var stored, before [2]BlockchainItemizer
+	for i, item := range []BlockchainItemizer{a, b} {
+		if _, ok := item.(EventSourcer); ok {
+			return ErrEventSourced
+		}
+		t := reflect.TypeOf(item).Elem()
+		s, err := getStoredItem(stub, item, o)
+		if err != nil {
+			return err
+		} else if s == nil {
+			return errors.Wrapf(ErrNotFound, "%s with id %d", tableName(t, o), item.GetId())
+		}
+		if changes, err := Diff(item, s); err != nil {
+			return err
+		} else if len(changes) > 0 {
+			return errors.Wrapf(ErrStaleItem, "%s with id %d: %v", tableName(t, o), item.GetId(), changes)
+		}
+		if err := authorize(stub, "update", s); err != nil {
+			return err
+		}
+		stored[i] = s
+		before[i] = reflect.New(t).Interface().(BlockchainItemizer)
+		reflect.ValueOf(before[i]).Elem().Set(reflect.ValueOf(s).Elem())
+	}
+
+	va := reflect.ValueOf(stored[0]).Elem().FieldByIndex(fa.Index)
+	vb := reflect.ValueOf(stored[1]).Elem().FieldByIndex(fb.Index)
+	tmp := reflect.New(fa.Type).Elem()
+	tmp.Set(va)
+	va.Set(vb)
+	vb.Set(tmp)
+	for _, s := range stored {
+		if err := prepareWrite(s, o); err != nil {
+			return err
+		}
+	}
+
+	// Release the unique values and index rows of both items first, so each can take the value of the other
+	for i, s := range stored {
+		if err := releaseUniques(stub, before[i], s, o); err != nil {
+			return errors.Wrap(err, "Swap failed")
+		}
+		if err := releaseIndexes(stub, before[i], s, o); err != nil {
+			return errors.Wrap(err, "Swap failed")
+		}
+	}
+	for i, s := range stored {
+		if err := replaceItem(stub, tableName(reflect.TypeOf(s).Elem(), o), before[i], s, o); err != nil {
+			return errors.Wrap(err, "Swap failed")
+		}
+	}
+	reflect.ValueOf(a).Elem().Set(reflect.ValueOf(stored[0]).Elem())
+	reflect.ValueOf(b).Elem().Set(reflect.ValueOf(stored[1]).Elem())
+	return nil
+}
+
+// Find a stored field of an item that can be swapped
+func swappableField(item BlockchainItemizer, name string) (field, error) {
+	t := reflect.TypeOf(item).Elem()
+	var f field
+	for _, stored := range fieldsOf(t) {
+		if stored.Name == name {
+			f = stored
+		}
+	}
+	if f.Index == nil {
+		return f, errors.New("Field " + name + " not found in " + t.Name())
+	}
+	if f.key || name == "Id" {
+		return f, errors.New("Cannot swap key field " + name + " of " + t.Name())
+	}
+	if _, ok := columnTypeOf(f.Type); !ok {
+		return f, errors.New("Field " + name + " of " + t.Name() + " is not stored")
+	}
+	return f, nil
+}
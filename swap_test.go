@@ -0,0 +1,83 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestSwap(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "a", "b")
+
+	var a, b TestStruct
+	if err := Get(stub, &a, 1); err != nil {
+		fail(t, err)
+	}
+	if err := Get(stub, &b, 2); err != nil {
+		fail(t, err)
+	}
+	if err := Swap(stub, &a, "Str", &b, "Str"); err != nil {
+		fail(t, err)
+	}
+	if a.Str != "b" || b.Str != "a" {
+		fail(t, "The items should hold the swapped values")
+	}
+	if checkGet(t, stub).Str != "b" {
+		fail(t, "The swap should be stored")
+	}
+
+	// a is stale now
+	stale := a
+	stale.I64 = 100
+	if err := Swap(stub, &stale, "Str", &b, "Str"); errors.Cause(err) != ErrStaleItem {
+		fail(t, "Swapping a stale item should fail")
+	}
+	checkErrorContains(t, Swap(stub, &a, "Str", &b, "I64"), "Cannot swap Str of type string with I64 of type int64")
+	checkErrorContains(t, Swap(stub, &a, "Id", &b, "Id"), "Cannot swap key field Id")
+	checkErrorContains(t, Swap(stub, &a, "Str", &a, "Str"), "with itself")
+	missing := TestStruct{Saveable: Saveable{Id: 9}}
+	if err := Swap(stub, &a, "Str", &missing, "Str"); errors.Cause(err) != ErrNotFound {
+		fail(t, "Swapping with a missing item should fail")
+	}
+}
+
+type TestSeat struct {
+	Seat  string `unique:"seat"`
+	Row   string `index:"row,unique"`
+	Guest string
+	Saveable
+}
+
+func TestSwapUnique(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestSeat{}); err != nil {
+		fail(t, err)
+	}
+	a, b := TestSeat{Seat: "2A", Row: "A", Guest: "x"}, TestSeat{Seat: "2B", Row: "B", Guest: "y"}
+	for _, item := range []*TestSeat{&a, &b} {
+		if err := Create(stub, item); err != nil {
+			fail(t, err)
+		}
+	}
+
+	if err := Swap(stub, &a, "Seat", &b, "Seat"); err != nil {
+		fail(t, err)
+	}
+	if err := Swap(stub, &a, "Row", &b, "Row"); err != nil {
+		fail(t, err)
+	}
+	if a.Seat != "2B" || b.Seat != "2A" || a.Row != "B" || b.Row != "A" {
+		fail(t, "The items should hold the swapped values")
+	}
+	if err := Create(stub, &TestSeat{Seat: "2A"}); err == nil {
+		fail(t, "The swapped unique values should still be taken")
+	}
+	var found TestSeat
+	if err := GetBy(stub, &found, "Row", "B"); err != nil || found.Id != a.Id {
+		fail(t, "The unique index should hold the swapped values")
+	}
+}
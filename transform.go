@@ -0,0 +1,129 @@
+package orm
+
+import (
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// Changes the value of a field when it is written or read, declared in order with a tag: Code string
+// `transform:"trim,upper"`. Either function can be nil. Write transforms run in the order of the tag before an item is
+// written, and the item holds the result; read transforms run in reverse order after a row is read.
+type Transformer struct {
+	Write func(value interface{}) (interface{}, error)
+	Read  func(value interface{}) (interface{}, error)
+}
+
+var transformers = map[string]Transformer{
+	"trim":  {Write: stringTransform(strings.TrimSpace)},
+	"upper": {Write: stringTransform(strings.ToUpper)},
+	"lower": {Write: stringTransform(strings.ToLower)},
+}
+
+// Register a transformer under a name to use in transform tags. Register transformers at initialization of the
+// chaincode. The names trim, upper and lower are built in.
+func RegisterTransformer(name string, transformer Transformer) {
+	state.Lock()
+	defer state.Unlock()
+	transformers[name] = transformer
+}
+
+// Make a transformer function of a function on strings
+func stringTransform(fn func(string) string) func(interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.Errorf("Cannot transform %T, only strings", value)
+		}
+		return fn(s), nil
+	}
+}
+
+// Get the transformers of a field in the order of its tag
+func transformersOf(f field) ([]Transformer, error) {
+	tag := f.Tag.Get("transform")
+	if tag == "" {
+		return nil, nil
+	}
+	state.RLock()
+	defer state.RUnlock()
+	var ts []Transformer
+	for _, name := range strings.Split(tag, ",") {
+		t, ok := transformers[strings.TrimSpace(name)]
+		if !ok {
+			return nil, errors.Errorf("Unknown transformer %q of %s", name, f.Name)
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// Run the write transforms of the fields of an item before it is written
+func transformWrites(item BlockchainItemizer) error {
+	v := reflect.ValueOf(item).Elem()
+	for _, f := range fieldsOf(v.Type()) {
+		ts, err := transformersOf(f)
+		if err != nil {
+			return err
+		}
+		for _, t := range ts {
+			if t.Write != nil {
+				if err := transformField(v.FieldByIndex(f.Index), f, t.Write); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Run the read transforms of the fields of a read item that are set
+func transformReads(v reflect.Value, o *options) error {
+	for _, f := range fieldsOf(v.Type()) {
+		if !o.includes(f.Name, f.key) {
+			continue
+		}
+		ts, err := transformersOf(f)
+		if err != nil {
+			return err
+		}
+		for i := len(ts) - 1; i >= 0; i-- {
+			if ts[i].Read != nil {
+				if err := transformField(v.FieldByIndex(f.Index), f, ts[i].Read); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Check whether a field of type t has a read transform, so its stored values differ from those of read items
+func transformsReads(t reflect.Type) bool {
+	for _, f := range fieldsOf(t) {
+		ts, err := transformersOf(f)
+		if err != nil {
+			return true
+		}
+		for _, t := range ts {
+			if t.Read != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Set the result of a transformer function to a field
+func transformField(fv reflect.Value, f field, fn func(interface{}) (interface{}, error)) error {
+	value, err := fn(fv.Interface())
+	if err != nil {
+		return errors.Wrap(err, "Could not transform "+f.Name)
+	}
+	val := reflect.ValueOf(value)
+	if !val.IsValid() || !val.Type().ConvertibleTo(fv.Type()) {
+		return errors.Errorf("Transform of %s returned %T instead of %v", f.Name, value, fv.Type())
+	}
+	fv.Set(val.Convert(fv.Type()))
+	return nil
+}
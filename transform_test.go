@@ -0,0 +1,78 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestTransformed struct {
+	Code  string `transform:"trim,upper"`
+	Email string `transform:"lower,reverse"`
+	Saveable
+}
+
+func reverse(value interface{}) (interface{}, error) {
+	runes := []rune(value.(string))
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes), nil
+}
+
+func TestTransform(t *testing.T) {
+	RegisterTransformer("reverse", Transformer{Write: reverse, Read: reverse})
+	defer delete(transformers, "reverse")
+
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestTransformed)); err != nil {
+		fail(t, err)
+	}
+	item := TestTransformed{Code: " ab-1 ", Email: "Alice@Example.com"}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if item.Code != "AB-1" || item.Email != "moc.elpmaxe@ecila" {
+		fail(t, "The item should hold the written values")
+	}
+	row, err := stub.GetRow("TestTransformed", []shim.Column{{Value: &shim.Column_Int64{Int64: 1}}})
+	if err != nil {
+		fail(t, err)
+	}
+	if row.Columns[1].GetString_() != "moc.elpmaxe@ecila" {
+		fail(t, "The transformed value should be stored")
+	}
+
+	var read TestTransformed
+	if err := Get(stub, &read, 1); err != nil {
+		fail(t, err)
+	}
+	if read.Code != "AB-1" || read.Email != "alice@example.com" {
+		fail(t, "The read transforms should run in reverse order")
+	}
+	read.Code = "cd"
+	if err := Update(stub, &read); err != nil {
+		fail(t, err)
+	}
+	var items []TestTransformed
+	if err := GetAll(stub, &items); err != nil || items[0].Code != "CD" || items[0].Email != "alice@example.com" {
+		fail(t, "Updates should be transformed")
+	}
+	maps, err := FindMaps(stub, new(TestTransformed), nil)
+	if err != nil || len(maps) != 1 || maps[0]["Email"] != "alice@example.com" {
+		fail(t, "FindMaps should run the read transforms")
+	}
+}
+
+func TestTransformShouldFail(t *testing.T) {
+	type unknown struct {
+		Code string `transform:"trim,shout"`
+		Saveable
+	}
+	checkErrorContains(t, transformWrites(&unknown{}), `Unknown transformer "shout"`)
+	type number struct {
+		Count int64 `transform:"upper"`
+		Saveable
+	}
+	checkErrorContains(t, transformWrites(&number{}), "only strings")
+}
@@ -0,0 +1,253 @@
+package orm
+
+import (
+	"encoding"
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"math"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+var (
+	urlType               = reflect.TypeOf(url.URL{})
+	timeType              = reflect.TypeOf(time.Time{})
+	textMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// Get the column type that stores values of type t
+func columnTypeOf(t reflect.Type) (shim.ColumnDefinition_Type, bool) {
+	if isValuer(t) {
+		return valuerColumnType(t), true
+	}
+	if typ, ok := columnDefinitions[t.Name()]; ok && t.PkgPath() == "" {
+		return typ, true
+	}
+	if t == timeType {
+		return shim.ColumnDefinition_INT64, true
+	}
+	if isFloat(t) {
+		return shim.ColumnDefinition_UINT64, true
+	}
+	if isNullable(t) {
+		return shim.ColumnDefinition_BYTES, true
+	}
+	if isText(t) {
+		return shim.ColumnDefinition_STRING, true
+	}
+	if isBinary(t) || isBytes(t) || isJSON(t) {
+		return shim.ColumnDefinition_BYTES, true
+	}
+	// Named types, like enums: type Status int32
+	if typ, ok := columnDefinitions[t.Kind().String()]; ok {
+		return typ, true
+	}
+	return 0, false
+}
+
+// Get the column type that stores a field. Times are stored as unix nanoseconds, or as RFC 3339 text when tagged
+// `time:"rfc3339"`.
+func fieldColumnType(f reflect.StructField) (shim.ColumnDefinition_Type, bool) {
+	if f.Type == timeType && f.Tag.Get("time") == "rfc3339" {
+		return shim.ColumnDefinition_STRING, true
+	}
+	return columnTypeOf(f.Type)
+}
+
+// Check whether t is a float, which is stored as its IEEE 754 bits in a uint64 column, so it is read back exactly
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// Check whether t is a pointer to a type that can be stored, like *string or *int64. The value is stored as its JSON
+// in a bytes column, and nil as no bytes, so nil and the zero value are told apart.
+func isNullable(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() == reflect.Ptr {
+		return false
+	}
+	_, ok := columnTypeOf(t.Elem())
+	return ok
+}
+
+// Get the JSON of the value of a pointer, or no bytes for nil
+func toNullable(val reflect.Value) ([]byte, error) {
+	if val.IsNil() {
+		return nil, nil
+	}
+	return json.Marshal(val.Interface())
+}
+
+// Set a pointer to the value in JSON, or to nil for no bytes
+func fromNullable(f reflect.Value, data []byte) error {
+	if len(data) == 0 {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+	p := reflect.New(f.Type().Elem())
+	if err := json.Unmarshal(data, p.Interface()); err != nil {
+		return err
+	}
+	f.Set(p)
+	return nil
+}
+
+// Get the value of a column of a field as the type of value of the field: floats and times instead of the numbers
+// that store them, and Valuers instead of their columns
+func storedValue(t reflect.Type, c *shim.Column) interface{} {
+	if isValuer(t) {
+		p := reflect.New(t)
+		if err := fromColumn(p.Elem(), c); err == nil {
+			return p.Elem().Interface()
+		}
+		return nil
+	}
+	switch val := c.GetValue().(type) {
+	case *shim.Column_Uint64:
+		if isFloat(t) {
+			return math.Float64frombits(val.Uint64)
+		}
+	case *shim.Column_Int64:
+		if t == timeType {
+			return timeOf(val.Int64)
+		}
+	case *shim.Column_Bytes:
+		if isNullable(t) {
+			p := reflect.New(t)
+			if err := fromNullable(p.Elem(), val.Bytes); err == nil && !p.Elem().IsNil() {
+				return p.Elem().Elem().Interface()
+			}
+			return nil
+		}
+	}
+	return columnValue(c)
+}
+
+// Get the unix nanoseconds that store a time. The zero time, which has no unix nanoseconds, is stored as 0.
+func nanosOf(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// Get the time stored as unix nanoseconds, in UTC
+func timeOf(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
+
+// Check whether values of type t are stored as their JSON: structs that are neither text nor binary, like an Address
+// field. Anonymous structs are flattened into columns instead.
+func isJSON(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !isText(t) && !isBinary(t)
+}
+
+// Check whether t is a byte slice that is stored as it is, like []byte or a named type of it without marshalers
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && !isText(t) && !isBinary(t)
+}
+
+// Check whether values of type t are stored as their text, like url.URL, net.IP and other encoding.TextMarshalers
+func isText(t reflect.Type) bool {
+	if t == urlType {
+		return true
+	}
+	return (t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)) &&
+		reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// Get the text of a value of a text type
+func toText(val reflect.Value) (string, error) {
+	p := reflect.New(val.Type())
+	p.Elem().Set(val)
+	if u, ok := p.Interface().(*url.URL); ok {
+		return u.String(), nil
+	}
+	text, err := p.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not marshal %v", val.Type())
+	}
+	return string(text), nil
+}
+
+// Set a field of a text type from its text
+func fromText(f reflect.Value, text string) error {
+	p := reflect.New(f.Type())
+	if u, ok := p.Interface().(*url.URL); ok {
+		parsed, err := url.Parse(text)
+		if err != nil {
+			return errors.Wrap(err, "Could not parse URL")
+		}
+		*u = *parsed
+	} else if err := p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+		return errors.Wrapf(err, "Could not unmarshal %v", f.Type())
+	}
+	f.Set(p.Elem())
+	return nil
+}
+
+// Check whether values of type t are stored as their bytes: encoding.BinaryMarshalers that are not stored as text
+func isBinary(t reflect.Type) bool {
+	return !isText(t) && (t.Implements(binaryMarshalerType) || reflect.PtrTo(t).Implements(binaryMarshalerType)) &&
+		reflect.PtrTo(t).Implements(binaryUnmarshalerType)
+}
+
+// Get the bytes of a value of a binary type
+func toBinary(val reflect.Value) ([]byte, error) {
+	p := reflect.New(val.Type())
+	p.Elem().Set(val)
+	data, err := p.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not marshal %v", val.Type())
+	}
+	return data, nil
+}
+
+// Set a field of a binary type from its bytes
+func fromBinary(f reflect.Value, data []byte) error {
+	p := reflect.New(f.Type())
+	if err := p.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+		return errors.Wrapf(err, "Could not unmarshal %v", f.Type())
+	}
+	f.Set(p.Elem())
+	return nil
+}
+
+// Check whether a column of type c can be set to a field of type t
+func decodable(c shim.ColumnDefinition_Type, t reflect.Type) bool {
+	if isValuer(t) {
+		return c == valuerColumnType(t)
+	}
+	switch c {
+	case shim.ColumnDefinition_BOOL:
+		return t.Kind() == reflect.Bool
+	case shim.ColumnDefinition_BYTES:
+		return isBinary(t) || isJSON(t) || isNullable(t) || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+	case shim.ColumnDefinition_INT32, shim.ColumnDefinition_INT64:
+		if c == shim.ColumnDefinition_INT64 && t == timeType {
+			return true
+		}
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return true
+		}
+	case shim.ColumnDefinition_UINT32, shim.ColumnDefinition_UINT64:
+		if c == shim.ColumnDefinition_UINT64 && isFloat(t) {
+			return true
+		}
+		switch t.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		}
+	case shim.ColumnDefinition_STRING:
+		return isText(t) || t.Kind() == reflect.String
+	}
+	return false
+}
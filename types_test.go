@@ -0,0 +1,332 @@
+package orm
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Stored through its text
+type TestCurrency struct {
+	Code string
+}
+
+func (c TestCurrency) MarshalText() ([]byte, error) { return []byte(strings.ToLower(c.Code)), nil }
+func (c *TestCurrency) UnmarshalText(text []byte) error {
+	c.Code = strings.ToUpper(string(text))
+	return nil
+}
+
+type TestTexts struct {
+	Homepage url.URL
+	Address  net.IP
+	Currency TestCurrency
+	Saveable
+}
+
+func TestTextTypes(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestTexts)); err != nil {
+		fail(t, err)
+	}
+	if typ, _ := ColumnType(stub, new(TestTexts), "Address"); typ != shim.ColumnDefinition_STRING {
+		fail(t, "IP addresses should be stored as strings")
+	}
+
+	homepage, _ := url.Parse("https://example.com/a?b=c")
+	item := TestTexts{Homepage: *homepage, Address: net.ParseIP("10.0.0.1"), Currency: TestCurrency{"EUR"}}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestTexts{}); err != nil {
+		fail(t, err)
+	}
+
+	var stored TestTexts
+	if err := Get(stub, &stored, 1); err != nil {
+		fail(t, err)
+	}
+	if stored.Homepage.String() != homepage.String() || !stored.Address.Equal(item.Address) || stored.Currency.Code != "EUR" {
+		fail(t, "Values should be restored from their text")
+	}
+	row, _ := stub.GetRow("TestTexts", []shim.Column{{Value: &shim.Column_Int64{Int64: 1}}})
+	if row.Columns[2].GetString_() != "eur" {
+		fail(t, "TextMarshalers should be stored as their text")
+	}
+
+	var empty TestTexts
+	if err := Get(stub, &empty, 2); err != nil {
+		fail(t, err)
+	}
+	if empty.Address != nil || empty.Homepage.String() != "" {
+		fail(t, "Zero values should stay zero")
+	}
+}
+
+// Stored as its bytes
+type TestPoint struct {
+	X, Y byte
+}
+
+func (p TestPoint) MarshalBinary() ([]byte, error) { return []byte{p.X, p.Y}, nil }
+func (p *TestPoint) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) != 2 {
+		return errors.New("A point has 2 bytes")
+	}
+	p.X, p.Y = data[0], data[1]
+	return nil
+}
+
+type TestBinary struct {
+	Location TestPoint
+	Saveable
+}
+
+func TestBinaryTypes(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestBinary)); err != nil {
+		fail(t, err)
+	}
+	if typ, _ := ColumnType(stub, new(TestBinary), "Location"); typ != shim.ColumnDefinition_BYTES {
+		fail(t, "BinaryMarshalers should be stored as bytes")
+	}
+
+	item := TestBinary{Location: TestPoint{3, 4}}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	var stored TestBinary
+	if err := Get(stub, &stored, 1); err != nil {
+		fail(t, err)
+	}
+	if stored != item {
+		fail(t, "Values should be restored from their bytes")
+	}
+}
+
+type TestBytes struct {
+	Hash    []byte
+	Payload json.RawMessage
+	Empty   []byte
+	Saveable
+}
+
+func checkBytes(t *testing.T, stub shim.ChaincodeStubInterface) {
+	if err := CreateTable(stub, new(TestBytes)); err != nil {
+		fail(t, err)
+	}
+	if typ, _ := ColumnType(stub, new(TestBytes), "Payload"); typ != shim.ColumnDefinition_BYTES {
+		fail(t, "Byte slices should be stored as bytes")
+	}
+	item := TestBytes{Hash: []byte{0, 1, 255}, Payload: json.RawMessage(`{"a":1}`), Empty: []byte{}}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	var stored TestBytes
+	if err := Get(stub, &stored, 1); err != nil {
+		fail(t, err)
+	}
+	if !bytes.Equal(stored.Hash, item.Hash) || string(stored.Payload) != `{"a":1}` || len(stored.Empty) != 0 {
+		fail(t, "Byte slices should be restored")
+	}
+
+	stored.Hash[0] = 9
+	if err := Update(stub, &stored); err != nil {
+		fail(t, err)
+	}
+	var items []TestBytes
+	if err := GetAll(stub, &items); err != nil || len(items) != 1 || items[0].Hash[0] != 9 {
+		fail(t, "Byte slices should be updated")
+	}
+	// The read value is a copy
+	items[0].Hash[0] = 7
+	if err := Get(stub, &stored, 1); err != nil || stored.Hash[0] != 9 {
+		fail(t, "Changing a read value should not change the stored value")
+	}
+}
+
+func TestBytesTypes(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkBytes(t, stub)
+}
+
+type TestAddress struct {
+	Street string
+	City   string
+}
+
+type TestAddressed struct {
+	Name    string
+	Address TestAddress
+	Saveable
+}
+
+func TestJSONTypes(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestAddressed)); err != nil {
+		fail(t, err)
+	}
+	if typ, _ := ColumnType(stub, new(TestAddressed), "Address"); typ != shim.ColumnDefinition_BYTES {
+		fail(t, "Struct fields should be stored as bytes")
+	}
+	item := TestAddressed{Name: "home", Address: TestAddress{Street: "Main 1", City: "Utrecht"}}
+	if err := Create(stub, &item); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestAddressed{Name: "empty"}); err != nil {
+		fail(t, err)
+	}
+	row, _ := stub.GetRow("TestAddressed", []shim.Column{{Value: &shim.Column_Int64{Int64: 1}}})
+	if string(row.Columns[1].GetBytes()) != `{"Street":"Main 1","City":"Utrecht"}` {
+		fail(t, "Struct fields should be stored as their JSON")
+	}
+
+	var read TestAddressed
+	if err := Get(stub, &read, 1); err != nil || read.Address != item.Address {
+		fail(t, "Struct fields should be read from their JSON")
+	}
+	var empty TestAddressed
+	if err := Get(stub, &empty, 2); err != nil || empty.Address != (TestAddress{}) {
+		fail(t, "Zero structs should stay zero")
+	}
+}
+
+type TestTimed struct {
+	Created time.Time
+	Updated time.Time `time:"rfc3339"`
+	Deleted time.Time
+	Saveable
+}
+
+func TestTimes(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestTimed)); err != nil {
+		fail(t, err)
+	}
+	if typ, _ := ColumnType(stub, new(TestTimed), "Created"); typ != shim.ColumnDefinition_INT64 {
+		fail(t, "Times should be stored as unix nanoseconds")
+	}
+	if typ, _ := ColumnType(stub, new(TestTimed), "Updated"); typ != shim.ColumnDefinition_STRING {
+		fail(t, "Tagged times should be stored as text")
+	}
+
+	created := time.Date(2017, 3, 1, 12, 30, 0, 123456789, time.FixedZone("CET", 3600))
+	if err := Create(stub, &TestTimed{Created: created, Updated: created.Add(time.Hour)}); err != nil {
+		fail(t, err)
+	}
+	var item TestTimed
+	if err := Get(stub, &item, 1); err != nil {
+		fail(t, err)
+	}
+	if !item.Created.Equal(created) || !item.Updated.Equal(created.Add(time.Hour)) {
+		fail(t, "Times should be read back to the nanosecond")
+	}
+	if !item.Deleted.IsZero() {
+		fail(t, "The zero time should be read back")
+	}
+}
+
+type TestMeasured struct {
+	Amount float64
+	Rate   float32
+	Saveable
+}
+
+func TestFloats(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestMeasured)); err != nil {
+		fail(t, err)
+	}
+	if typ, _ := ColumnType(stub, new(TestMeasured), "Amount"); typ != shim.ColumnDefinition_UINT64 {
+		fail(t, "Floats should be stored as their bits")
+	}
+	for _, amount := range []float64{0.1, -2.5} {
+		if err := Create(stub, &TestMeasured{Amount: amount, Rate: 0.3}); err != nil {
+			fail(t, err)
+		}
+	}
+
+	var item TestMeasured
+	if err := Get(stub, &item, 1); err != nil || item.Amount != 0.1 || item.Rate != float32(0.3) {
+		fail(t, "Floats should be read back exactly")
+	}
+	var items []TestMeasured
+	if err := Find(stub, &items, Where("Amount", "<", 0)); err != nil || len(items) != 1 || items[0].Amount != -2.5 {
+		fail(t, "Floats should be compared by value")
+	}
+	maps, err := FindMaps(stub, new(TestMeasured), Where("Amount", ">", 0))
+	if err != nil || len(maps) != 1 || maps[0]["Amount"] != 0.1 {
+		fail(t, "Maps should hold the values of floats")
+	}
+}
+
+type TestOptional struct {
+	Note   *string
+	Amount *int64
+	Saveable
+}
+
+func TestNullable(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestOptional)); err != nil {
+		fail(t, err)
+	}
+	note, zero := "note", int64(0)
+	if err := Create(stub, &TestOptional{Note: &note, Amount: &zero}); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestOptional{}); err != nil {
+		fail(t, err)
+	}
+
+	var item TestOptional
+	if err := Get(stub, &item, 1); err != nil || item.Note == nil || *item.Note != "note" || item.Amount == nil || *item.Amount != 0 {
+		fail(t, "Pointers should be read back, also to zero values")
+	}
+	item = TestOptional{Note: &note}
+	if err := Get(stub, &item, 2); err != nil || item.Note != nil || item.Amount != nil {
+		fail(t, "Nil pointers should be read back as nil")
+	}
+
+	var items []TestOptional
+	if err := Find(stub, &items, Where("Amount", "=", 0)); err != nil || len(items) != 1 || items[0].Id != 1 {
+		fail(t, "Pointers should be compared by their values")
+	}
+	items = nil
+	if err := Find(stub, &items, Where("Note", "=", nil)); err != nil || len(items) != 1 || items[0].Id != 2 {
+		fail(t, "Nil pointers should be found by nil")
+	}
+	items = nil
+	if err := Find(stub, &items, Where("Note", "!=", nil)); err != nil || len(items) != 1 || items[0].Id != 1 {
+		fail(t, "Pointers that aren't nil should be found by nil")
+	}
+	items = nil
+	if err := Find(stub, &items, new(Query).OrderBy("Note", false)); err != nil || len(items) != 2 || items[0].Note != nil {
+		fail(t, "Nil pointers should sort first")
+	}
+	maps, err := FindMaps(stub, new(TestOptional), nil)
+	if err != nil || len(maps) != 2 || maps[0]["Amount"] != int64(0) || maps[1]["Amount"] != nil {
+		fail(t, "Maps should hold the values of pointers")
+	}
+	maps, err = FindMaps(stub, new(TestOptional), Where("Note", "=", nil))
+	if err != nil || len(maps) != 1 || maps[0]["id"] != int64(2) {
+		fail(t, "Maps of nil pointers should be found by nil")
+	}
+	checkErrorContains(t, Find(stub, &items, Where("Id", "=", nil)), "Cannot compare Id")
+}
@@ -0,0 +1,133 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// A uniqueness constraint across tables, declared with a tag naming its group:
+//
+// type Company struct {
+//   RegistrationNumber string `unique:"registration"`
+//   ...
+// }
+//
+// No two items in any table with a field in the group can have the same value. The values are kept in the table
+// orm_unique_<group>. Zero values are not constrained. {table} in a group is replaced with the name of the type, to
+// make a field of a mixin unique within each table that embeds it.
+type uniqueField struct {
+	group string
+	table string
+	field reflect.StructField
+}
+
+// Get the unique fields of type t
+func uniquesOf(t reflect.Type, o *options) []uniqueField {
+	var uniques []uniqueField
+	for _, f := range fieldsOf(t) {
+		if group := strings.Replace(f.Tag.Get("unique"), "{table}", t.Name(), -1); group != "" {
+			uniques = append(uniques, uniqueField{group: group, table: o.conf().Namespace + "orm_unique_" + group, field: f.StructField})
+		}
+	}
+	return uniques
+}
+
+// Create the constraint tables of type t that don't exist yet
+func createUniqueTables(stub shim.ChaincodeStubInterface, t reflect.Type, o *options) error {
+	for _, u := range uniquesOf(t, o) {
+		if _, err := stub.GetTable(u.table); err == nil {
+			continue
+		}
+		logger.Infof("Create Constraint %s", u.table)
+		err := stub.CreateTable(u.table, []*shim.ColumnDefinition{
+			{Name: "Value", Type: shim.ColumnDefinition_STRING, Key: true},
+			{Name: "Table", Type: shim.ColumnDefinition_STRING},
+			{Name: "Id", Type: shim.ColumnDefinition_INT64},
+		})
+		if err != nil {
+			return errors.Wrap(err, "Could not create constraint "+u.group)
+		}
+	}
+	return nil
+}
+
+// Get the constrained value of an item, or "" for a zero value
+func (u uniqueField) value(item BlockchainItemizer) string {
+	if item == nil {
+		return ""
+	}
+	f := reflect.ValueOf(item).Elem().FieldByIndex(u.field.Index)
+	if reflect.DeepEqual(f.Interface(), reflect.Zero(f.Type()).Interface()) {
+		return ""
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// Replace the constrained values of the old version of an item by those of the new version. Either can be nil.
+// Returns an error without writing when a new value is taken, here or in a unique index.
+func updateUniques(stub shim.ChaincodeStubInterface, old, new BlockchainItemizer, o *options) error {
+	if new != nil {
+		if err := checkUniqueIndexes(stub, new, o); err != nil {
+			return err
+		}
+	}
+	item := new
+	if item == nil {
+		item = old
+	}
+	t := reflect.TypeOf(item).Elem()
+	uniques := uniquesOf(t, o)
+
+	for _, u := range uniques {
+		value := u.value(new)
+		if value == "" || value == u.value(old) {
+			continue
+		}
+		row, err := stub.GetRow(u.table, []shim.Column{{Value: &shim.Column_String_{String_: value}}})
+		if err != nil {
+			return errors.Wrap(err, "Could not check constraint "+u.group)
+		}
+		if len(row.Columns) > 0 {
+			return errors.Errorf("%s %v is already used by %s %d", u.field.Name, value, row.Columns[1].GetString_(), row.Columns[2].GetInt64())
+		}
+	}
+
+	for _, u := range uniques {
+		oldValue, newValue := u.value(old), u.value(new)
+		if oldValue == newValue {
+			continue
+		}
+		if oldValue != "" {
+			if err := stub.DeleteRow(u.table, []shim.Column{{Value: &shim.Column_String_{String_: oldValue}}}); err != nil {
+				return errors.Wrap(err, "Could not update constraint "+u.group)
+			}
+		}
+		if newValue != "" {
+			row := shim.Row{Columns: []*shim.Column{
+				{Value: &shim.Column_String_{String_: newValue}},
+				{Value: &shim.Column_String_{String_: tableName(t, o)}},
+				{Value: &shim.Column_Int64{Int64: item.GetId()}},
+			}}
+			if _, err := stub.InsertRow(u.table, row); err != nil {
+				return errors.Wrap(err, "Could not update constraint "+u.group)
+			}
+		}
+	}
+	return nil
+}
+
+// Remove the constrained values of the old version of an item that the new version changes, so another item can take
+// them before the new version is written
+func releaseUniques(stub shim.ChaincodeStubInterface, old, new BlockchainItemizer, o *options) error {
+	for _, u := range uniquesOf(reflect.TypeOf(old).Elem(), o) {
+		if value := u.value(old); value != "" && value != u.value(new) {
+			if err := stub.DeleteRow(u.table, []shim.Column{{Value: &shim.Column_String_{String_: value}}}); err != nil {
+				return errors.Wrap(err, "Could not release constraint "+u.group)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"testing"
+)
+
+type TestCompany struct {
+	Registration string `unique:"registration"`
+	Saveable
+}
+
+type TestPerson struct {
+	Registration string `unique:"registration"`
+	Name         string
+	Saveable
+}
+
+func TestUnique(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, new(TestCompany)); err != nil {
+		fail(t, err)
+	}
+	if err := CreateTable(stub, new(TestPerson)); err != nil {
+		fail(t, err)
+	}
+
+	company := TestCompany{Registration: "R1"}
+	if err := Create(stub, &company); err != nil {
+		fail(t, err)
+	}
+	checkErrorContains(t, Create(stub, &TestPerson{Registration: "R1"}), "TestCompany 1")
+	if err := Create(stub, &TestPerson{}); err != nil {
+		fail(t, "Zero values should not be constrained")
+	}
+	if err := Create(stub, &TestPerson{}); err != nil {
+		fail(t, "Zero values should not be constrained")
+	}
+
+	// Updating and deleting release the value
+	company.Registration = "R2"
+	if err := Update(stub, &company); err != nil {
+		fail(t, err)
+	}
+	person := TestPerson{Registration: "R1"}
+	if err := Create(stub, &person); err != nil {
+		fail(t, err)
+	}
+	checkErrorContains(t, Create(stub, &TestPerson{Registration: "R2"}), "already used")
+	if err := Delete(stub, &company); err != nil {
+		fail(t, err)
+	}
+	if err := Create(stub, &TestPerson{Registration: "R2"}); err != nil {
+		fail(t, err)
+	}
+	person.Name = "unchanged registration"
+	if err := Update(stub, &person); err != nil {
+		fail(t, err)
+	}
+}
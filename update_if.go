@@ -0,0 +1,49 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Returned by UpdateIf when the stored item doesn't match the precondition
+var ErrPreconditionFailed = errors.New("Precondition failed.")
+
+// A precondition that a field has a value, for UpdateIf. Add more conditions with And.
+func FieldEquals(field string, value interface{}) *Query {
+	return Where(field, "=", value)
+}
+
+// Update an item only if its stored version matches the conditions and filters of a query, e.g. to close an order
+// only while it is open:
+//
+// err := orm.UpdateIf(stub, &order, orm.FieldEquals("Status", "open"))
+//
+// Returns ErrPreconditionFailed when the stored item doesn't match, and ErrNotFound when there is none.
+func UpdateIf(stub shim.ChaincodeStubInterface, item BlockchainItemizer, precondition *Query, opts ...Option) error {
+	if err := checkStub(stub, true); err != nil {
+		return err
+	}
+	if err := checkItem(item); err != nil {
+		return err
+	}
+	t := reflect.TypeOf(item).Elem()
+	if err := precondition.validate(t); err != nil {
+		return err
+	}
+	stored, err := getStoredItem(stub, item, newOptions(stub, opts))
+	if err != nil {
+		return err
+	} else if stored == nil {
+		return ErrNotFound
+	}
+	if err := authorize(stub, "update", stored); err != nil {
+		return err
+	}
+	if ok, err := precondition.matches(stored); err != nil {
+		return err
+	} else if !ok {
+		return errors.Wrapf(ErrPreconditionFailed, "%s with id %d", t.Name(), stored.GetId())
+	}
+	return Update(stub, item, opts...)
+}
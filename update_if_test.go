@@ -0,0 +1,37 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestUpdateIf(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	checkCreateTable(t, stub)
+	checkCreateItems(t, stub, "open")
+
+	item := checkGet(t, stub)
+	item.Str = "closed"
+	if err := UpdateIf(stub, &item, FieldEquals("Str", "open").And("I64", "=", 1)); err != nil {
+		fail(t, err)
+	}
+	if checkGet(t, stub).Str != "closed" {
+		fail(t, "The item should be updated when the precondition holds")
+	}
+
+	item.Str = "reopened"
+	if err := UpdateIf(stub, &item, FieldEquals("Str", "open")); errors.Cause(err) != ErrPreconditionFailed {
+		fail(t, "The update should fail when the stored item doesn't match")
+	}
+	if checkGet(t, stub).Str != "closed" {
+		fail(t, "The item should not be updated when the precondition fails")
+	}
+	missing := getTestStruct()
+	missing.Id = 9
+	if err := UpdateIf(stub, &missing, FieldEquals("Str", "open")); err != ErrNotFound {
+		fail(t, "A missing item should not be found")
+	}
+	checkErrorContains(t, UpdateIf(stub, &item, FieldEquals("Nope", 1)), "Nope")
+}
@@ -0,0 +1,117 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// A field type that converts itself to a column, to store types the ORM doesn't know, like big integers, decimals
+// or UUIDs. It needs a Scanner too, to be read back:
+//
+// type Amount struct{ big.Int }
+//
+// func (a Amount) ColumnType() shim.ColumnDefinition_Type { return shim.ColumnDefinition_STRING }
+// func (a Amount) ColumnValue() (shim.Column, error) {
+// 	return shim.Column{Value: &shim.Column_String_{String_: a.String()}}, nil
+// }
+// func (a *Amount) ScanColumn(c *shim.Column) error {
+// 	if _, ok := a.SetString(c.GetString_(), 10); !ok {
+// 		return errors.New("Invalid amount " + c.GetString_())
+// 	}
+// 	return nil
+// }
+//
+// Valuers take precedence over the other ways of storing a type, like its text or JSON. Queries compare them by their
+// column values.
+type Valuer interface {
+	// The type of the column, which is the same for all values
+	ColumnType() shim.ColumnDefinition_Type
+	ColumnValue() (shim.Column, error)
+}
+
+// Sets a field of a Valuer type from its column
+type Scanner interface {
+	ScanColumn(c *shim.Column) error
+}
+
+var (
+	valuerType  = reflect.TypeOf((*Valuer)(nil)).Elem()
+	scannerType = reflect.TypeOf((*Scanner)(nil)).Elem()
+)
+
+// Check whether values of type t convert themselves to columns
+func isValuer(t reflect.Type) bool {
+	return (t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType)) && reflect.PtrTo(t).Implements(scannerType)
+}
+
+// Get the column type of a Valuer type
+func valuerColumnType(t reflect.Type) shim.ColumnDefinition_Type {
+	return reflect.New(t).Interface().(Valuer).ColumnType()
+}
+
+// Get the column of a value of a Valuer type
+func toColumn(val reflect.Value) (shim.Column, error) {
+	p := reflect.New(val.Type())
+	p.Elem().Set(val)
+	c, err := p.Interface().(Valuer).ColumnValue()
+	if err != nil {
+		return shim.Column{}, errors.Wrapf(err, "Could not convert %v to a column", val.Type())
+	}
+	if typ, ok := typeOfColumn(&c); !ok || typ != valuerColumnType(val.Type()) {
+		return shim.Column{}, errors.Errorf("Column value of %v doesn't match its column type %s", val.Type(), valuerColumnType(val.Type()))
+	}
+	return c, nil
+}
+
+// Set a field of a Valuer type from its column
+func fromColumn(f reflect.Value, c *shim.Column) error {
+	p := reflect.New(f.Type())
+	if err := p.Interface().(Scanner).ScanColumn(c); err != nil {
+		return errors.Wrapf(err, "Could not scan %v", f.Type())
+	}
+	f.Set(p.Elem())
+	return nil
+}
+
+// Get the type of the value of a column
+func typeOfColumn(c *shim.Column) (shim.ColumnDefinition_Type, bool) {
+	switch c.GetValue().(type) {
+	case *shim.Column_Bool:
+		return shim.ColumnDefinition_BOOL, true
+	case *shim.Column_Bytes:
+		return shim.ColumnDefinition_BYTES, true
+	case *shim.Column_Int32:
+		return shim.ColumnDefinition_INT32, true
+	case *shim.Column_Int64:
+		return shim.ColumnDefinition_INT64, true
+	case *shim.Column_String_:
+		return shim.ColumnDefinition_STRING, true
+	case *shim.Column_Uint32:
+		return shim.ColumnDefinition_UINT32, true
+	case *shim.Column_Uint64:
+		return shim.ColumnDefinition_UINT64, true
+	}
+	return 0, false
+}
+
+// Get the column of a value read by columnValue, to scan it into a Valuer
+func columnOf(value interface{}) (shim.Column, bool) {
+	switch v := value.(type) {
+	case bool:
+		return shim.Column{Value: &shim.Column_Bool{Bool: v}}, true
+	case []byte:
+		return shim.Column{Value: &shim.Column_Bytes{Bytes: v}}, true
+	case int32:
+		return shim.Column{Value: &shim.Column_Int32{Int32: v}}, true
+	case int64:
+		return shim.Column{Value: &shim.Column_Int64{Int64: v}}, true
+	case string:
+		return shim.Column{Value: &shim.Column_String_{String_: v}}, true
+	case uint32:
+		return shim.Column{Value: &shim.Column_Uint32{Uint32: v}}, true
+	case uint64:
+		return shim.Column{Value: &shim.Column_Uint64{Uint64: v}}, true
+	}
+	return shim.Column{}, false
+}
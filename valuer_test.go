@@ -0,0 +1,100 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"math/big"
+	"testing"
+)
+
+// A big integer stored as its decimal text
+type TestAmount struct{ big.Int }
+
+func (a TestAmount) ColumnType() shim.ColumnDefinition_Type { return shim.ColumnDefinition_STRING }
+
+func (a TestAmount) ColumnValue() (shim.Column, error) {
+	return shim.Column{Value: &shim.Column_String_{String_: a.String()}}, nil
+}
+
+func (a *TestAmount) ScanColumn(c *shim.Column) error {
+	if _, ok := a.SetString(c.GetString_(), 10); !ok {
+		return errors.New("Invalid amount " + c.GetString_())
+	}
+	return nil
+}
+
+func testAmount(s string) TestAmount {
+	var a TestAmount
+	a.SetString(s, 10)
+	return a
+}
+
+// A value whose column doesn't match its column type
+type TestMismatched int
+
+func (m TestMismatched) ColumnType() shim.ColumnDefinition_Type { return shim.ColumnDefinition_BYTES }
+
+func (m TestMismatched) ColumnValue() (shim.Column, error) {
+	return shim.Column{Value: &shim.Column_Int64{Int64: int64(m)}}, nil
+}
+
+func (m *TestMismatched) ScanColumn(c *shim.Column) error { return nil }
+
+type TestBalance struct {
+	Amount TestAmount `index:"amount"`
+	Saveable
+}
+
+type TestMismatchedValue struct {
+	Value TestMismatched
+	Saveable
+}
+
+func TestValuer(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestBalance{}); err != nil {
+		fail(t, err)
+	}
+	tbl, _ := stub.GetTable("TestBalance")
+	if tbl.ColumnDefinitions[0].Type != shim.ColumnDefinition_STRING {
+		fail(t, "The column should have the type of the Valuer")
+	}
+
+	large := "123456789012345678901234567890"
+	for _, amount := range []string{large, "5"} {
+		if err := Create(stub, &TestBalance{Amount: testAmount(amount)}); err != nil {
+			fail(t, err)
+		}
+	}
+	var b TestBalance
+	if err := Get(stub, &b, 1); err != nil {
+		fail(t, err)
+	}
+	if b.Amount.String() != large {
+		fail(t, "The value should be scanned from its column")
+	}
+
+	// Queries compare the column values, and can use an index
+	var balances []TestBalance
+	if err := Find(stub, &balances, Where("Amount", "=", testAmount("5"))); err != nil || len(balances) != 1 || balances[0].Id != 2 {
+		fail(t, "Valuers should be found by their value")
+	}
+	if n, err := CountBy(stub, &TestBalance{}, "Amount", large); err != nil || n != 1 {
+		fail(t, "Valuers should be found by their column value")
+	}
+
+	maps, err := FindMaps(stub, &TestBalance{}, nil)
+	if err != nil {
+		fail(t, err)
+	}
+	if a, ok := maps[0]["Amount"].(TestAmount); !ok || a.String() != large {
+		fail(t, "Maps should have the value of the Valuer")
+	}
+
+	if err := CreateTable(stub, &TestMismatchedValue{}); err != nil {
+		fail(t, err)
+	}
+	err = Create(stub, &TestMismatchedValue{Value: 1})
+	checkErrorContains(t, err, "doesn't match its column type")
+}
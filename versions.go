@@ -0,0 +1,194 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+	"math"
+	"reflect"
+)
+
+// Entities that implement this interface get a hidden SchemaVersion column, which is stamped with the current
+// version on every write. Rows with an older version are passed through the registered version adapters when they
+// are read. Implement it before creating the table: the column can't be added to an existing table.
+type SchemaVersioner interface {
+	SchemaVersion() uint32
+}
+
+const schemaVersionColumn = "SchemaVersion"
+
+// Converts the column values of a row with version n into the values of version n+1. Values are keyed by column
+// name. Add, remove, rename and convert values as needed; the result is set to the fields with the same name.
+type VersionAdapter func(values map[string]interface{}) (map[string]interface{}, error)
+
+var versionAdapters = map[string]map[uint32]VersionAdapter{}
+
+// Register the adapter that upgrades rows of a table from version to version+1. The table is the name of the type,
+// without Namespace. Versions without an adapter are assumed to be compatible with the next version. Register
+// adapters at initialization of the chaincode.
+func RegisterVersionAdapter(table string, version uint32, adapter VersionAdapter) {
+	state.Lock()
+	defer state.Unlock()
+	if versionAdapters[table] == nil {
+		versionAdapters[table] = map[uint32]VersionAdapter{}
+	}
+	versionAdapters[table][version] = adapter
+}
+
+// Decodes a row that was written with an old schema version into an item of the current version. The columns of the
+// row are in the order of the table definition, including the SchemaVersion column.
+type LegacyDecoder func(row shim.Row) (BlockchainItemizer, error)
+
+var legacyDecoders = map[string]map[uint32]LegacyDecoder{}
+
+// Register a decoder for rows of a table that were written with the given version. It takes precedence over version
+// adapters. Since writes always stamp the current version, decoded items are upgraded when they are saved again.
+func RegisterLegacyDecoder(table string, version uint32, decoder LegacyDecoder) {
+	state.Lock()
+	defer state.Unlock()
+	if legacyDecoders[table] == nil {
+		legacyDecoders[table] = map[uint32]LegacyDecoder{}
+	}
+	legacyDecoders[table][version] = decoder
+}
+
+// Get the schema version a row was written with. Returns false if the table has no version column or the row is empty.
+func storedSchemaVersion(tbl *shim.Table, row shim.Row) (uint32, bool) {
+	for i, cd := range tbl.ColumnDefinitions {
+		if cd.Name == schemaVersionColumn && i < len(row.Columns) {
+			return row.Columns[i].GetUint32(), true
+		}
+	}
+	return 0, false
+}
+
+// Set the values of a row that was written with an older schema version to item v, using the registered legacy
+// decoder or upgrading the values with the registered adapters first.
+func setAdaptedValues(tbl *shim.Table, row shim.Row, v reflect.Value, stored, current uint32, o *options) error {
+	if stored > current {
+		return fmt.Errorf("Row of %s has schema version %d, which is newer than %d", tbl.Name, stored, current)
+	}
+
+	state.RLock()
+	decoder, ok := legacyDecoders[v.Type().Name()][stored]
+	state.RUnlock()
+	if ok {
+		logger.Debugf("Decoding %s with the decoder for version %d", tbl.Name, stored)
+		item, err := decoder(row)
+		if err != nil {
+			return errors.Wrapf(err, "Could not decode %s with version %d", tbl.Name, stored)
+		}
+		val := reflect.Indirect(reflect.ValueOf(item))
+		if !val.IsValid() || val.Type() != v.Type() {
+			return errors.New("Legacy decoder should return an item of type " + v.Type().Name())
+		}
+		v.Set(val)
+		return nil
+	}
+
+	values := map[string]interface{}{}
+	for i, c := range row.GetColumns() {
+		if name := tbl.ColumnDefinitions[i].Name; name != schemaVersionColumn && name != checksumColumn {
+			values[name] = columnValue(c)
+		}
+	}
+
+	for version := stored; version < current; version++ {
+		state.RLock()
+		adapter, ok := versionAdapters[v.Type().Name()][version]
+		state.RUnlock()
+		if ok {
+			logger.Debugf("Upgrading %s from version %d", tbl.Name, version)
+			var err error
+			if values, err = adapter(values); err != nil {
+				return errors.Wrapf(err, "Could not upgrade %s from version %d", tbl.Name, version)
+			}
+		}
+	}
+
+	fields := fieldsByColumn(v.Type())
+	for name, value := range values {
+		field, ok := fields[name]
+		if !ok {
+			if err := unknownColumn(v, name, value, o); err != nil {
+				return err
+			}
+			continue
+		}
+		if !o.includes(field.Name, field.key) {
+			continue
+		}
+		if err := setValue(v.FieldByIndex(field.Index), value); err != nil {
+			return errors.Wrap(err, "Could not set "+name)
+		}
+	}
+	return nil
+}
+
+// Set an arbitrary value to a field, converting it to the type of the field if needed
+func setValue(f reflect.Value, value interface{}) error {
+	if value == nil {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+	if c, ok := columnOf(value); ok && isValuer(f.Type()) {
+		return fromColumn(f, &c)
+	}
+	if isNullable(f.Type()) {
+		if data, ok := value.([]byte); ok {
+			return fromNullable(f, data)
+		}
+		p := reflect.New(f.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+	if v, ok := value.(uint64); ok && isFloat(f.Type()) {
+		f.SetFloat(math.Float64frombits(v))
+		return nil
+	}
+	if f.Type() == timeType {
+		switch v := value.(type) {
+		case int64:
+			f.Set(reflect.ValueOf(timeOf(v)))
+			return nil
+		case string:
+			return fromText(f, v)
+		}
+	}
+	val := reflect.ValueOf(value)
+	if val.Type() == f.Type() {
+		f.Set(val)
+		return nil
+	}
+	// Decode the columns of text, binary and JSON fields like setValues does
+	switch v := value.(type) {
+	case string:
+		if isText(f.Type()) {
+			return fromText(f, v)
+		}
+	case []byte:
+		if isBinary(f.Type()) {
+			return fromBinary(f, v)
+		}
+		if isJSON(f.Type()) {
+			p := reflect.New(f.Type())
+			if len(v) > 0 {
+				if err := json.Unmarshal(v, p.Interface()); err != nil {
+					return err
+				}
+			}
+			f.Set(p.Elem())
+			return nil
+		}
+	}
+	// Converting numbers to strings is allowed by reflect, but yields a rune
+	if !val.Type().ConvertibleTo(f.Type()) || (f.Kind() == reflect.String && val.Kind() != reflect.String) {
+		return fmt.Errorf("Cannot convert %v to %v", val.Type(), f.Type())
+	}
+	f.Set(val.Convert(f.Type()))
+	return nil
+}
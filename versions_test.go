@@ -0,0 +1,176 @@
+package orm
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Version 0 stored lowercase names, version 1 stored whole amounts instead of cents
+type TestVersioned struct {
+	Name   string
+	Amount int64
+	Saveable
+}
+
+func (s *TestVersioned) SchemaVersion() uint32 { return 2 }
+
+func checkInsertVersionedRow(t *testing.T, stub shim.ChaincodeStubInterface, id int64, version uint32) {
+	row := shim.Row{Columns: []*shim.Column{
+		{Value: &shim.Column_String_{String_: "name"}},
+		{Value: &shim.Column_Int64{Int64: 3}},
+		{Value: &shim.Column_Int64{Int64: id}},
+		{Value: &shim.Column_Uint32{Uint32: version}},
+	}}
+	if _, err := stub.InsertRow("TestVersioned", row); err != nil {
+		fail(t, err)
+	}
+}
+
+func TestSchemaVersionIsStamped(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestVersioned{}); err != nil {
+		fail(t, err)
+	}
+	s := TestVersioned{Name: "name", Amount: 10}
+	if err := Create(stub, &s); err != nil {
+		fail(t, err)
+	}
+	row, err := stub.GetRow("TestVersioned", []shim.Column{{Value: &shim.Column_Int64{Int64: s.Id}}})
+	if err != nil {
+		fail(t, err)
+	}
+	if len(row.Columns) != 4 || row.Columns[3].GetUint32() != 2 {
+		fail(t, "Schema version should be stored")
+	}
+	var a TestVersioned
+	if err := Get(stub, &a, s.Id); err != nil {
+		fail(t, err)
+	}
+	if a.Name != "name" || a.Amount != 10 {
+		fail(t, "Versioned item not read correctly")
+	}
+}
+
+func TestVersionAdapter(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestVersioned{}); err != nil {
+		fail(t, err)
+	}
+	checkInsertVersionedRow(t, stub, 1, 0)
+	checkInsertVersionedRow(t, stub, 2, 3)
+
+	RegisterVersionAdapter("TestVersioned", 0, func(values map[string]interface{}) (map[string]interface{}, error) {
+		values["Name"] = strings.ToUpper(values["Name"].(string))
+		return values, nil
+	})
+	RegisterVersionAdapter("TestVersioned", 1, func(values map[string]interface{}) (map[string]interface{}, error) {
+		values["Amount"] = values["Amount"].(int64) * 100
+		return values, nil
+	})
+	defer delete(versionAdapters, "TestVersioned")
+
+	var a TestVersioned
+	if err := Get(stub, &a, 1); err != nil {
+		fail(t, err)
+	}
+	if a.Name != "NAME" || a.Amount != 300 {
+		fail(t, "Row should be upgraded by the adapter")
+	}
+
+	var b TestVersioned
+	if err := Get(stub, &b, 2); err == nil {
+		fail(t, "Rows with a newer version should not be read")
+	}
+}
+
+func TestLegacyDecoder(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestVersioned{}); err != nil {
+		fail(t, err)
+	}
+	checkInsertVersionedRow(t, stub, 1, 0)
+	checkInsertVersionedRow(t, stub, 2, 2)
+
+	RegisterLegacyDecoder("TestVersioned", 0, func(row shim.Row) (BlockchainItemizer, error) {
+		item := &TestVersioned{Name: "decoded", Amount: row.Columns[1].GetInt64()}
+		item.Id = row.Columns[2].GetInt64()
+		return item, nil
+	})
+	defer delete(legacyDecoders, "TestVersioned")
+
+	var items []TestVersioned
+	if err := GetAll(stub, &items); err != nil {
+		fail(t, err)
+	}
+	for _, item := range items {
+		if item.Id == 1 && item.Name != "decoded" {
+			fail(t, "Row should be decoded by the legacy decoder")
+		}
+		if item.Id == 2 && item.Name != "name" {
+			fail(t, "Current rows should not be decoded by the legacy decoder")
+		}
+	}
+
+	// Decoded items are upgraded when saved
+	var a TestVersioned
+	if err := Get(stub, &a, 1); err != nil {
+		fail(t, err)
+	}
+	if err := Update(stub, &a); err != nil {
+		fail(t, err)
+	}
+	row, _ := stub.GetRow("TestVersioned", []shim.Column{{Value: &shim.Column_Int64{Int64: 1}}})
+	if row.Columns[3].GetUint32() != 2 {
+		fail(t, "Row should be upgraded when saved")
+	}
+}
+
+type TestVersionedAddress struct {
+	City string
+}
+
+type TestVersionedTypes struct {
+	IP      net.IP
+	URL     url.URL
+	Address TestVersionedAddress
+	Saveable
+}
+
+func (s *TestVersionedTypes) SchemaVersion() uint32 { return 1 }
+
+func TestVersionAdapterDecodesColumns(t *testing.T) {
+	stub := shim.NewMockStub("cc", new(MockChaincode))
+	stub.MockTransactionStart("test")
+	if err := CreateTable(stub, &TestVersionedTypes{}); err != nil {
+		fail(t, err)
+	}
+	s := TestVersionedTypes{IP: net.ParseIP("10.0.0.1"), URL: url.URL{Scheme: "https", Host: "example.com"}, Address: TestVersionedAddress{City: "Utrecht"}}
+	if err := Create(stub, &s); err != nil {
+		fail(t, err)
+	}
+	// Written before the schema version was raised, without an adapter for it
+	key := []shim.Column{{Value: &shim.Column_Int64{Int64: s.Id}}}
+	row, err := stub.GetRow("TestVersionedTypes", key)
+	if err != nil {
+		fail(t, err)
+	}
+	row.Columns[len(row.Columns)-1] = &shim.Column{Value: &shim.Column_Uint32{Uint32: 0}}
+	if _, err := stub.ReplaceRow("TestVersionedTypes", row); err != nil {
+		fail(t, err)
+	}
+
+	var a TestVersionedTypes
+	if err := Get(stub, &a, s.Id); err != nil {
+		fail(t, err)
+	}
+	if !a.IP.Equal(s.IP) || a.URL != s.URL || !reflect.DeepEqual(a.Address, s.Address) {
+		fail(t, "Text, binary and JSON columns of older rows should be decoded like current rows")
+	}
+}
@@ -0,0 +1,35 @@
+package orm
+
+import (
+	"reflect"
+)
+
+// Prepare the types of the entities at initialization of the chaincode, so the first invocation that uses a type
+// doesn't pay for the reflection. The entities are registered and their declarations checked, like the tags of
+// indexes and transformers, so a mistake fails Init instead of a transaction:
+//
+// if err := orm.Warm(&User{}, &Order{}); err != nil {
+//   return nil, err
+// }
+func Warm(prototypes ...BlockchainItemizer) error {
+	o := newOptions(nil, nil)
+	for _, p := range prototypes {
+		if err := checkItem(p); err != nil {
+			return err
+		}
+		t := reflect.TypeOf(p).Elem()
+		if _, err := createColumnDefinitions(p, o); err != nil {
+			return err
+		}
+		if _, err := indexesOf(t, o); err != nil {
+			return err
+		}
+		for _, f := range fieldsOf(t) {
+			if _, err := transformersOf(f); err != nil {
+				return err
+			}
+		}
+		Register(p)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type TestMistyped struct {
+	Name string `transform:"trim,shout"`
+	Saveable
+}
+
+func TestWarm(t *testing.T) {
+	if err := Warm(&TestIndexed{}, &TestStruct{}); err != nil {
+		fail(t, err)
+	}
+	if _, ok := fieldCache.fields[reflect.TypeOf(TestIndexed{})]; !ok || registry["TestIndexed"] == nil {
+		fail(t, "The entities should be prepared and registered")
+	}
+	checkErrorContains(t, Warm(&TestMistyped{}), `Unknown transformer "shout" of Name`)
+}